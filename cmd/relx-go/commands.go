@@ -0,0 +1,409 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gyr/relx-go/pkg/app"
+	"github.com/gyr/relx-go/pkg/cli"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/gitea"
+)
+
+// newRootCommand returns the command tree of relx-go. The actions run with e.
+func newRootCommand(e *env) *cli.Command {
+	root := &cli.Command{
+		Name:    "relx-go",
+		Summary: "Orchestrate release management tasks for the openSUSE/SUSE ecosystem",
+		Setup: func(fs *cli.FlagSet) cli.Action {
+			e.flags = globalFlags{
+				verbose:    fs.Bool("v", "verbose", "Enable verbose output (INFO level)"),
+				debug:      fs.Bool("d", "debug", "Enable debug output (DEBUG level)"),
+				quiet:      fs.Bool("q", "quiet", "Only report errors, no warnings or progress (ERROR level)"),
+				configPath: fs.String("c", "config", "<path>", "", "Path to the configuration file"),
+				profile:    fs.String("", "profile", "<name>", "", "Select a configuration profile (default: $RELX_GO_PROFILE)"),
+				sets:       fs.Strings("", "set", "<key>=<value>", "Override a configuration setting (repeatable)"),
+				logFormat:  fs.String("", "log-format", "text|json", "text", "Format of log messages (default: text)"),
+				logFile:    fs.String("", "log-file", "<path>", "", "Append log messages to this file instead of writing them to stderr"),
+				deadline:   fs.Duration("", "deadline", "<duration>", 0, "Stop all operations after this duration, e.g. 10m (default: no deadline)"),
+			}
+			fs.SetCompleter("profile", e.complete(profileNames))
+			fs.SetCompleter("log-format", func() []string { return []string{"text", "json"} })
+			return nil
+		},
+		Before: e.setup,
+		Commands: []*cli.Command{
+			reviewCommand(e),
+			bugownerCommand(e),
+			artifactCommand(e),
+			catCommand(e),
+			exportCommand(e),
+			repoCommand(e),
+			cacheCommand(e),
+			configCommand(e),
+			doctorCommand(e),
+		},
+	}
+	root.Commands = append(root.Commands, cli.CompletionCommands(root.Name, e.stdout)...)
+	return root
+}
+
+// complete returns a completer that calls fn with the loaded configuration.
+func (e *env) complete(fn func(cfg *config.Config) []string) cli.Completer {
+	return func() []string {
+		if e.cfg == nil {
+			return nil
+		}
+		return fn(e.cfg)
+	}
+}
+
+// contains reports whether values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// profileNames returns the names of the configured profiles.
+func profileNames(cfg *config.Config) []string {
+	var names []string
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	return names
+}
+
+func reviewCommand(e *env) *cli.Command {
+	return &cli.Command{
+		Name:    "review",
+		Summary: "Review and approve pull requests",
+		Help: `Show the open pull requests of a repository that request a review from the
+reviewer one by one, and approve them on request. With --tui, review them in a
+full-screen terminal UI instead (press ? for its keys).
+
+-b and -r can be repeated to review the pull requests of every given branch of
+every given repository in one session. Without them, the branches listed in the
+review_targets setting are reviewed; -b or -r alone selects some of them.`,
+		Setup: func(fs *cli.FlagSet) cli.Action {
+			branches := fs.Strings("b", "branch", "<branch>", "Get pull requests for a specific branch (repeatable)")
+			prIDs := fs.String("p", "pr-id", "<id1,id2,...>", "", "Filter pull requests by specific PR IDs or <repository>#<id>")
+			repositories := fs.Strings("r", "repository", "<repository>", "Get pull requests for a specific repository (repeatable)")
+			user := fs.String("u", "user", "<user>", "", "Specify the PR reviewer (default: pr_reviewer)")
+			show := fs.String("", "show", "<part>", gitea.ShowAll, "What to show of each pull request: all, description, timeline (description and timeline) or diff (description and patch) (default: all)")
+			useTUI := fs.Bool("", "tui", "Review in a full-screen terminal UI")
+			fs.SetCompleter("repository", e.complete(app.CompletePullRequestRepositories))
+			fs.SetCompleter("show", func() []string { return gitea.ShowParts })
+
+			return func(args []string) error {
+				targets, err := reviewTargets(e.cfg, *branches, *repositories)
+				if err != nil {
+					return err
+				}
+				if !contains(gitea.ShowParts, *show) {
+					return cli.Usagef("for 'review', --show must be one of %s", strings.Join(gitea.ShowParts, ", "))
+				}
+				var ids []string
+				if *prIDs != "" {
+					ids = strings.Split(*prIDs, ",")
+				}
+				if *useTUI {
+					return app.HandleReviewTUI(e.ctx, e.cfg, e.runner, targets, ids, *user, *show)
+				}
+				return app.HandleReview(e.ctx, e.cfg, e.runner, targets, ids, *user, *show)
+			}
+		},
+	}
+}
+
+// reviewTargets returns the branches reviewed by 'review': every given branch of every
+// given repository, or the configured review_targets, limited to the given branches or
+// repositories if only one of -b and -r is used.
+func reviewTargets(cfg *config.Config, branches, repositories []string) ([]config.ReviewTarget, error) {
+	if len(branches) > 0 && len(repositories) > 0 {
+		var targets []config.ReviewTarget
+		for _, repository := range repositories {
+			for _, branch := range branches {
+				targets = append(targets, config.ReviewTarget{Repository: repository, Branch: branch})
+			}
+		}
+		return targets, nil
+	}
+	if len(cfg.ReviewTargets) == 0 {
+		if len(branches) == 0 {
+			return nil, cli.Usagef("for 'review', you must provide -b (branch) or configure review_targets")
+		}
+		return nil, cli.Usagef("for 'review', you must provide -r (repository) or configure review_targets")
+	}
+	var targets []config.ReviewTarget
+	for _, target := range cfg.ReviewTargets {
+		if (len(branches) == 0 || contains(branches, target.Branch)) && (len(repositories) == 0 || contains(repositories, target.Repository)) {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, cli.Usagef("for 'review', no review_targets match the given -b/-r")
+	}
+	return targets, nil
+}
+
+func bugownerCommand(e *env) *cli.Command {
+	return &cli.Command{
+		Name:    "bugowner",
+		Summary: "Show the bugowners of a package or the packages of a maintainer",
+		Setup: func(fs *cli.FlagSet) cli.Action {
+			pkg := fs.String("p", "package", "<pkg>", "", "Get bugowners for a specific package")
+			maintainer := fs.String("m", "maintainer", "<maintainer>", "", "List packages maintained by a user")
+
+			return func(args []string) error {
+				if (*pkg != "" && *maintainer != "") || (*pkg == "" && *maintainer == "") {
+					return cli.Usagef("for 'bugowner', you must provide either -p (package) OR -m (maintainer), but not both")
+				}
+				if *pkg != "" {
+					return app.HandleBugownerByPackage(e.ctx, e.cfg, e.runner, *pkg)
+				}
+				return app.HandlePackagesByMaintainer(e.ctx, e.cfg, e.runner, *maintainer)
+			}
+		},
+	}
+}
+
+func artifactCommand(e *env) *cli.Command {
+	return &cli.Command{
+		Name:    "artifact",
+		Summary: "List the artifacts of an OBS project",
+		Help: `List the binaries of the packages of an OBS project that match
+package_filter_patterns and binary_filter_patterns.`,
+		Setup: func(fs *cli.FlagSet) cli.Action {
+			project := fs.String("p", "project", "<project>", "", "List all artifacts for a specific project (mandatory)")
+			fs.SetCompleter("project", e.complete(app.CompleteProjects))
+
+			return func(args []string) error {
+				if *project == "" {
+					return cli.Usagef("a project must be specified using -p or --project")
+				}
+				return app.HandleArtifacts(e.ctx, e.cfg, e.runner, *project)
+			}
+		},
+	}
+}
+
+func catCommand(e *env) *cli.Command {
+	return &cli.Command{
+		Name:    "cat",
+		Args:    "<path>...",
+		Summary: "Print files of the product repository",
+		Setup: func(fs *cli.FlagSet) cli.Action {
+			ref := fs.String("b", "branch", "<ref>", "", "Read the files from a specific branch, tag or commit (default: repo_branch)")
+
+			return func(args []string) error {
+				if len(args) == 0 {
+					return cli.Usagef("for 'cat', you must provide at least one path")
+				}
+				return app.HandleCat(e.ctx, e.cfg, e.runner, *ref, args)
+			}
+		},
+	}
+}
+
+func exportCommand(e *env) *cli.Command {
+	return &cli.Command{
+		Name:    "export",
+		Args:    "<path>...",
+		Summary: "Export files and directories of the product repository",
+		Setup: func(fs *cli.FlagSet) cli.Action {
+			ref := fs.String("b", "branch", "<ref>", "", "Export from a specific branch, tag or commit (default: repo_branch)")
+			output := fs.String("o", "output", "<dir>", ".", "Destination directory (default: current directory)")
+
+			return func(args []string) error {
+				if len(args) == 0 {
+					return cli.Usagef("for 'export', you must provide at least one file or directory")
+				}
+				return app.HandleExport(e.ctx, e.cfg, e.runner, *ref, *output, args)
+			}
+		},
+	}
+}
+
+func repoCommand(e *env) *cli.Command {
+	repositories := e.complete(app.CompleteRepositories)
+	return &cli.Command{
+		Name:    "repo",
+		Summary: "Manage the repositories in the cache",
+		Commands: []*cli.Command{
+			{
+				Name:     "sync",
+				Args:     "[<name>...]",
+				Summary:  "Clone or update the configured repositories (all by default)",
+				Complete: repositories,
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					return func(args []string) error {
+						return app.HandleRepoSync(e.ctx, e.cfg, e.runner, args)
+					}
+				},
+			},
+			{
+				Name:     "path",
+				Args:     "<name>",
+				Summary:  "Print the local path of a synced repository",
+				Complete: repositories,
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					return func(args []string) error {
+						if len(args) != 1 {
+							return cli.Usagef("for 'repo path', you must provide exactly one repository name")
+						}
+						return app.HandleRepoPath(e.cfg, args[0])
+					}
+				},
+			},
+			{
+				Name:     "submodules",
+				Args:     "[<name>]",
+				Summary:  "Compare pinned submodule commits with their branch tips (default: repo_url)",
+				Complete: repositories,
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					behind := fs.Bool("", "behind", "Only list packages that are behind their branch")
+
+					return func(args []string) error {
+						if len(args) > 1 {
+							return cli.Usagef("for 'repo submodules', you can provide at most one repository name")
+						}
+						name := ""
+						if len(args) == 1 {
+							name = args[0]
+						}
+						return app.HandleRepoSubmodules(e.ctx, e.cfg, e.runner, name, *behind)
+					}
+				},
+			},
+		},
+	}
+}
+
+func cacheCommand(e *env) *cli.Command {
+	return &cli.Command{
+		Name:    "cache",
+		Summary: "Inspect and clean up the cache",
+		Commands: []*cli.Command{
+			{
+				Name:    "ls",
+				Summary: "List cache entries with size and last access time",
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					return func(args []string) error { return app.HandleCacheList(e.cfg) }
+				},
+			},
+			{
+				Name:    "du",
+				Summary: "Show the disk usage of the cache",
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					return func(args []string) error { return app.HandleCacheDiskUsage(e.cfg) }
+				},
+			},
+			{
+				Name:    "prune",
+				Summary: "Evict old and least recently used entries (default: configured limits)",
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					maxSize := fs.Int("s", "max-size", "<MB>", -1, "Maximum cache size in MB (default: cache_max_size_mb)")
+					maxAge := fs.Int("a", "max-age", "<days>", -1, "Maximum age in days since last access (default: cache_max_age_days)")
+					dryRun := fs.Bool("n", "dry-run", "Only show what would be removed")
+
+					return func(args []string) error {
+						return app.HandleCachePrune(e.cfg, *maxSize, *maxAge, *dryRun)
+					}
+				},
+			},
+			{
+				Name:    "clear",
+				Summary: "Remove all cache entries",
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					return func(args []string) error { return app.HandleCacheClear(e.cfg) }
+				},
+			},
+			{
+				Name:    "invalidate",
+				Args:    "[<prefix>]",
+				Summary: "Drop memoized remote lookups (all, or those whose key starts with prefix)",
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					return func(args []string) error {
+						prefix := ""
+						if len(args) > 0 {
+							prefix = args[0]
+						}
+						return app.HandleCacheInvalidate(e.cfg, prefix)
+					}
+				},
+			},
+		},
+	}
+}
+
+func configCommand(e *env) *cli.Command {
+	return &cli.Command{
+		Name:    "config",
+		Summary: "Show, validate and create the configuration",
+		Commands: []*cli.Command{
+			{
+				Name:    "show",
+				Summary: "Print the effective configuration",
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					origin := fs.Bool("", "origin", "Show where each value came from")
+
+					return func(args []string) error {
+						return app.HandleConfigShow(e.cfg, *origin)
+					}
+				},
+			},
+			{
+				Name:    "validate",
+				Args:    "[<command>]",
+				Summary: "Check the configuration (and the settings required by a command)",
+				Complete: func() []string {
+					return []string{"review", "bugowner", "artifact", "cat", "export", "repo", "cache"}
+				},
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					return func(args []string) error {
+						if len(args) > 1 {
+							return cli.Usagef("for 'config validate', you can provide at most one command")
+						}
+						command := ""
+						if len(args) == 1 {
+							command = args[0]
+						}
+						return app.HandleConfigValidate(e.cfg, command)
+					}
+				},
+			},
+			{
+				Name:    "init",
+				Summary: "Interactively write a starter configuration (default: ~/.config/relx-go/config.yaml)",
+				Setup: func(fs *cli.FlagSet) cli.Action {
+					force := fs.Bool("f", "force", "Overwrite an existing configuration file")
+					output := fs.String("o", "output", "<path>", "", "Path of the configuration file to write")
+
+					return func(args []string) error {
+						return app.HandleConfigInit(e.cfg, e.stdin, *output, *force)
+					}
+				},
+			},
+		},
+	}
+}
+
+func doctorCommand(e *env) *cli.Command {
+	return &cli.Command{
+		Name:    "doctor",
+		Summary: "Check the tools, logins, repository access, cache and configuration",
+		Help: `Check that the external tools relx-go depends on (git, tar, osc and
+git-obs) and the viewer of pull requests (pr_viewer) are installed, that osc and
+git-obs are logged in, that the product repository can be reached, that the cache
+directory is writable and that the configuration is valid. For each failed check,
+print how to fix it.`,
+		Setup: func(fs *cli.FlagSet) cli.Action {
+			return func(args []string) error {
+				return app.HandleDoctor(e.ctx, e.cfg, e.runner)
+			}
+		},
+	}
+}
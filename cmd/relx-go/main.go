@@ -2,196 +2,215 @@ package main
 
 import (
 	"context" // Import context for cancellation and timeouts
-	"flag"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/gyr/relx-go/pkg/app"
+	"github.com/gyr/relx-go/pkg/cli"
 	"github.com/gyr/relx-go/pkg/command" // Import the new command runner
 	"github.com/gyr/relx-go/pkg/config"
 	"github.com/gyr/relx-go/pkg/logging"
+	"github.com/gyr/relx-go/pkg/progress"
+	"github.com/gyr/relx-go/pkg/timeout"
 )
 
+// globalFlags holds the values of the flags given before the command.
+type globalFlags struct {
+	verbose, debug, quiet *bool
+	configPath, profile   *string
+	sets                  *[]string
+	logFormat, logFile    *string
+	deadline              *time.Duration
+}
+
+// Exit codes of relx-go. Wrapper scripts can rely on them, e.g. to tell "no pull requests
+// pending review" from "git-obs crashed". An operation that timed out or was stopped by
+// --deadline exits with timeout.ExitCode (124).
+const (
+	exitOK       = 0
+	exitFailure  = 1 // Any other error, e.g. an invalid configuration
+	exitUsage    = 2 // Wrong command line; the usage of the command is printed
+	exitNotFound = 3 // Nothing found, e.g. no pull requests pending review or an unknown package
+	exitPartial  = 4 // Some items failed, e.g. 1 of 3 repositories failed to sync
+	exitExternal = 5 // An external tool (git, git-obs, osc) failed or is not installed
+)
+
+// env is what the commands run with. The global flags are set when the command line is
+// parsed; setup fills in the rest before the action of the selected command runs.
+type env struct {
+	flags   globalFlags
+	command string // The selected command, e.g. "repo sync"
+	ctx     context.Context
+	cancel  context.CancelFunc
+	cfg     *config.Config
+	runner  command.Runner
+	logger  *logging.Logger
+	logFile *os.File
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
 func main() {
-	var verbose, debug bool
-	var configPath string
+	os.Exit(Run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Run runs relx-go with the command-line arguments args, which do not include the
+// program name, and returns the exit code. Prompts are answered from stdin; results are
+// written to stdout, log messages and errors to stderr.
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	// The runner is passed down to functions that need to execute external commands,
+	// enabling dependency injection for easier testing.
+	return run(ctx, args, stdin, stdout, stderr, &command.DefaultRunner{})
+}
+
+// run is Run with the runner for external commands.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, runner command.Runner) int {
+	e := &env{ctx: ctx, runner: runner, stdin: stdin, stdout: stdout, stderr: stderr}
+	defer e.close()
+
+	err := newRootCommand(e).Execute(args, stdout, stderr)
+	code := exitCode(err)
+	switch {
+	case err == nil, code == exitUsage:
+		// Usage errors were printed by Execute together with the usage.
+	case e.logger == nil:
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+	case code == exitNotFound:
+		// Handlers explain on the output what was not found.
+		e.logger.Infof("Nothing found for %s: %v", e.command, err)
+	case e.cfg == nil:
+		e.logger.Failf("Error: %v", err)
+	default:
+		e.logger.Failf("Error handling %s: %v", e.command, err)
+	}
+	return code
+}
+
+// exitCode returns the exit code for the error of a command.
+func exitCode(err error) int {
+	var usage *cli.UsageError
+	var timeoutErr *timeout.TimeoutError
+	var partial *app.PartialError
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &partial) && partial.Failed < partial.Total:
+		// Some items succeeded. If all of them failed, the causes decide instead.
+		return exitPartial
+	case errors.As(err, &timeoutErr):
+		// Checked before external tools, since their errors are wrapped by timeouts.
+		return timeoutErr.ExitCode()
+	case errors.Is(err, app.ErrNotFound):
+		return exitNotFound
+	case errors.As(err, &exitErr), errors.Is(err, exec.ErrNotFound):
+		return exitExternal
+	case partial != nil:
+		return exitPartial
+	}
+	return exitFailure
+}
 
-	flag.BoolVar(&verbose, "v", false, "Enable verbose output (INFO level).")
-	flag.BoolVar(&debug, "d", false, "Enable debug output (DEBUG level).")
-	flag.StringVar(&configPath, "c", "", "Path to the configuration file.")
-	flag.Parse()
+// setup creates the logger, loads the configuration and applies --deadline to the root
+// context for the selected command. It is called after the command line has been parsed.
+func (e *env) setup(cmd *cli.Command) error {
+	path := cmd.Path()
+	e.command = strings.Join(path, " ")
 
 	var logLevel logging.LogLevel
-	if debug {
+	if *e.flags.debug {
 		logLevel = logging.LevelDebug
-	} else if verbose {
+	} else if *e.flags.verbose {
 		logLevel = logging.LevelInfo
-	} else {
+	} else if *e.flags.quiet {
 		logLevel = logging.LevelError
+	} else {
+		logLevel = logging.LevelWarn
 	}
-	logger := logging.NewLogger(logLevel)
-
-	// Find and load configuration
-	cfgFile, err := config.FindConfigFile(configPath)
+	format, err := logging.ParseFormat(*e.flags.logFormat)
 	if err != nil {
-		logger.Infof("Warning: %v. Proceeding without custom configuration.", err)
+		return &cli.UsageError{Command: cmd, Message: err.Error()}
 	}
-
-	var cfg *config.Config
-	if cfgFile != "" {
-		cfg, err = config.LoadConfig(cfgFile)
+	logOptions := logging.Options{Level: logLevel, Format: format, Stderr: e.stderr}
+	if *e.flags.logFile != "" {
+		e.logFile, err = logging.OpenFile(*e.flags.logFile)
 		if err != nil {
-			logger.Fatalf("Error loading configuration from %s: %v", cfgFile, err)
-		}
-		cfg.Logger = logger // Assign the logger to the config
-		cfg.OutputWriter = os.Stdout
-		logger.Debug("Configuration loaded from: ", cfgFile)
-	} else {
-		// Provide a default configuration if no file is found/loaded
-		cfg = &config.Config{
-			CacheDir:     "",        // Default to empty, gitutils will use its own default
-			Logger:       logger,    // Assign the logger to the default config
-			OutputWriter: os.Stdout, // Default to os.Stdout for application output
-			// OperationTimeoutSeconds will be set to default 300 in config.LoadConfig if not specified
+			return err
 		}
+		logOptions.Output = e.logFile
 	}
+	logger := logging.New(logOptions)
+	e.logger = logger
 
-	// Initialize the default command runner and the root context for the application.
-	// The runner is passed down to functions that need to execute external commands,
-	// enabling dependency injection for easier testing.
-	defaultRunner := &command.DefaultRunner{}
-	ctx := context.Background() // Use context.Background() as the root context
-
-	args := flag.Args() // Get non-flag arguments after flag.Parse()
-
-	validCommands := []string{"review", "bugowner", "artifact"}
-
-	if len(args) < 1 {
-		fmt.Println("Usage: relx-go <command> [arguments]")
-		fmt.Println("\nCommands:")
-		for _, cmd := range validCommands {
-			fmt.Printf("  %s\n", cmd)
-		}
-		os.Exit(1)
-	}
-
-	command := args[0]
-	commandArgs := args[1:]
-
-	switch command {
-	case "review":
-		reviewCmd := flag.NewFlagSet("review", flag.ContinueOnError)
-		branchFlag := reviewCmd.String("b", "", "Specify the branch")
-		prIDFlag := reviewCmd.String("p", "", "Specify one or more comma-separated PR IDs")
-		repoFlag := reviewCmd.String("r", "", "Specify the repository")
-		userFlag := reviewCmd.String("u", "", "Specify the PR reviewer")
-
-		reviewCmd.Usage = func() {
-			fmt.Fprintf(os.Stderr, "Usage of %s review:\n", os.Args[0])
-			fmt.Fprintf(os.Stderr, "  -b, --branch <branch>       Get pull requests for a specific branch (mandatory)\n")
-			fmt.Fprintf(os.Stderr, "  -p, --pr-id <id1,id2,...> Filter pull requests by specific PR IDs (optional)\n")
-			fmt.Fprintf(os.Stderr, "  -r, --repository <repository>   Get pull requests for a specific repository\n")
-			fmt.Fprintf(os.Stderr, "  -u, --user <user>             Specify the PR reviewer\n")
-		}
-
-		err = reviewCmd.Parse(commandArgs)
-		if err != nil {
-			if err == flag.ErrHelp {
-				os.Exit(0)
-			}
-			os.Exit(1)
-		}
-
-		if *branchFlag == "" {
-			fmt.Fprintf(os.Stderr, "Error: For 'review', you must provide -b (branch).\n")
-			reviewCmd.Usage()
-			os.Exit(1)
-		}
-
-		if *repoFlag == "" {
-			fmt.Fprintf(os.Stderr, "Error: For 'review', you must provide -r (repository).\n")
-			reviewCmd.Usage()
-			os.Exit(1)
-		}
-
-		var prIDs []string
-		if *prIDFlag != "" {
-			prIDs = strings.Split(*prIDFlag, ",")
-		}
-
-		if err := app.HandleReview(ctx, cfg, defaultRunner, *branchFlag, prIDs, *repoFlag, *userFlag); err != nil {
-			logger.Fatalf("Error handling review: %v", err)
-		}
-
-	case "bugowner":
-		bugownerCmd := flag.NewFlagSet("bugowner", flag.ContinueOnError)
-		pkgFlag := bugownerCmd.String("p", "", "Specify the package")
-		maintainerFlag := bugownerCmd.String("m", "", "Specify the maintainer")
-
-		bugownerCmd.Usage = func() {
-			fmt.Fprintf(os.Stderr, "Usage of %s bugowner:\n", os.Args[0])
-			fmt.Fprintf(os.Stderr, "  -p <pkg>          Get bugowners for a specific package\n")
-			fmt.Fprintf(os.Stderr, "  -m <maintainer>   List packages maintained by a user\n")
-		}
-
-		err = bugownerCmd.Parse(commandArgs)
-		if err != nil {
-			if err == flag.ErrHelp {
-				os.Exit(0)
-			}
-			os.Exit(1)
-		}
-
-		if (*pkgFlag != "" && *maintainerFlag != "") || (*pkgFlag == "" && *maintainerFlag == "") {
-			fmt.Fprintf(os.Stderr, "Error: For 'bugowner', you must provide either -p (package) OR -m (maintainer), but not both.\n")
-			bugownerCmd.Usage()
-			os.Exit(1)
-		}
-
-		if *pkgFlag != "" {
-			// Updated call to pass context and runner
-			if err := app.HandleBugownerByPackage(ctx, cfg, defaultRunner, *pkgFlag); err != nil {
-				logger.Fatalf("Error handling bugowner by package: %v", err)
-			}
-		} else { // *maintainerFlag != ""
-			// Updated call to pass context and runner
-			if err := app.HandlePackagesByMaintainer(ctx, cfg, defaultRunner, *maintainerFlag); err != nil {
-				logger.Fatalf("Error handling packages by maintainer: %v", err)
-			}
-		}
-	case "artifact":
-		artifactCmd := flag.NewFlagSet("artifact", flag.ContinueOnError)
-		projectFlag := artifactCmd.String("p", "", "Specify the project to list artifacts from (mandatory)")
+	if *e.flags.deadline > 0 {
+		// Operations still running at the deadline fail with a timeout error naming them.
+		e.ctx, e.cancel = context.WithTimeout(e.ctx, *e.flags.deadline)
+	}
 
-		artifactCmd.Usage = func() {
-			fmt.Fprintf(os.Stderr, "Usage of %s artifact:\n", os.Args[0])
-			fmt.Fprintf(os.Stderr, "  -p, --project <project>   List all artifacts for a specific project\n")
-		}
+	if path[0] == "completion" {
+		return nil // Printing completion scripts needs no configuration
+	}
 
-		err = artifactCmd.Parse(commandArgs)
-		if err != nil {
-			if err == flag.ErrHelp {
-				os.Exit(0)
-			}
-			os.Exit(1)
-		}
+	// Load the configuration from all layers: system and user files, RELX_GO_CONFIG_FILE,
+	// -c, the selected profile, RELX_GO_* environment variables and --set overrides.
+	// The config subcommands and doctor inspect the configuration, so they also work when
+	// it is invalid. Shell completion works with whatever configuration is available.
+	completing := cli.IsCompletion(cmd)
+	inspectConfig := path[0] == "config" || path[0] == "doctor" || completing
 
-		if *projectFlag == "" {
-			fmt.Fprintf(os.Stderr, "Error: a project must be specified using -p or --project.\n")
-			artifactCmd.Usage()
-			os.Exit(1)
+	cfg, err := config.Load(config.LoadOptions{ConfigPath: *e.flags.configPath, Profile: *e.flags.profile, Sets: *e.flags.sets, SkipValidation: inspectConfig})
+	if err != nil && completing {
+		cfg, err = config.Default()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if !inspectConfig {
+		if err := cfg.CheckRequired(path[0]); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
 		}
+	}
+	cfg.Logger = logger // Assign the logger to the config
+	cfg.OutputWriter = e.stdout
+	cfg.InputReader = e.stdin
+	cfg.Progress = progress.New(e.stderr, progressMode(*e.flags.quiet))
+	e.cfg = cfg
+
+	if len(cfg.Files) == 0 {
+		logger.Infof("No configuration file found; proceeding with defaults.")
+	}
+	for _, file := range cfg.Files {
+		logger.Debug("Configuration loaded from: ", file)
+	}
+	if cfg.Profile != "" {
+		logger.Debugf("Using configuration profile: %s", cfg.Profile)
+	}
+	return nil
+}
 
-		if err := app.HandleArtifacts(ctx, cfg, defaultRunner, *projectFlag); err != nil {
-			logger.Fatalf("Error handling artifacts: %v", err)
-		}
+// progressMode returns how long operations report their progress on stderr: not at all
+// with -q, and as a bar or as plain lines depending on whether stderr is a terminal.
+func progressMode(quiet bool) progress.Mode {
+	if quiet {
+		return progress.ModeQuiet
+	}
+	return progress.ModeAuto
+}
 
-	default:
-		fmt.Printf("Unknown command: %s. Possible commands are:\n", command)
-		for _, cmd := range validCommands {
-			fmt.Printf("  %s\n", cmd)
-		}
-		os.Exit(1)
+// close releases the root context and the log file.
+func (e *env) close() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	if e.logFile != nil {
+		_ = e.logFile.Close()
 	}
 }
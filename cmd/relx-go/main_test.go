@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/command/commandtest"
+	"github.com/gyr/relx-go/pkg/timeout"
+)
+
+// writeConfig writes a configuration file for the tests and returns its path.
+func writeConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := fmt.Sprintf(`cache_dir: %q
+metadata_cache_ttl_seconds: -1
+pr_reviewer: "reviewer"
+pr_viewer: "pager"
+`, filepath.Join(dir, "cache"))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+// gitObsRunner returns a runner that answers 'git-obs pr list' with prList and fails
+// 'git-obs pr show' of the PRs in showErrs.
+func gitObsRunner(prList string, listErr error, showErrs map[string]error) *commandtest.MockRunner {
+	return &commandtest.MockRunner{
+		RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+			if name == "git-obs" && args[1] == "list" {
+				return []byte(prList), listErr
+			}
+			return nil, nil
+		},
+		RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+			show := p.Stages[0]
+			return showErrs[show[len(show)-1]]
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	configPath := writeConfig(t)
+	t.Setenv("PAGER", "cat")
+	// A real *exec.ExitError, as returned when git-obs exits with a non-zero status.
+	crashed := exec.Command("false").Run()
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		runner     *commandtest.MockRunner
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "Help",
+			args:       []string{"--help"},
+			runner:     &commandtest.MockRunner{},
+			wantCode:   exitOK,
+			wantStdout: "Usage: relx-go [flags] <command>",
+		},
+		{
+			name:       "NoCommand",
+			runner:     &commandtest.MockRunner{},
+			wantCode:   exitUsage,
+			wantStderr: "Usage: relx-go",
+		},
+		{
+			name:       "UnknownCommand",
+			args:       []string{"bogus"},
+			runner:     &commandtest.MockRunner{},
+			wantCode:   exitUsage,
+			wantStderr: "Error: unknown command 'bogus'",
+		},
+		{
+			name:       "MissingFlag",
+			args:       []string{"-c", configPath, "review", "-r", "products/SLFO"},
+			runner:     &commandtest.MockRunner{},
+			wantCode:   exitUsage,
+			wantStderr: "you must provide -b (branch)",
+		},
+		{
+			name:       "UnknownShowPart",
+			args:       []string{"-c", configPath, "review", "-b", "main", "-r", "products/SLFO", "--show", "files"},
+			runner:     &commandtest.MockRunner{},
+			wantCode:   exitUsage,
+			wantStderr: "--show must be one of all, description, timeline, diff",
+		},
+		{
+			name:       "UnknownLogFormat",
+			args:       []string{"--log-format", "xml", "cache", "ls"},
+			runner:     &commandtest.MockRunner{},
+			wantCode:   exitUsage,
+			wantStderr: `unknown log format "xml"`,
+		},
+		{
+			name:       "InvalidConfiguration",
+			args:       []string{"-c", configPath, "--set", "operation_timeout_seconds=-1", "cache", "ls"},
+			runner:     &commandtest.MockRunner{},
+			wantCode:   exitFailure,
+			wantStderr: "Error: failed to load configuration",
+		},
+		{
+			name:       "Approve",
+			args:       []string{"-c", configPath, "review", "-b", "main", "-r", "products/SLFO"},
+			stdin:      "y\na\n",
+			runner:     gitObsRunner("ID: #1", nil, nil),
+			wantCode:   exitOK,
+			wantStdout: "PR 1 approved.",
+		},
+		{
+			name:       "NoPullRequests",
+			args:       []string{"-c", configPath, "review", "-b", "main", "-r", "products/SLFO"},
+			runner:     gitObsRunner("", nil, nil),
+			wantCode:   exitNotFound,
+			wantStdout: "No open pull requests found for review.",
+		},
+		{
+			name:       "PartialFailure",
+			args:       []string{"-c", configPath, "review", "-b", "main", "-r", "products/SLFO"},
+			stdin:      "y\na\n",
+			runner:     gitObsRunner("ID: #1\nID: #2", nil, map[string]error{"products/SLFO#1": crashed}),
+			wantCode:   exitPartial,
+			wantStderr: "Error handling review: failed to show or approve 1 of 2 pull requests",
+		},
+		{
+			name:       "AllItemsFailed",
+			args:       []string{"-c", configPath, "review", "-b", "main", "-r", "products/SLFO"},
+			stdin:      "y\n",
+			runner:     gitObsRunner("ID: #1\nID: #2", nil, map[string]error{"products/SLFO#1": crashed, "products/SLFO#2": crashed}),
+			wantCode:   exitExternal,
+			wantStderr: "Error handling review: failed to show or approve 2 of 2 pull requests",
+		},
+		{
+			name:       "ExternalToolFailed",
+			args:       []string{"-c", configPath, "review", "-b", "main", "-r", "products/SLFO"},
+			runner:     gitObsRunner("", crashed, nil),
+			wantCode:   exitExternal,
+			wantStderr: "Error handling review: failed to get open pull requests",
+		},
+		{
+			name:       "ExternalToolMissing",
+			args:       []string{"-c", configPath, "review", "-b", "main", "-r", "products/SLFO"},
+			runner:     gitObsRunner("", &exec.Error{Name: "git-obs", Err: exec.ErrNotFound}, nil),
+			wantCode:   exitExternal,
+			wantStderr: "executable file not found",
+		},
+		{
+			name: "Deadline",
+			args: []string{"-c", configPath, "--deadline", "10ms", "review", "-b", "main", "-r", "products/SLFO"},
+			runner: &commandtest.MockRunner{
+				RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+					<-ctx.Done()
+					return nil, errors.New("signal: killed")
+				},
+			},
+			wantCode:   timeout.ExitCode,
+			wantStderr: "gitea_list was stopped by the deadline of the run",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(context.Background(), tt.args, strings.NewReader(tt.stdin), &stdout, &stderr, tt.runner)
+
+			if code != tt.wantCode {
+				t.Errorf("Expected exit code %d, got %d\nstdout:\n%s\nstderr:\n%s", tt.wantCode, code, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("Expected stdout to contain %q, got:\n%s", tt.wantStdout, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("Expected stderr to contain %q, got:\n%s", tt.wantStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunReviewTargets(t *testing.T) {
+	configPath := writeConfig(t)
+	f, err := os.OpenFile(configPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open config: %v", err)
+	}
+	if _, err := f.WriteString(`review_targets:
+  - repository: "products/SLFO"
+    branch: "main"
+  - repository: "products/SLES"
+    branch: "16.0"
+`); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	f.Close()
+	t.Setenv("PAGER", "cat")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantLists  []string
+		wantStderr string
+	}{
+		// Nothing is pending review, so a successful lookup finds nothing.
+		{"Configured", nil, exitNotFound, []string{"main products/SLFO", "16.0 products/SLES"}, ""},
+		{"ConfiguredBranch", []string{"-b", "16.0"}, exitNotFound, []string{"16.0 products/SLES"}, ""},
+		{"Flags", []string{"-b", "main", "-b", "16.0", "-r", "products/SLFO"}, exitNotFound, []string{"main products/SLFO", "16.0 products/SLFO"}, ""},
+		{"NoMatch", []string{"-r", "products/Leap"}, exitUsage, nil, "no review_targets match the given -b/-r"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var lists []string
+			runner := &commandtest.MockRunner{
+				RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+					if args[1] == "list" {
+						mu.Lock()
+						defer mu.Unlock()
+						lists = append(lists, strings.Join(args[len(args)-2:], " "))
+					}
+					return nil, nil
+				},
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-c", configPath, "review"}, tt.args...)
+			code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr, runner)
+
+			if code != tt.wantCode {
+				t.Errorf("Expected exit code %d, got %d\nstderr:\n%s", tt.wantCode, code, stderr.String())
+			}
+			sort.Strings(lists)
+			sort.Strings(tt.wantLists)
+			if strings.Join(lists, ",") != strings.Join(tt.wantLists, ",") {
+				t.Errorf("Expected PR lists of %v, got %v", tt.wantLists, lists)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("Expected stderr to contain %q, got:\n%s", tt.wantStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunCompletion(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"__complete", "--", "repo", "s"}, strings.NewReader(""), &stdout, &stderr, &commandtest.MockRunner{})
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if got, want := stdout.String(), "submodules\nsync\n"; got != want {
+		t.Errorf("Expected candidates %q, got %q", want, got)
+	}
+}
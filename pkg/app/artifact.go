@@ -12,6 +12,7 @@ import (
 
 // HandleArtifacts is the handler for the 'artifact' subcommand.
 // It orchestrates the fetching and display of artifacts for a given project.
+// If the project has no matching artifacts, the error wraps ErrNotFound.
 func HandleArtifacts(ctx context.Context, cfg *config.Config, runner command.Runner, project string) error {
 	cfg.Logger.Infof("Handling artifact request for project: %s", project)
 
@@ -32,6 +33,7 @@ func HandleArtifacts(ctx context.Context, cfg *config.Config, runner command.Run
 		if _, err := fmt.Fprintf(cfg.OutputWriter, "No artifacts found for project '%s'.\n", project); err != nil {
 			return err
 		}
+		return notFoundf("no artifacts found for project '%s'", project)
 	}
 
 	return nil
@@ -22,6 +22,7 @@ func TestHandleArtifacts(t *testing.T) {
 		cfg                  *config.Config
 		expectedOutput       string
 		expectError          bool
+		expectNotFound       bool // The output is still checked
 		expectedErrorMessage string
 	}{
 		{
@@ -62,7 +63,7 @@ func TestHandleArtifacts(t *testing.T) {
 				},
 			},
 			expectedOutput: "No artifacts found for project 'test-project'.\n",
-			expectError:    false,
+			expectNotFound: true,
 		},
 		{
 			name:    "error listing packages",
@@ -93,7 +94,11 @@ func TestHandleArtifacts(t *testing.T) {
 				assert.Error(t, err)
 				assert.Contains(t, err.Error(), tt.expectedErrorMessage)
 			} else {
-				assert.NoError(t, err)
+				if tt.expectNotFound {
+					assert.ErrorIs(t, err, ErrNotFound)
+				} else {
+					assert.NoError(t, err)
+				}
 				assert.Equal(t, tt.expectedOutput, out.String())
 			}
 		})
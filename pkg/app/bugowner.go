@@ -32,6 +32,7 @@ func prepareMaintainershipData(ctx context.Context, cfg *config.Config, runner c
 }
 
 // HandleBugownerByPackage fetches and displays the bug owners for a given package.
+// If the package is not in the maintainership data, the error wraps ErrNotFound.
 // It now accepts a context and a command.Runner, demonstrating Dependency Injection
 // for improved testability and operational control.
 func HandleBugownerByPackage(ctx context.Context, cfg *config.Config, runner command.Runner, pkg string) error {
@@ -55,11 +56,13 @@ func HandleBugownerByPackage(ctx context.Context, cfg *config.Config, runner com
 		if _, err := fmt.Fprintf(cfg.OutputWriter, "Package '%s' not found in maintainership data.\n", pkg); err != nil {
 			return err
 		}
+		return notFoundf("package '%s' not found in maintainership data", pkg)
 	}
 	return nil
 }
 
 // HandlePackagesByMaintainer lists the packages maintained by a given user.
+// If the user maintains no packages, the error wraps ErrNotFound.
 // It now accepts a context and a command.Runner, demonstrating Dependency Injection
 // for improved testability and operational control.
 func HandlePackagesByMaintainer(ctx context.Context, cfg *config.Config, runner command.Runner, maintainer string) error {
@@ -93,6 +96,7 @@ func HandlePackagesByMaintainer(ctx context.Context, cfg *config.Config, runner
 		if _, err := fmt.Fprintf(cfg.OutputWriter, "No packages found for maintainer '%s'.\n", maintainer); err != nil {
 			return err
 		}
+		return notFoundf("no packages found for maintainer '%s'", maintainer)
 	}
 	return nil
 }
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"strings"
 	"testing"
 
+	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/command/commandtest" // Import the shared mock runner
 	"github.com/gyr/relx-go/pkg/config"
 	"github.com/gyr/relx-go/pkg/logging"
@@ -18,12 +20,13 @@ func TestHandleBugownerByPackage(t *testing.T) {
 
 	// This is the mock for a successful git archive call.
 	successfulRunner := &commandtest.MockRunner{
-		RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+		RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
 			// Check if the command is what we expect
-			if name == "bash" && strings.Contains(args[1], "git archive") {
-				return []byte(maintainershipContent), nil
+			if p.Stages[0][0] == "git" && p.Stages[0][1] == "archive" {
+				_, err := io.WriteString(p.Stdout, maintainershipContent)
+				return err
 			}
-			return nil, nil
+			return nil
 		},
 	}
 
@@ -60,8 +63,8 @@ func TestHandleBugownerByPackage(t *testing.T) {
 		}
 
 		err := HandleBugownerByPackage(context.Background(), cfg, successfulRunner, "nonexistent")
-		if err != nil {
-			t.Errorf("Expected no error, got %v", err)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
 		}
 
 		output := out.String()
@@ -82,8 +85,8 @@ func TestHandleBugownerByPackage(t *testing.T) {
 
 		mockError := errors.New("git archive failed")
 		failedRunner := &commandtest.MockRunner{
-			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
-				return nil, mockError
+			RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+				return mockError
 			},
 		}
 
@@ -102,11 +105,12 @@ func TestHandlePackagesByMaintainer(t *testing.T) {
 	const maintainershipContent = `{"pkg1": ["userA", "userB"], "pkg2": ["userC"], "pkg3": ["userA"]}`
 
 	successfulRunner := &commandtest.MockRunner{
-		RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
-			if name == "bash" && strings.Contains(args[1], "git archive") {
-				return []byte(maintainershipContent), nil
+		RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+			if p.Stages[0][0] == "git" && p.Stages[0][1] == "archive" {
+				_, err := io.WriteString(p.Stdout, maintainershipContent)
+				return err
 			}
-			return nil, nil
+			return nil
 		},
 	}
 
@@ -143,8 +147,8 @@ func TestHandlePackagesByMaintainer(t *testing.T) {
 		}
 
 		err := HandlePackagesByMaintainer(context.Background(), cfg, successfulRunner, "nonexistent")
-		if err != nil {
-			t.Errorf("Expected no error, got %v", err)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
 		}
 
 		output := out.String()
@@ -164,8 +168,9 @@ func TestHandlePackagesByMaintainer(t *testing.T) {
 		}
 
 		malformedRunner := &commandtest.MockRunner{
-			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
-				return []byte("this is not json"), nil
+			RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+				_, err := io.WriteString(p.Stdout, "this is not json")
+				return err
 			},
 		}
 
@@ -0,0 +1,219 @@
+package app
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gyr/relx-go/pkg/cache"
+	"github.com/gyr/relx-go/pkg/config"
+)
+
+// HandleCacheList is the handler for the 'cache ls' subcommand.
+// It lists all cache entries with their size and last access time.
+func HandleCacheList(cfg *config.Config) error {
+	c, err := cache.New(cfg.CacheDir)
+	if err != nil {
+		return err
+	}
+	entries, err := c.Entries()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "Cache %s is empty.\n", cfg.CacheDir); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cfg.OutputWriter, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "NAME\tSIZE\tLAST ACCESS"); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, formatSize(e.Size), e.LastAccess.Format("2006-01-02 15:04")); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// HandleCacheDiskUsage is the handler for the 'cache du' subcommand.
+// It prints the size of each cache entry and of the memoized lookups, and the total size
+// of the cache, compared with the configured limit.
+func HandleCacheDiskUsage(cfg *config.Config) error {
+	c, err := cache.New(cfg.CacheDir)
+	if err != nil {
+		return err
+	}
+	entries, err := c.Entries()
+	if err != nil {
+		return err
+	}
+	blobsSize, err := c.BlobsSize()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(cfg.OutputWriter, 0, 4, 2, ' ', 0)
+	for _, e := range entries {
+		total += e.Size
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", formatSize(e.Size), e.Name); err != nil {
+			return err
+		}
+	}
+	if blobsSize > 0 {
+		total += blobsSize
+		if _, err := fmt.Fprintf(w, "%s\t(memoized lookups)\n", formatSize(blobsSize)); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	limit := "unlimited"
+	if cfg.CacheMaxSizeMB > 0 {
+		limit = formatSize(int64(cfg.CacheMaxSizeMB) << 20)
+	}
+	if _, err := fmt.Fprintf(cfg.OutputWriter, "Total: %s in %s (limit: %s)\n", formatSize(total), cfg.CacheDir, limit); err != nil {
+		return err
+	}
+	return nil
+}
+
+// HandleCachePrune is the handler for the 'cache prune' subcommand.
+// It evicts entries older than maxAgeDays and then the least recently used entries until
+// the cache is below maxSizeMB. Negative values fall back to the configured limits.
+func HandleCachePrune(cfg *config.Config, maxSizeMB, maxAgeDays int, dryRun bool) error {
+	if maxSizeMB < 0 {
+		maxSizeMB = cfg.CacheMaxSizeMB
+	}
+	if maxAgeDays < 0 {
+		maxAgeDays = cfg.CacheMaxAgeDays
+	}
+	if maxSizeMB == 0 && maxAgeDays == 0 {
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "No cache limits configured; nothing to prune.\n"); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	c, err := cache.New(cfg.CacheDir)
+	if err != nil {
+		return err
+	}
+	evicted, err := c.Prune(pruneOptions(maxSizeMB, maxAgeDays, nil, dryRun))
+	if err != nil {
+		return err
+	}
+
+	action := "Removed"
+	if dryRun {
+		action = "Would remove"
+	}
+	var freed int64
+	for _, e := range evicted {
+		freed += e.Size
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "%s %s (%s, last access %s)\n", action, entryName(e), formatSize(e.Size), e.LastAccess.Format("2006-01-02")); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(cfg.OutputWriter, "%s %d entries, %s.\n", action, len(evicted), formatSize(freed)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// HandleCacheClear is the handler for the 'cache clear' subcommand.
+// It removes all entries from the cache that are not in use by another process.
+func HandleCacheClear(cfg *config.Config) error {
+	c, err := cache.New(cfg.CacheDir)
+	if err != nil {
+		return err
+	}
+	// Entries locked by other processes are skipped; report what was removed anyway.
+	removed, clearErr := c.Clear()
+	if _, err := c.Invalidate(""); err != nil {
+		cfg.Logger.Warnf("Failed to clear memoized lookups: %v", err)
+	}
+
+	var freed int64
+	for _, e := range removed {
+		freed += e.Size
+	}
+	if _, err := fmt.Fprintf(cfg.OutputWriter, "Removed %d entries, %s.\n", len(removed), formatSize(freed)); err != nil {
+		return err
+	}
+	return clearErr
+}
+
+// HandleCacheInvalidate is the handler for the 'cache invalidate' subcommand.
+// It drops memoized remote lookups (fetched files, OBS listings, PR lists) whose key starts
+// with prefix, or all of them if prefix is empty. Cloned repositories are not affected.
+func HandleCacheInvalidate(cfg *config.Config, prefix string) error {
+	c, err := cache.New(cfg.CacheDir)
+	if err != nil {
+		return err
+	}
+	removed, err := c.Invalidate(prefix)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(cfg.OutputWriter, "Invalidated %d cached lookups.\n", removed); err != nil {
+		return err
+	}
+	return nil
+}
+
+// autoPruneCache enforces the configured cache limits after commands that write to the cache.
+// Entries listed in keep are never evicted.
+func autoPruneCache(cfg *config.Config, keep []string) error {
+	if cfg.CacheMaxSizeMB <= 0 && cfg.CacheMaxAgeDays <= 0 {
+		return nil
+	}
+	c, err := cache.New(cfg.CacheDir)
+	if err != nil {
+		return err
+	}
+	evicted, err := c.Prune(pruneOptions(cfg.CacheMaxSizeMB, cfg.CacheMaxAgeDays, keep, false))
+	for _, e := range evicted {
+		cfg.Logger.Infof("Evicted %s (%s) from the cache.", entryName(e), formatSize(e.Size))
+	}
+	return err
+}
+
+// entryName returns the name of an evicted entry for messages.
+func entryName(e cache.Entry) string {
+	if e.Lookup {
+		return "lookup " + e.Name
+	}
+	return e.Name
+}
+
+// pruneOptions converts the configured limits into cache.PruneOptions.
+func pruneOptions(maxSizeMB, maxAgeDays int, keep []string, dryRun bool) cache.PruneOptions {
+	return cache.PruneOptions{
+		MaxSize: int64(maxSizeMB) << 20,
+		MaxAge:  time.Duration(maxAgeDays) * 24 * time.Hour,
+		Keep:    keep,
+		DryRun:  dryRun,
+	}
+}
+
+// formatSize formats a size in bytes for humans, using binary units.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gyr/relx-go/pkg/cache"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/logging"
+)
+
+// newCacheConfig returns a config with a cache containing two entries of 1 MiB,
+// "old" last used 10 days ago and "new" last used now.
+func newCacheConfig(t *testing.T, out *bytes.Buffer) *config.Config {
+	t.Helper()
+	cacheDir := t.TempDir()
+	for name, age := range map[string]time.Duration{"old": 10 * 24 * time.Hour, "new": 0} {
+		dir := filepath.Join(cacheDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create cache entry: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "data"), make([]byte, 1<<20), 0644); err != nil {
+			t.Fatalf("Failed to write cache entry: %v", err)
+		}
+		lastUse := time.Now().Add(-age)
+		if err := os.Chtimes(dir, lastUse, lastUse); err != nil {
+			t.Fatalf("Failed to set cache entry time: %v", err)
+		}
+	}
+	return &config.Config{
+		Logger:       logging.NewLogger(logging.LevelDebug),
+		OutputWriter: out,
+		CacheDir:     cacheDir,
+	}
+}
+
+func TestHandleCacheList(t *testing.T) {
+	var out bytes.Buffer
+	cfg := newCacheConfig(t, &out)
+
+	if err := HandleCacheList(cfg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "new ") || !strings.HasPrefix(lines[2], "old ") {
+		t.Errorf("Unexpected listing:\n%s", out.String())
+	}
+	if !strings.Contains(lines[1], "1.0 MiB") {
+		t.Errorf("Expected entry size in listing, got %q", lines[1])
+	}
+}
+
+func TestHandleCacheDiskUsage(t *testing.T) {
+	var out bytes.Buffer
+	cfg := newCacheConfig(t, &out)
+	cfg.CacheMaxSizeMB = 1024
+
+	if err := HandleCacheDiskUsage(cfg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Total: 2.0 MiB in "+cfg.CacheDir+" (limit: 1.0 GiB)") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
+
+func TestHandleCacheDiskUsageCountsLookups(t *testing.T) {
+	var out bytes.Buffer
+	cfg := newCacheConfig(t, &out)
+	c, _ := cache.New(cfg.CacheDir)
+	if err := c.Put("osc/ls/project", make([]byte, 1<<20), 0); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	if err := HandleCacheDiskUsage(cfg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "1.0 MiB  (memoized lookups)") || !strings.Contains(out.String(), "Total: 3.0 MiB") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
+
+func TestHandleCachePrune(t *testing.T) {
+	t.Run("ConfiguredMaxAge", func(t *testing.T) {
+		var out bytes.Buffer
+		cfg := newCacheConfig(t, &out)
+		cfg.CacheMaxAgeDays = 7
+
+		if err := HandleCachePrune(cfg, -1, -1, false); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(out.String(), "Removed old") || strings.Contains(out.String(), "Removed new") {
+			t.Errorf("Unexpected output:\n%s", out.String())
+		}
+		if _, err := os.Stat(filepath.Join(cfg.CacheDir, "old")); !os.IsNotExist(err) {
+			t.Error("Expected old entry to be removed")
+		}
+	})
+
+	t.Run("DryRunWithFlagLimit", func(t *testing.T) {
+		var out bytes.Buffer
+		cfg := newCacheConfig(t, &out)
+
+		if err := HandleCachePrune(cfg, 1, -1, true); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(out.String(), "Would remove 1 entries, 1.0 MiB.") {
+			t.Errorf("Unexpected output:\n%s", out.String())
+		}
+		if _, err := os.Stat(filepath.Join(cfg.CacheDir, "old")); err != nil {
+			t.Errorf("Dry run removed an entry: %v", err)
+		}
+	})
+
+	t.Run("EvictsLookups", func(t *testing.T) {
+		var out bytes.Buffer
+		cfg := newCacheConfig(t, &out)
+		c, _ := cache.New(cfg.CacheDir)
+		if err := c.Put("osc/ls/project", make([]byte, 1<<20), 0); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+
+		if err := HandleCachePrune(cfg, 1, -1, false); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(out.String(), "Removed old") || !strings.Contains(out.String(), "Removed lookup osc/ls/project") {
+			t.Errorf("Unexpected output:\n%s", out.String())
+		}
+		if keys, _ := c.Keys(""); len(keys) != 0 {
+			t.Errorf("Expected the lookup to be evicted, got %v", keys)
+		}
+	})
+
+	t.Run("NoLimits", func(t *testing.T) {
+		var out bytes.Buffer
+		cfg := newCacheConfig(t, &out)
+
+		if err := HandleCachePrune(cfg, -1, -1, false); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(out.String(), "nothing to prune") {
+			t.Errorf("Unexpected output:\n%s", out.String())
+		}
+	})
+}
+
+func TestHandleCacheClear(t *testing.T) {
+	var out bytes.Buffer
+	cfg := newCacheConfig(t, &out)
+
+	if err := HandleCacheClear(cfg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Removed 2 entries, 2.0 MiB.") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
+
+func TestHandleCacheInvalidate(t *testing.T) {
+	var out bytes.Buffer
+	cfg := newCacheConfig(t, &out)
+
+	c, err := cache.New(cfg.CacheDir)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	for _, key := range []string{"osc/ls/api/project", "osc/ls-b/api/project/pkg/repo", "gitea/pr-list/repo/main/reviewer"} {
+		if err := c.Put(key, []byte(key), time.Hour); err != nil {
+			t.Fatalf("Failed to store %s: %v", key, err)
+		}
+	}
+
+	if err := HandleCacheInvalidate(cfg, "osc/"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Invalidated 2 cached lookups.") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+	if keys, _ := c.Keys(""); len(keys) != 1 {
+		t.Errorf("Expected one remaining key, got %v", keys)
+	}
+
+	// Clearing the cache drops the remaining lookups as well, but keeps them out of the entry count.
+	out.Reset()
+	if err := HandleCacheClear(cfg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Removed 2 entries") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+	if keys, _ := c.Keys(""); len(keys) != 0 {
+		t.Errorf("Expected no remaining keys, got %v", keys)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	testCases := map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1536:          "1.5 KiB",
+		5 << 30:       "5.0 GiB",
+		3<<40 + 1<<39: "3.5 TiB",
+	}
+	for size, want := range testCases {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
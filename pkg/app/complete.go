@@ -0,0 +1,103 @@
+package app
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gyr/relx-go/pkg/cache"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/gitutils"
+)
+
+// The completers below provide candidates for shell completion. They only read what is
+// already known locally, the configuration and the cache, so they are fast and never
+// fail: errors just mean fewer candidates.
+
+// CompleteProjects returns the OBS projects whose packages were listed before with the
+// configured OBS API, taken from the memoized 'osc ls' lookups in the cache.
+func CompleteProjects(cfg *config.Config) []string {
+	prefix := "osc/ls/" + cfg.OBSAPIURL + "/"
+	var projects []string
+	for _, key := range cachedKeys(cfg, prefix) {
+		projects = append(projects, strings.TrimPrefix(key, prefix))
+	}
+	return projects
+}
+
+// CompleteRepositories returns the names of the configured repositories and of the
+// repositories in the cache.
+func CompleteRepositories(cfg *config.Config) []string {
+	var names []string
+	if repos, err := gitutils.ResolveRepositories(cfg); err == nil {
+		for _, repo := range repos {
+			names = append(names, repo.Name)
+		}
+	}
+	if c, ok := openCache(cfg); ok {
+		if entries, err := c.Entries(); err == nil {
+			for _, entry := range entries {
+				if c.Has(entry.Name, ".git") {
+					names = append(names, entry.Name)
+				}
+			}
+		}
+	}
+	return dedupe(names)
+}
+
+// CompletePullRequestRepositories returns the Gitea repositories, e.g. "products/SLFO",
+// of the configured review targets and those whose pull requests were listed before,
+// taken from the memoized PR lists in the cache (see pr_list_cache_ttl_seconds).
+func CompletePullRequestRepositories(cfg *config.Config) []string {
+	const prefix = "gitea/pr-list/"
+	var repos []string
+	for _, target := range cfg.ReviewTargets {
+		repos = append(repos, target.Repository)
+	}
+	for _, key := range cachedKeys(cfg, prefix) {
+		// Keys are gitea/pr-list/<owner>/<repository>/<branch>/<reviewer>.
+		parts := strings.SplitN(strings.TrimPrefix(key, prefix), "/", 3)
+		if len(parts) == 3 {
+			repos = append(repos, parts[0]+"/"+parts[1])
+		}
+	}
+	return dedupe(repos)
+}
+
+// cachedKeys returns the keys of the memoized lookups starting with prefix.
+func cachedKeys(cfg *config.Config, prefix string) []string {
+	c, ok := openCache(cfg)
+	if !ok {
+		return nil
+	}
+	keys, err := c.Keys(prefix)
+	if err != nil {
+		return nil
+	}
+	return keys
+}
+
+// openCache opens the cache if it exists. Unlike cache.New, it doesn't create it.
+func openCache(cfg *config.Config) (*cache.Cache, bool) {
+	if cfg.CacheDir == "" {
+		return nil, false
+	}
+	if _, err := os.Stat(cfg.CacheDir); err != nil {
+		return nil, false
+	}
+	c, err := cache.New(cfg.CacheDir)
+	return c, err == nil
+}
+
+// dedupe returns the sorted unique values.
+func dedupe(values []string) []string {
+	sort.Strings(values)
+	var unique []string
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gyr/relx-go/pkg/cache"
+	"github.com/gyr/relx-go/pkg/config"
+)
+
+func TestCompleters(t *testing.T) {
+	cacheDir := t.TempDir()
+	c, err := cache.New(cacheDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	for _, key := range []string{
+		"osc/ls/https://api.example.com/SUSE:SLFO:Main",
+		"osc/ls/https://api.example.com/SUSE:SLFO:Products:SLES:16.0",
+		"osc/ls/https://other.example.com/openSUSE:Factory",
+		"osc/ls-b/https://api.example.com/SUSE:SLFO:Main/sles/standard",
+		"gitea/pr-list/products/SLFO/main/reviewer",
+		"gitea/pr-list/products/SLFO/16.0/reviewer",
+		"gitea/pr-list/pool/kernel/main/reviewer",
+	} {
+		if err := c.Put(key, []byte("data"), time.Hour); err != nil {
+			t.Fatalf("Failed to store %s: %v", key, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(cacheDir, "cached", ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create cached clone: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(cacheDir, "not-a-clone"), 0755); err != nil {
+		t.Fatalf("Failed to create cache entry: %v", err)
+	}
+
+	cfg := &config.Config{
+		CacheDir:      cacheDir,
+		OBSAPIURL:     "https://api.example.com",
+		RepoURL:       "https://example.com/products/SLFO.git",
+		Repositories:  []config.Repository{{Name: "sles", URL: "https://example.com/products/SLES.git", Branch: "16.0"}},
+		ReviewTargets: []config.ReviewTarget{{Repository: "products/SLES", Branch: "16.0"}, {Repository: "products/SLFO", Branch: "main"}},
+	}
+
+	tests := []struct {
+		name     string
+		complete func(cfg *config.Config) []string
+		want     []string
+	}{
+		{"Projects", CompleteProjects, []string{"SUSE:SLFO:Main", "SUSE:SLFO:Products:SLES:16.0"}},
+		{"Repositories", CompleteRepositories, []string{"SLFO", "cached", "sles"}},
+		{"PullRequestRepositories", CompletePullRequestRepositories, []string{"pool/kernel", "products/SLES", "products/SLFO"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.complete(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("MissingCache", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing")
+		if got := CompleteProjects(&config.Config{CacheDir: missing}); got != nil {
+			t.Errorf("Expected no candidates, got %q", got)
+		}
+		if _, err := os.Stat(missing); !os.IsNotExist(err) {
+			t.Errorf("Expected completion not to create the cache directory")
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/gyr/relx-go/pkg/config"
+)
+
+// HandleConfigShow is the handler for the 'config show' subcommand.
+// It prints the effective configuration as YAML, including the selected profile. With showOrigin, every setting is
+// annotated with where its value came from (a file location, an environment variable,
+// --set or the built-in default).
+func HandleConfigShow(cfg *config.Config, showOrigin bool) error {
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	if cfg.Profile != "" {
+		doc.HeadComment = "Profile: " + cfg.Profile
+	}
+
+	if showOrigin {
+		for i := 0; i+1 < len(doc.Content); i += 2 {
+			key, value := doc.Content[i], doc.Content[i+1]
+			origin, found := cfg.Origins[key.Value]
+			if !found {
+				origin = "not set"
+			}
+			// Comments on scalar and empty values stay on the same line; block values get it on the key.
+			if value.Kind == yaml.ScalarNode || len(value.Content) == 0 {
+				value.LineComment = origin
+			} else {
+				key.LineComment = origin
+			}
+		}
+	}
+
+	encoder := yaml.NewEncoder(cfg.OutputWriter)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// HandleConfigValidate is the handler for the 'config validate' subcommand.
+// It reports all problems in the configuration. If command is not empty, it also reports
+// the settings that the subcommand requires but that are not set.
+func HandleConfigValidate(cfg *config.Config, command string) error {
+	var problems config.ValidationErrors
+	for _, err := range []error{cfg.Validate(), cfg.CheckRequired(command)} {
+		var errs config.ValidationErrors
+		if errors.As(err, &errs) {
+			problems = append(problems, errs...)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if len(problems) == 0 {
+		if _, err := fmt.Fprintln(cfg.OutputWriter, "Configuration is valid."); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	for _, problem := range problems {
+		if _, err := fmt.Fprintln(cfg.OutputWriter, problem.Error()); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("%d problem(s) found in the configuration", len(problems))
+}
+
+// HandleConfigInit is the handler for the 'config init' subcommand.
+// It asks for the OBS API URL, the product repository and the reviewer on in, offering the
+// current settings as defaults, and writes a commented starter configuration to path
+// (the user configuration file if empty). An existing file is only replaced with force.
+func HandleConfigInit(cfg *config.Config, in io.Reader, path string, force bool) error {
+	if path == "" {
+		var err error
+		if path, err = config.UserConfigPath(); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists; use -f to overwrite it", path)
+	}
+
+	starter, err := config.Default()
+	if err != nil {
+		return err
+	}
+	starter.OperationTimeoutSeconds = cfg.OperationTimeoutSeconds
+
+	scanner := bufio.NewScanner(in)
+	for _, q := range []struct {
+		prompt string
+		key    string
+		value  *string
+		def    string
+	}{
+		{"OBS API URL", "obs_api_url", &starter.OBSAPIURL, cfg.OBSAPIURL},
+		{"Product repository URL", "repo_url", &starter.RepoURL, cfg.RepoURL},
+		{"Product repository branch", "repo_branch", &starter.RepoBranch, cfg.RepoBranch},
+		{"Pull request reviewer", "pr_reviewer", &starter.PRReviewer, cfg.PRReviewer},
+	} {
+		if err := askSetting(cfg, scanner, starter, q.prompt, q.key, q.value, q.def); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := config.WriteStarter(&buf, starter); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if _, err := fmt.Fprintf(cfg.OutputWriter, "Wrote configuration to %s.\n", path); err != nil {
+		return err
+	}
+	return nil
+}
+
+// askSetting prompts for a setting until the answer passes validation. An empty answer
+// keeps def; "-" leaves the setting empty.
+func askSetting(cfg *config.Config, scanner *bufio.Scanner, candidate *config.Config, prompt, key string, value *string, def string) error {
+	for {
+		if def != "" {
+			if _, err := fmt.Fprintf(cfg.OutputWriter, "%s [%s]: ", prompt, def); err != nil {
+				return err
+			}
+		} else if _, err := fmt.Fprintf(cfg.OutputWriter, "%s (empty to skip): ", prompt); err != nil {
+			return err
+		}
+
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return fmt.Errorf("failed to read answer: %w", err)
+			}
+			return fmt.Errorf("no answer for %s", key)
+		}
+		answer := strings.TrimSpace(scanner.Text())
+		switch answer {
+		case "":
+			answer = def
+		case "-":
+			answer = ""
+		}
+
+		*value = answer
+		problem := settingProblem(candidate, key)
+		if problem == "" {
+			return nil
+		}
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "Invalid %s: %s\n", key, problem); err != nil {
+			return err
+		}
+	}
+}
+
+// settingProblem returns the validation problem of a single setting of cfg, if any.
+func settingProblem(cfg *config.Config, key string) string {
+	var problems config.ValidationErrors
+	if errors.As(cfg.Validate(), &problems) {
+		for _, p := range problems {
+			if p.Key == key {
+				return p.Message
+			}
+		}
+	}
+	return ""
+}
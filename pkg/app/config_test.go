@@ -0,0 +1,157 @@
+package app
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/logging"
+)
+
+func TestHandleConfigShow(t *testing.T) {
+	newConfig := func(out *bytes.Buffer) *config.Config {
+		return &config.Config{
+			Logger:                  logging.NewLogger(logging.LevelDebug),
+			OutputWriter:            out,
+			RepoURL:                 "https://example.com/products/SLFO.git",
+			BinaryFilterPatterns:    []string{"*.iso"},
+			OperationTimeoutSeconds: 300,
+			Origins: map[string]string{
+				"repo_url":                  "/etc/relx-go/config.yaml:3:1",
+				"binary_filter_patterns":    "env RELX_GO_BINARY_FILTER_PATTERNS",
+				"operation_timeout_seconds": config.OriginDefault,
+			},
+		}
+	}
+
+	t.Run("WithOrigin", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := HandleConfigShow(newConfig(&out), true); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, want := range []string{
+			"repo_url: https://example.com/products/SLFO.git # /etc/relx-go/config.yaml:3:1\n",
+			"binary_filter_patterns: # env RELX_GO_BINARY_FILTER_PATTERNS\n  - '*.iso'\n",
+			"operation_timeout_seconds: 300 # default\n",
+			"pr_reviewer: \"\" # not set\n",
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Output missing %q. Got:\n%s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("WithoutOrigin", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := HandleConfigShow(newConfig(&out), false); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if strings.Contains(out.String(), "#") {
+			t.Errorf("Expected no origin comments. Got:\n%s", out.String())
+		}
+	})
+}
+
+func TestHandleConfigValidate(t *testing.T) {
+	newConfig := func(out *bytes.Buffer) *config.Config {
+		return &config.Config{
+			Logger:                  logging.NewLogger(logging.LevelDebug),
+			OutputWriter:            out,
+			RepoURL:                 "https://example.com/products/SLFO.git",
+			OperationTimeoutSeconds: 300,
+		}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := HandleConfigValidate(newConfig(&out), "cat"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if out.String() != "Configuration is valid.\n" {
+			t.Errorf("Unexpected output: %q", out.String())
+		}
+	})
+
+	t.Run("Problems", func(t *testing.T) {
+		var out bytes.Buffer
+		cfg := newConfig(&out)
+		cfg.OBSAPIURL = "api.example.com"
+		cfg.Origins = map[string]string{"obs_api_url": "config.yaml:4:1"}
+
+		err := HandleConfigValidate(cfg, "bugowner")
+		if err == nil || !strings.Contains(err.Error(), "2 problem(s)") {
+			t.Errorf("Expected 2 problems, got %v", err)
+		}
+		want := "config.yaml:4:1: obs_api_url: URL \"api.example.com\" must start with http:// or https://\n" +
+			"repo_branch: is required by 'bugowner'\n"
+		if out.String() != want {
+			t.Errorf("Output mismatch:\nGot:\n%s\nWant:\n%s", out.String(), want)
+		}
+	})
+}
+
+func TestHandleConfigInit(t *testing.T) {
+	newConfig := func(out *bytes.Buffer) *config.Config {
+		return &config.Config{
+			Logger:                  logging.NewLogger(logging.LevelDebug),
+			OutputWriter:            out,
+			RepoURL:                 "https://example.com/products/SLFO.git",
+			OperationTimeoutSeconds: 300,
+		}
+	}
+	path := filepath.Join(t.TempDir(), "relx-go", "config.yaml")
+
+	t.Run("WritesConfig", func(t *testing.T) {
+		var out bytes.Buffer
+		// An invalid OBS URL is asked again, the repository default is kept, the branch is
+		// entered and the reviewer skipped.
+		in := strings.NewReader("api.example.com\nhttps://api.example.com\n\nmain\n\n")
+		if err := HandleConfigInit(newConfig(&out), in, path, false); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(out.String(), "Invalid obs_api_url") {
+			t.Errorf("Expected the invalid URL to be reported. Got:\n%s", out.String())
+		}
+
+		cfg, err := config.LoadConfig(path)
+		if err != nil {
+			t.Fatalf("Failed to load the written configuration: %v", err)
+		}
+		if cfg.OBSAPIURL != "https://api.example.com" || cfg.RepoURL != "https://example.com/products/SLFO.git" || cfg.RepoBranch != "main" || cfg.PRReviewer != "" {
+			t.Errorf("Unexpected configuration written: %+v", cfg)
+		}
+	})
+
+	t.Run("RefusesToOverwrite", func(t *testing.T) {
+		var out bytes.Buffer
+		err := HandleConfigInit(newConfig(&out), strings.NewReader(""), path, false)
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("Expected an error for an existing file, got %v", err)
+		}
+	})
+
+	t.Run("Force", func(t *testing.T) {
+		var out bytes.Buffer
+		in := strings.NewReader("-\n-\n-\nreviewer\n")
+		if err := HandleConfigInit(newConfig(&out), in, path, true); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		cfg, err := config.LoadConfig(path)
+		if err != nil {
+			t.Fatalf("Failed to load the written configuration: %v", err)
+		}
+		if cfg.RepoURL != "" || cfg.PRReviewer != "reviewer" {
+			t.Errorf("Unexpected configuration written: %+v", cfg)
+		}
+	})
+
+	t.Run("EndOfInput", func(t *testing.T) {
+		var out bytes.Buffer
+		err := HandleConfigInit(newConfig(&out), strings.NewReader(""), filepath.Join(t.TempDir(), "config.yaml"), false)
+		if err == nil || !strings.Contains(err.Error(), "no answer for obs_api_url") {
+			t.Errorf("Expected an error at end of input, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,296 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/gitea"
+	"github.com/gyr/relx-go/pkg/gitutils"
+	"github.com/gyr/relx-go/pkg/timeout"
+)
+
+// doctorTool is an external tool relx-go depends on.
+type doctorTool struct {
+	name    string
+	pkg     string // The openSUSE package that provides it
+	usedFor string
+}
+
+// doctorTools are the external tools checked by 'doctor', in the order they are reported.
+var doctorTools = []doctorTool{
+	{name: "git", pkg: "git", usedFor: "cloning and reading the product repositories"},
+	{name: "tar", pkg: "tar", usedFor: "extracting files fetched with 'git archive'"},
+	{name: "osc", pkg: "osc", usedFor: "listing OBS packages and artifacts"},
+	{name: "git-obs", pkg: "osc", usedFor: "listing, showing and approving pull requests"},
+}
+
+// checkResult is the outcome of a single 'doctor' check.
+type checkResult struct {
+	name    string
+	detail  string // What was found, e.g. the version of a tool
+	err     error
+	fix     string // How to fix a failed check
+	skipped bool   // The check could not run, e.g. because its tool is missing
+}
+
+// HandleDoctor is the handler for the 'doctor' subcommand.
+// It checks that the external tools and the viewer of pull requests are installed, that
+// osc and git-obs are logged in, that the product repository can be reached, that the
+// cache directory is writable and that the configuration is valid, and prints how to fix
+// each failed check.
+// If any check failed, the error is a *PartialError.
+func HandleDoctor(ctx context.Context, cfg *config.Config, runner command.Runner) error {
+	cfg.Logger.Infof("Handling doctor request")
+
+	var results []checkResult
+	installed := make(map[string]bool)
+	for _, tool := range doctorTools {
+		result := checkTool(ctx, cfg, runner, tool)
+		installed[tool.name] = result.err == nil
+		results = append(results, result)
+	}
+	results = append(results,
+		checkViewer(cfg),
+		checkOBSLogin(ctx, cfg, runner, installed["osc"]),
+		checkGiteaLogin(ctx, cfg, runner, installed["git-obs"]),
+		checkRepository(ctx, cfg, runner, installed["git"]),
+		checkCacheDir(cfg),
+		checkConfig(cfg),
+	)
+
+	var failed []error
+	var checked int
+	for _, result := range results {
+		status := "ok"
+		switch {
+		case result.skipped:
+			status = "skipped"
+		case result.err != nil:
+			status = "FAILED"
+			failed = append(failed, result.err)
+		}
+		if !result.skipped {
+			checked++
+		}
+
+		detail := result.detail
+		if result.err != nil {
+			detail = result.err.Error()
+		}
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "%-8s%s: %s\n", status, result.name, detail); err != nil {
+			return err
+		}
+		if result.err != nil && result.fix != "" {
+			if _, err := fmt.Fprintf(cfg.OutputWriter, "        fix: %s\n", result.fix); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return partialf(failed, checked, "%d of %d checks failed", len(failed), checked)
+	}
+	if _, err := fmt.Fprintf(cfg.OutputWriter, "\nAll %d checks passed.\n", checked); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runCheck runs a command of a check, bounded by the operation timeout. The result is
+// never nil, so that its output can be reported even if the command could not be started.
+func runCheck(ctx context.Context, cfg *config.Config, runner command.Runner, name string, args ...string) (*command.Result, error) {
+	timeoutCtx, op := timeout.Start(ctx, cfg, timeout.DoctorCheck)
+	defer op.Stop()
+
+	result, err := runner.Exec(timeoutCtx, "" /* workDir */, name, args...)
+	if result == nil {
+		result = &command.Result{}
+	}
+	return result, op.Err(err)
+}
+
+// failureOutput returns the first line of the error output of a failed check command,
+// or of its standard output if it printed no errors.
+func failureOutput(result *command.Result) string {
+	if line := firstLine(result.Stderr); line != "" {
+		return line
+	}
+	return firstLine(result.Stdout)
+}
+
+// checkTool checks that tool is on PATH and reports its version.
+func checkTool(ctx context.Context, cfg *config.Config, runner command.Runner, tool doctorTool) checkResult {
+	result := checkResult{name: tool.name}
+	output, err := runCheck(ctx, cfg, runner, tool.name, "--version")
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		result.err = fmt.Errorf("not found on PATH (needed for %s)", tool.usedFor)
+		result.fix = fmt.Sprintf("install it, e.g. with 'sudo zypper install %s'", tool.pkg)
+	case err != nil:
+		result.err = fmt.Errorf("'%s --version' failed: %w. Output: %s", tool.name, err, failureOutput(output))
+		result.fix = fmt.Sprintf("reinstall it, e.g. with 'sudo zypper install --force %s'", tool.pkg)
+	default:
+		result.detail = firstLine(output.Stdout)
+	}
+	return result
+}
+
+// checkViewer reports the viewer that shows pull requests in 'review'. It fails if the
+// configured viewer is not available.
+func checkViewer(cfg *config.Config) checkResult {
+	result := checkResult{name: "PR viewer"}
+	viewer, args, err := gitea.ResolveViewer(cfg)
+	if err != nil {
+		result.err = err
+		result.fix = fmt.Sprintf("install it, or set pr_viewer to auto to use %s", viewer)
+		return result
+	}
+	if args == nil {
+		result.detail = "none (the output of git-obs is printed as it is)"
+	} else {
+		result.detail = strings.Join(args, " ")
+	}
+	return result
+}
+
+// checkOBSLogin checks that osc has credentials for the configured OBS API.
+func checkOBSLogin(ctx context.Context, cfg *config.Config, runner command.Runner, installed bool) checkResult {
+	apiURL := cfg.OBSAPIURL
+	if apiURL == "" {
+		apiURL = "the default API of osc"
+	}
+	result := checkResult{name: "OBS login"}
+	if !installed {
+		result.skipped, result.detail = true, "osc is not installed"
+		return result
+	}
+
+	osc := []string{"osc"}
+	if cfg.OBSAPIURL != "" {
+		osc = append(osc, "-A", cfg.OBSAPIURL)
+	}
+	output, err := runCheck(ctx, cfg, runner, osc[0], append(osc[1:], "whois")...)
+	if err != nil {
+		result.err = fmt.Errorf("'osc whois' failed for %s: %w. Output: %s", apiURL, err, failureOutput(output))
+		result.fix = fmt.Sprintf("run '%s ls' once to store your credentials in ~/.config/osc/oscrc, and check that obs_api_url is right", strings.Join(osc, " "))
+		return result
+	}
+	result.detail = fmt.Sprintf("%s on %s", firstLine(output.Stdout), apiURL)
+	return result
+}
+
+// checkGiteaLogin checks that git-obs has a working Gitea login.
+func checkGiteaLogin(ctx context.Context, cfg *config.Config, runner command.Runner, installed bool) checkResult {
+	result := checkResult{name: "Gitea login"}
+	if !installed {
+		result.skipped, result.detail = true, "git-obs is not installed"
+		return result
+	}
+
+	// Only the standard output is JSON; git-obs may print warnings on standard error.
+	output, err := runCheck(ctx, cfg, runner, "git-obs", "api", "/user")
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err == nil {
+		err = json.Unmarshal(output.Stdout, &user)
+	}
+	if err != nil || user.Login == "" {
+		if err == nil {
+			err = errors.New("no user in the response")
+		}
+		result.err = fmt.Errorf("'git-obs api /user' failed: %w. Output: %s", err, failureOutput(output))
+		result.fix = "add a login with 'git-obs login add' (see 'git-obs login add --help') and make it the default"
+		return result
+	}
+	result.detail = "logged in as " + user.Login
+	if cfg.PRReviewer != "" && cfg.PRReviewer != user.Login {
+		result.detail += fmt.Sprintf(" (pr_reviewer is %s)", cfg.PRReviewer)
+	}
+	return result
+}
+
+// checkRepository checks that the product repository and its branch can be reached.
+func checkRepository(ctx context.Context, cfg *config.Config, runner command.Runner, installed bool) checkResult {
+	result := checkResult{name: "product repository"}
+	switch {
+	case cfg.RepoURL == "":
+		result.skipped, result.detail = true, "repo_url is not set"
+		return result
+	case !installed:
+		result.skipped, result.detail = true, "git is not installed"
+		return result
+	}
+
+	tip, err := gitutils.RemoteBranchHead(ctx, cfg, runner, cfg.RepoURL, cfg.RepoBranch)
+	if err != nil {
+		result.err = err
+		result.fix = "check repo_url and repo_branch, and that your SSH key or git credentials give access to the repository"
+		return result
+	}
+	branch := cfg.RepoBranch
+	if branch == "" {
+		branch = "HEAD"
+	}
+	result.detail = fmt.Sprintf("%s %s at %s", cfg.RepoURL, branch, shortCommit(tip))
+	return result
+}
+
+// checkCacheDir checks that the cache directory can be created and written to.
+func checkCacheDir(cfg *config.Config) checkResult {
+	result := checkResult{name: "cache directory"}
+	fix := fmt.Sprintf("make %s writable, or set cache_dir to a writable directory", cfg.CacheDir)
+	if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
+		result.err, result.fix = err, fix
+		return result
+	}
+	file, err := os.CreateTemp(cfg.CacheDir, ".doctor-*")
+	if err != nil {
+		result.err, result.fix = err, fix
+		return result
+	}
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+	result.detail = cfg.CacheDir + " is writable"
+	return result
+}
+
+// checkConfig checks the configuration like 'config validate'.
+func checkConfig(cfg *config.Config) checkResult {
+	result := checkResult{name: "configuration"}
+	if err := cfg.Validate(); err != nil {
+		var problems config.ValidationErrors
+		if errors.As(err, &problems) {
+			err = fmt.Errorf("%d problem(s) found", len(problems))
+		}
+		result.err = err
+		result.fix = "run 'relx-go config validate' to list the problems"
+		if len(cfg.Files) > 0 {
+			result.fix += " and fix them in " + strings.Join(cfg.Files, ", ")
+		}
+		return result
+	}
+	if len(cfg.Files) == 0 {
+		result.detail = "valid (no configuration file, see 'relx-go config init')"
+	} else {
+		result.detail = "valid (" + strings.Join(cfg.Files, ", ") + ")"
+	}
+	return result
+}
+
+// firstLine returns the first non-empty line of a command's output.
+func firstLine(output []byte) string {
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/command/commandtest"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/logging"
+)
+
+// doctorRunner answers the commands of the doctor checks. Tools in missing are not
+// installed; commands in failing (e.g. "osc whois") fail.
+func doctorRunner(missing, failing map[string]bool) *commandtest.MockRunner {
+	return &commandtest.MockRunner{
+		ExecFunc: func(ctx context.Context, workDir, name string, args ...string) (*command.Result, error) {
+			if missing[name] {
+				return &command.Result{ExitCode: -1}, &exec.Error{Name: name, Err: exec.ErrNotFound}
+			}
+			cmdline := name + " " + args[len(args)-1]
+			if failing[cmdline] {
+				return &command.Result{
+					Stdout:   []byte("Retrying...\n"),
+					Stderr:   []byte("Server returned an error: HTTP Error 401: Unauthorized"),
+					ExitCode: 1,
+				}, errors.New("exit status 1")
+			}
+			switch {
+			case args[len(args)-1] == "--version":
+				return stdout(name + " version 1.0\n"), nil
+			case cmdline == "osc whois":
+				return stdout(`alice: "Alice" <alice@example.com>` + "\n"), nil
+			case cmdline == "git-obs /user":
+				// Warnings on standard error must not break the JSON on standard output.
+				return &command.Result{Stdout: []byte(`{"login": "alice"}`), Stderr: []byte("Warning: the login has no SSH key\n")}, nil
+			case name == "git" && args[0] == "ls-remote":
+				return stdout("0123456789abcdef0123456789abcdef01234567\trefs/heads/main\n"), nil
+			}
+			return &command.Result{ExitCode: 1}, errors.New("unexpected command")
+		},
+	}
+}
+
+// stdout returns the result of a successful command that printed output.
+func stdout(output string) *command.Result {
+	return &command.Result{Stdout: []byte(output)}
+}
+
+func TestHandleDoctor(t *testing.T) {
+	newConfig := func(out *bytes.Buffer) *config.Config {
+		return &config.Config{
+			Logger:                  logging.NewLogger(logging.LevelDebug),
+			OutputWriter:            out,
+			CacheDir:                t.TempDir(),
+			OBSAPIURL:               "https://api.opensuse.org",
+			RepoURL:                 "https://src.opensuse.org/products/SLFO.git",
+			RepoBranch:              "main",
+			OperationTimeoutSeconds: 60,
+		}
+	}
+
+	t.Run("AllPassing", func(t *testing.T) {
+		var out bytes.Buffer
+		cfg := newConfig(&out)
+		cfg.PRViewer = "none"
+		if err := HandleDoctor(context.Background(), cfg, doctorRunner(nil, nil)); err != nil {
+			t.Fatalf("Expected no error, got %v\n%s", err, out.String())
+		}
+		for _, want := range []string{
+			"ok      git: git version 1.0",
+			"ok      PR viewer: none",
+			"ok      OBS login: alice: \"Alice\" <alice@example.com> on https://api.opensuse.org",
+			"ok      Gitea login: logged in as alice",
+			"ok      product repository: https://src.opensuse.org/products/SLFO.git main at 0123456789ab",
+			"ok      cache directory:",
+			"All 10 checks passed.",
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Output missing %q:\n%s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		var out bytes.Buffer
+		t.Setenv("PAGER", "")
+		cfg := newConfig(&out)
+		cfg.PRViewer = "pager"
+		runner := doctorRunner(map[string]bool{"git-obs": true}, map[string]bool{"osc whois": true})
+		err := HandleDoctor(context.Background(), cfg, runner)
+
+		var partial *PartialError
+		if !errors.As(err, &partial) || partial.Failed != 3 || partial.Total != 9 {
+			t.Fatalf("Expected 3 of 9 checks to fail, got %v\n%s", err, out.String())
+		}
+		for _, want := range []string{
+			"FAILED  git-obs: not found on PATH",
+			"fix: install it, e.g. with 'sudo zypper install osc'",
+			`FAILED  PR viewer: gitea: pr_viewer "pager" is not available: $PAGER is not set`,
+			"FAILED  OBS login: 'osc whois' failed for https://api.opensuse.org: exit status 1. Output: Server returned an error: HTTP Error 401: Unauthorized",
+			"fix: run 'osc -A https://api.opensuse.org ls' once",
+			"skipped Gitea login: git-obs is not installed",
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Output missing %q:\n%s", want, out.String())
+			}
+		}
+	})
+}
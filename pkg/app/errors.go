@@ -0,0 +1,54 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is wrapped by the errors of handlers that found nothing to show or to work
+// on, e.g. no pull requests pending review. The handlers explain this on the output, so
+// callers can treat it as a result rather than a failure.
+var ErrNotFound = errors.New("not found")
+
+// PartialError is returned by handlers that work on several items, e.g. repositories or
+// pull requests, when some of them failed. The results of the others were reported.
+// It unwraps to the errors of the failed items, so that callers can tell why all items
+// failed, e.g. because an external tool is broken.
+type PartialError struct {
+	Failed  int // Number of items that failed
+	Total   int // Number of items
+	Message string
+	Errs    []error // Errors of the failed items
+}
+
+func (e *PartialError) Error() string {
+	return e.Message
+}
+
+func (e *PartialError) Unwrap() []error {
+	return e.Errs
+}
+
+// partialf returns a PartialError for the errors of the failed of total items with a
+// formatted message.
+func partialf(errs []error, total int, format string, args ...interface{}) error {
+	return &PartialError{Failed: len(errs), Total: total, Message: fmt.Sprintf(format, args...), Errs: errs}
+}
+
+// notFoundError is an error with its own message that wraps ErrNotFound.
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string {
+	return e.msg
+}
+
+func (e *notFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// notFoundf returns an error with a formatted message that wraps ErrNotFound.
+func notFoundf(format string, args ...interface{}) error {
+	return &notFoundError{msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/gitutils"
+)
+
+// resolveRef returns the ref given on the command line, falling back to the configured branch.
+// Refs starting with '-' are rejected, because git archive would parse them as options.
+func resolveRef(cfg *config.Config, ref string) (string, error) {
+	if ref == "" {
+		if cfg.RepoBranch == "" {
+			return "", fmt.Errorf("missing 'repo_branch' configuration and no branch specified with -b/--branch")
+		}
+		ref = cfg.RepoBranch
+	}
+	if strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("invalid ref '%s': must not start with '-'", ref)
+	}
+	return ref, nil
+}
+
+// checkPaths rejects paths that git archive would parse as options, e.g. "--output=...".
+func checkPaths(paths []string) error {
+	for _, path := range paths {
+		if strings.HasPrefix(path, "-") {
+			return fmt.Errorf("invalid path '%s': must not start with '-'", path)
+		}
+	}
+	return nil
+}
+
+// HandleCat is the handler for the 'cat' subcommand.
+// It prints the content of one or more files from the configured repository,
+// in the order they were given, to the output writer.
+func HandleCat(ctx context.Context, cfg *config.Config, runner command.Runner, ref string, paths []string) error {
+	cfg.Logger.Infof("Handling cat request for %v (ref: %s)", paths, ref)
+
+	if cfg.RepoURL == "" {
+		return fmt.Errorf("missing 'repo_url' configuration")
+	}
+	ref, err := resolveRef(cfg, ref)
+	if err != nil {
+		return err
+	}
+	if err := checkPaths(paths); err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		content, err := gitutils.FetchRemoteFileAt(ctx, cfg, runner, ref, path)
+		if err != nil {
+			return fmt.Errorf("failed to fetch '%s' from ref '%s': %w", path, ref, err)
+		}
+		if _, err := cfg.OutputWriter.Write(content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HandleExport is the handler for the 'export' subcommand.
+// It extracts files or whole directories from the configured repository into destDir.
+func HandleExport(ctx context.Context, cfg *config.Config, runner command.Runner, ref, destDir string, paths []string) error {
+	cfg.Logger.Infof("Handling export request for %v (ref: %s) into %s", paths, ref, destDir)
+
+	if cfg.RepoURL == "" {
+		return fmt.Errorf("missing 'repo_url' configuration")
+	}
+	ref, err := resolveRef(cfg, ref)
+	if err != nil {
+		return err
+	}
+	if err := checkPaths(paths); err != nil {
+		return err
+	}
+
+	if err := gitutils.ExportRemotePaths(ctx, cfg, runner, ref, destDir, paths...); err != nil {
+		return fmt.Errorf("failed to export %v from ref '%s': %w", paths, ref, err)
+	}
+
+	if _, err := fmt.Fprintf(cfg.OutputWriter, "Exported %d path(s) from '%s' into '%s'.\n", len(paths), ref, destDir); err != nil {
+		return err
+	}
+	return nil
+}
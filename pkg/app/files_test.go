@@ -0,0 +1,170 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/command/commandtest"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/logging"
+)
+
+func TestHandleCat(t *testing.T) {
+	newConfig := func(out *bytes.Buffer) *config.Config {
+		return &config.Config{
+			Logger:                  logging.NewLogger(logging.LevelDebug),
+			OutputWriter:            out,
+			RepoURL:                 "https://example.com/test/repo.git",
+			RepoBranch:              "main",
+			OperationTimeoutSeconds: 5,
+		}
+	}
+
+	t.Run("MultipleFilesInOrder", func(t *testing.T) {
+		var out bytes.Buffer
+		var refs []string
+		runner := &commandtest.MockRunner{
+			RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+				archive := p.Stages[0]
+				refs = append(refs, archive[3])
+				_, err := io.WriteString(p.Stdout, "content of "+archive[4]+"\n")
+				return err
+			},
+		}
+
+		err := HandleCat(context.Background(), newConfig(&out), runner, "", []string{"_manifest", "_project"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		want := "content of _manifest\ncontent of _project\n"
+		if out.String() != want {
+			t.Errorf("Output mismatch:\nGot:  %q\nWant: %q", out.String(), want)
+		}
+		for _, ref := range refs {
+			if ref != "main" {
+				t.Errorf("Expected configured branch 'main' to be used, got %q", ref)
+			}
+		}
+	})
+
+	t.Run("ExplicitRef", func(t *testing.T) {
+		var out bytes.Buffer
+		runner := &commandtest.MockRunner{
+			RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+				if archive := p.Stages[0]; archive[3] != "v1.0" || archive[4] != "_config" {
+					t.Errorf("Expected ref v1.0 in command, got %v", archive)
+				}
+				_, err := io.WriteString(p.Stdout, "config")
+				return err
+			},
+		}
+
+		if err := HandleCat(context.Background(), newConfig(&out), runner, "v1.0", []string{"_config"}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("FetchFailure", func(t *testing.T) {
+		var out bytes.Buffer
+		mockError := errors.New("archive failed")
+		runner := &commandtest.MockRunner{
+			RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+				return mockError
+			},
+		}
+
+		err := HandleCat(context.Background(), newConfig(&out), runner, "", []string{"_missing"})
+		if !errors.Is(err, mockError) {
+			t.Errorf("Expected error to wrap %v, got %v", mockError, err)
+		}
+	})
+
+	t.Run("MissingBranch", func(t *testing.T) {
+		var out bytes.Buffer
+		cfg := newConfig(&out)
+		cfg.RepoBranch = ""
+
+		err := HandleCat(context.Background(), cfg, &commandtest.MockRunner{}, "", []string{"_manifest"})
+		if err == nil || !strings.Contains(err.Error(), "repo_branch") {
+			t.Errorf("Expected missing repo_branch error, got %v", err)
+		}
+	})
+}
+
+func TestOptionLikeArguments(t *testing.T) {
+	cfg := &config.Config{
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OutputWriter:            &bytes.Buffer{},
+		RepoURL:                 "https://example.com/test/repo.git",
+		RepoBranch:              "main",
+		OperationTimeoutSeconds: 5,
+	}
+	// Nothing may run: a ref or path starting with '-' would be an option of git archive.
+	runner := &commandtest.MockRunner{}
+
+	tests := []struct {
+		name    string
+		run     func() error
+		wantErr string
+	}{
+		{"CatRef", func() error {
+			return HandleCat(context.Background(), cfg, runner, "--exec=touch /tmp/pwned", []string{"_manifest"})
+		}, "invalid ref '--exec=touch /tmp/pwned'"},
+		{"CatPath", func() error {
+			return HandleCat(context.Background(), cfg, runner, "", []string{"_manifest", "--output=/tmp/x"})
+		}, "invalid path '--output=/tmp/x'"},
+		{"ExportRef", func() error {
+			return HandleExport(context.Background(), cfg, runner, "-v", t.TempDir(), []string{"_config"})
+		}, "invalid ref '-v'"},
+		{"ExportPath", func() error {
+			return HandleExport(context.Background(), cfg, runner, "main", t.TempDir(), []string{"--remote=x"})
+		}, "invalid path '--remote=x'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	var out bytes.Buffer
+	destDir := t.TempDir()
+	cfg := &config.Config{
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OutputWriter:            &out,
+		RepoURL:                 "https://example.com/test/repo.git",
+		RepoBranch:              "main",
+		OperationTimeoutSeconds: 5,
+	}
+
+	runner := &commandtest.MockRunner{
+		RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+			want := [][]string{
+				{"git", "archive", "--remote=https://example.com/test/repo.git", "slfo-1.1", "products/SLES", "_config"},
+				{"tar", "-x", "-C", destDir},
+			}
+			if !reflect.DeepEqual(p.Stages, want) {
+				t.Errorf("Unexpected pipeline:\nGot:  %v\nWant: %v", p.Stages, want)
+			}
+			return nil
+		},
+	}
+
+	err := HandleExport(context.Background(), cfg, runner, "slfo-1.1", destDir, []string{"products/SLES", "_config"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Exported 2 path(s)") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
@@ -0,0 +1,275 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/gyr/relx-go/pkg/cache"
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/gitutils"
+)
+
+const (
+	maxConcurrentRepoSyncs = 4
+	maxConcurrentLsRemotes = 10
+)
+
+// repoSyncResult holds the outcome of syncing a single repository.
+type repoSyncResult struct {
+	repo config.Repository
+	path string
+	err  error
+}
+
+// HandleRepoSync is the handler for the 'repo sync' subcommand.
+// It clones or updates the configured repositories (or only the named ones) in parallel
+// and reports the status of each one. It returns a *PartialError if any repository failed
+// to sync.
+func HandleRepoSync(ctx context.Context, cfg *config.Config, runner command.Runner, names []string) error {
+	cfg.Logger.Infof("Handling repo sync request for %v", names)
+
+	repos, err := selectRepositories(cfg, names)
+	if err != nil {
+		return err
+	}
+	if len(repos) == 0 {
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "No repositories configured.\n"); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// Results are stored by index so that the report keeps the configured order.
+	results := make([]repoSyncResult, len(repos))
+	task := cfg.Progress.Start("Syncing repositories", len(repos))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentRepoSyncs)
+
+	for i, repo := range repos {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, repo config.Repository) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			path, err := gitutils.SyncRepo(ctx, cfg, runner, repo)
+			results[i] = repoSyncResult{repo: repo, path: path, err: err}
+			task.Add(1)
+		}(i, repo)
+	}
+	wg.Wait()
+	task.Finish()
+
+	var failed []error
+	for _, res := range results {
+		if res.err != nil {
+			failed = append(failed, res.err)
+			if _, err := fmt.Fprintf(cfg.OutputWriter, "FAILED  %s (%s): %v\n", res.repo.Name, res.repo.Branch, res.err); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "ok      %s (%s): %s\n", res.repo.Name, res.repo.Branch, res.path); err != nil {
+			return err
+		}
+	}
+
+	// Enforce the configured cache limits, but never evict the repositories just synced.
+	keep := make([]string, len(repos))
+	for i, repo := range repos {
+		keep[i] = repo.Name
+	}
+	if err := autoPruneCache(cfg, keep); err != nil {
+		cfg.Logger.Warnf("Failed to prune cache: %v", err)
+	}
+
+	if len(failed) > 0 {
+		return partialf(failed, len(repos), "%d of %d repositories failed to sync", len(failed), len(repos))
+	}
+	return nil
+}
+
+// HandleRepoPath is the handler for the 'repo path' subcommand.
+// It prints the local path of a synced repository, so that scripts can use it.
+// If the repository has not been synced yet, the error wraps ErrNotFound.
+func HandleRepoPath(cfg *config.Config, name string) error {
+	repo, err := gitutils.FindRepository(cfg, name)
+	if err != nil {
+		return err
+	}
+
+	c, err := cache.New(cfg.CacheDir)
+	if err != nil {
+		return err
+	}
+	if !c.Has(repo.Name, ".git") {
+		return notFoundf("repository %q has not been synced yet; run 'relx-go repo sync %s' first", repo.Name, repo.Name)
+	}
+
+	if err := c.Touch(repo.Name); err != nil {
+		cfg.Logger.Warnf("Failed to record access to %s: %v", repo.Name, err)
+	}
+
+	if _, err := fmt.Fprintln(cfg.OutputWriter, c.GetPath(repo.Name)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// selectRepositories returns all configured repositories, or only the named ones if names is not empty.
+func selectRepositories(cfg *config.Config, names []string) ([]config.Repository, error) {
+	if len(names) == 0 {
+		return gitutils.ResolveRepositories(cfg)
+	}
+
+	repos := make([]config.Repository, 0, len(names))
+	for _, name := range names {
+		repo, err := gitutils.FindRepository(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// submoduleStatus holds the comparison of a submodule's pinned commit with its branch tip.
+type submoduleStatus struct {
+	submodule gitutils.Submodule
+	tip       string
+	ahead     int // Commits of the pinned commit that are not on the branch
+	behind    int // Commits of the branch that are not in the pinned commit
+	err       error
+}
+
+// String describes the status for the STATUS column.
+func (st submoduleStatus) String() string {
+	switch {
+	case st.err != nil:
+		return "error"
+	case st.ahead > 0 && st.behind > 0:
+		return fmt.Sprintf("diverged (%d ahead, %d behind)", st.ahead, st.behind)
+	case st.behind > 0:
+		return fmt.Sprintf("behind %d", st.behind)
+	case st.ahead > 0:
+		return fmt.Sprintf("ahead %d", st.ahead)
+	}
+	return "up-to-date"
+}
+
+// HandleRepoSubmodules is the handler for the 'repo submodules' subcommand.
+// It syncs the repository, lists its submodules with their pinned commits and compares
+// them with the tip of each package repository's branch: a package is behind if the branch
+// has commits that the pinned commit does not, ahead if it is the other way round, and
+// diverged if both have commits of their own. If onlyBehind is set, packages that are not
+// behind (including diverged ones) are left out, except those that could not be compared.
+// If name is empty, the main `repo_url` repository is used.
+func HandleRepoSubmodules(ctx context.Context, cfg *config.Config, runner command.Runner, name string, onlyBehind bool) error {
+	cfg.Logger.Infof("Handling repo submodules request for %q", name)
+
+	var repo config.Repository
+	if name == "" {
+		if cfg.RepoURL == "" || cfg.RepoBranch == "" {
+			return fmt.Errorf("missing 'repo_url'/'repo_branch' configuration and no repository name given")
+		}
+		repo = config.Repository{URL: cfg.RepoURL, Branch: cfg.RepoBranch}
+	} else {
+		var err error
+		repo, err = gitutils.FindRepository(cfg, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	localPath, err := gitutils.SyncRepo(ctx, cfg, runner, repo)
+	if err != nil {
+		return fmt.Errorf("failed to sync repository %s: %w", repo.URL, err)
+	}
+
+	submodules, err := gitutils.ListSubmodules(ctx, cfg, runner, repo, localPath)
+	if err != nil {
+		return fmt.Errorf("failed to list submodules of %s: %w", repo.URL, err)
+	}
+	if len(submodules) == 0 {
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "Repository %s has no submodules.\n", repo.URL); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// Look up the branch tips concurrently; results are stored by index to keep the order.
+	statuses := make([]submoduleStatus, len(submodules))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentLsRemotes)
+
+	for i, sm := range submodules {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, sm gitutils.Submodule) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			st := submoduleStatus{submodule: sm}
+			st.tip, st.err = gitutils.RemoteBranchHead(ctx, cfg, runner, sm.URL, sm.Branch)
+			if st.err == nil && st.tip != sm.Commit {
+				st.ahead, st.behind, st.err = gitutils.CompareCommits(ctx, cfg, runner, sm.URL, sm.Commit, st.tip)
+			}
+			statuses[i] = st
+		}(i, sm)
+	}
+	wg.Wait()
+
+	w := tabwriter.NewWriter(cfg.OutputWriter, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "PACKAGE\tBRANCH\tPINNED\tTIP\tSTATUS"); err != nil {
+		return err
+	}
+
+	var behind int
+	var failed []error
+	for _, st := range statuses {
+		switch {
+		case st.err != nil:
+			failed = append(failed, st.err)
+			cfg.Logger.Warnf("Failed to compare %s with its branch: %v", st.submodule.Path, st.err)
+		case st.behind > 0:
+			behind++
+		}
+		if onlyBehind && st.err == nil && st.behind == 0 {
+			continue
+		}
+
+		branch := st.submodule.Branch
+		if branch == "" {
+			branch = "(default)"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", st.submodule.Path, branch, shortCommit(st.submodule.Commit), shortCommit(st.tip), st); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(cfg.OutputWriter, "\n%d of %d packages are behind their branch.\n", behind, len(submodules)); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return partialf(failed, len(submodules), "failed to compare %d of %d packages with their branch", len(failed), len(submodules))
+	}
+	return nil
+}
+
+// shortCommit abbreviates a commit hash for display.
+func shortCommit(commit string) string {
+	if commit == "" {
+		return "-"
+	}
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
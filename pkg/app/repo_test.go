@@ -0,0 +1,248 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/command/commandtest"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/logging"
+)
+
+func TestHandleRepoSync(t *testing.T) {
+	newConfig := func(out *bytes.Buffer, cacheDir string) *config.Config {
+		return &config.Config{
+			Logger:                  logging.NewLogger(logging.LevelDebug),
+			OutputWriter:            out,
+			CacheDir:                cacheDir,
+			OperationTimeoutSeconds: 5,
+			RepoURL:                 "https://example.com/products/SLFO.git",
+			RepoBranch:              "main",
+			Repositories: []config.Repository{
+				{URL: "https://example.com/products/SLES.git", Branch: "16.0"},
+				{Name: "leap", URL: "https://example.com/products/Leap.git", Branch: "leap-16.0"},
+			},
+		}
+	}
+
+	t.Run("AllRepositoriesCloned", func(t *testing.T) {
+		var out bytes.Buffer
+		cacheDir := t.TempDir()
+		runner := &commandtest.MockRunner{
+			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+				if name != "git" || args[0] != "clone" {
+					t.Errorf("Unexpected command: %s %v", name, args)
+				}
+				return nil, nil
+			},
+		}
+
+		if err := HandleRepoSync(context.Background(), newConfig(&out, cacheDir), runner, nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		want := []string{
+			"ok      SLES (16.0): " + filepath.Join(cacheDir, "SLES"),
+			"ok      leap (leap-16.0): " + filepath.Join(cacheDir, "leap"),
+			"ok      SLFO (main): " + filepath.Join(cacheDir, "SLFO"),
+		}
+		if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Output mismatch:\nGot:\n%s\nWant:\n%s", out.String(), strings.Join(want, "\n"))
+		}
+	})
+
+	t.Run("PartialFailure", func(t *testing.T) {
+		var out bytes.Buffer
+		mockError := errors.New("clone failed")
+		runner := &commandtest.MockRunner{
+			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+				if strings.Contains(strings.Join(args, " "), "Leap.git") {
+					return nil, mockError
+				}
+				return nil, nil
+			},
+		}
+
+		err := HandleRepoSync(context.Background(), newConfig(&out, t.TempDir()), runner, nil)
+		var partial *PartialError
+		if !errors.As(err, &partial) || partial.Failed != 1 || partial.Total != 3 {
+			t.Errorf("Expected partial failure error for 1 of 3 repositories, got %v", err)
+		}
+		if !strings.Contains(out.String(), "FAILED  leap (leap-16.0)") {
+			t.Errorf("Expected failure to be reported, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("SelectedRepository", func(t *testing.T) {
+		var out bytes.Buffer
+		var cloned []string
+		runner := &commandtest.MockRunner{
+			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+				cloned = append(cloned, args[len(args)-2])
+				return nil, nil
+			},
+		}
+
+		if err := HandleRepoSync(context.Background(), newConfig(&out, t.TempDir()), runner, []string{"leap"}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(cloned) != 1 || cloned[0] != "https://example.com/products/Leap.git" {
+			t.Errorf("Expected only leap to be cloned, got %v", cloned)
+		}
+	})
+
+	t.Run("UnknownRepository", func(t *testing.T) {
+		var out bytes.Buffer
+		err := HandleRepoSync(context.Background(), newConfig(&out, t.TempDir()), &commandtest.MockRunner{}, []string{"unknown"})
+		if err == nil || !strings.Contains(err.Error(), "not configured") {
+			t.Errorf("Expected unknown repository error, got %v", err)
+		}
+	})
+}
+
+func TestHandleRepoPath(t *testing.T) {
+	var out bytes.Buffer
+	cacheDir := t.TempDir()
+	cfg := &config.Config{
+		Logger:       logging.NewLogger(logging.LevelDebug),
+		OutputWriter: &out,
+		CacheDir:     cacheDir,
+		Repositories: []config.Repository{{URL: "https://example.com/products/SLES.git", Branch: "main"}},
+	}
+
+	if err := HandleRepoPath(cfg, "SLES"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for a repository that was not synced, got %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(cacheDir, "SLES", ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create dummy repo: %v", err)
+	}
+	if err := HandleRepoPath(cfg, "SLES"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.TrimSpace(out.String()) != filepath.Join(cacheDir, "SLES") {
+		t.Errorf("Unexpected path output: %q", out.String())
+	}
+}
+
+func TestHandleRepoSubmodules(t *testing.T) {
+	const gitmodules = `submodule.bash.path bash
+submodule.bash.url ../../pool/bash
+submodule.bash.branch main
+submodule.vim.path vim
+submodule.vim.url ../../pool/vim
+submodule.vim.branch main
+submodule.zsh.path zsh
+submodule.zsh.url ../../pool/zsh
+submodule.zsh.branch main
+`
+	const lsTree = "160000 commit aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\tbash\n" +
+		"160000 commit bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb\tvim\n" +
+		"160000 commit dddddddddddddddddddddddddddddddddddddddd\tzsh\n"
+
+	runner := &commandtest.MockRunner{
+		RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+			switch args[0] {
+			case "config":
+				return []byte(gitmodules), nil
+			case "ls-tree":
+				return []byte(lsTree), nil
+			case "ls-remote":
+				if strings.HasSuffix(args[1], "/pool/vim") || strings.HasSuffix(args[1], "/pool/zsh") {
+					return []byte("cccccccccccccccccccccccccccccccccccccccc\trefs/heads/main\n"), nil
+				}
+				return []byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\trefs/heads/main\n"), nil
+			case "-C":
+				// The pinned commit of vim is 2 commits behind the tip, zsh has diverged.
+				if args[2] == "rev-list" {
+					if strings.HasPrefix(args[len(args)-1], "bbbb") {
+						return []byte("0\t2\n"), nil
+					}
+					return []byte("1\t3\n"), nil
+				}
+			}
+			return nil, nil // clone
+		},
+	}
+
+	newConfig := func(out *bytes.Buffer) *config.Config {
+		return &config.Config{
+			Logger:                  logging.NewLogger(logging.LevelDebug),
+			OutputWriter:            out,
+			CacheDir:                t.TempDir(),
+			OperationTimeoutSeconds: 5,
+			RepoURL:                 "https://example.com/products/SLFO.git",
+			RepoBranch:              "main",
+		}
+	}
+
+	t.Run("AllPackages", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := HandleRepoSubmodules(context.Background(), newConfig(&out), runner, "", false); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		output := out.String()
+		for _, want := range []string{
+			"bash     main    aaaaaaaaaaaa  aaaaaaaaaaaa  up-to-date",
+			"vim      main    bbbbbbbbbbbb  cccccccccccc  behind 2",
+			"zsh      main    dddddddddddd  cccccccccccc  diverged (1 ahead, 3 behind)",
+			"2 of 3 packages are behind their branch.",
+		} {
+			if !strings.Contains(output, want) {
+				t.Errorf("Output missing %q. Got:\n%s", want, output)
+			}
+		}
+	})
+
+	t.Run("OnlyBehind", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := HandleRepoSubmodules(context.Background(), newConfig(&out), runner, "SLFO", true); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if strings.Contains(out.String(), "up-to-date") || !strings.Contains(out.String(), "behind 2") || !strings.Contains(out.String(), "diverged") {
+			t.Errorf("Expected only packages behind their branch. Got:\n%s", out.String())
+		}
+	})
+
+	t.Run("CompareFailure", func(t *testing.T) {
+		var out bytes.Buffer
+		failingRunner := &commandtest.MockRunner{
+			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+				if args[0] == "-C" && args[2] == "fetch" {
+					return []byte("fatal: remote error: not our ref"), errors.New("exit status 128")
+				}
+				return runner.RunFunc(ctx, workDir, name, args...)
+			},
+		}
+		err := HandleRepoSubmodules(context.Background(), newConfig(&out), failingRunner, "", true)
+		if err == nil || !strings.Contains(err.Error(), "2 of 3 packages") {
+			t.Errorf("Expected comparison failure error, got %v", err)
+		}
+		if !strings.Contains(out.String(), "cccccccccccc  error") {
+			t.Errorf("Expected failed packages to be listed. Got:\n%s", out.String())
+		}
+	})
+
+	t.Run("LsRemoteFailure", func(t *testing.T) {
+		var out bytes.Buffer
+		failingRunner := &commandtest.MockRunner{
+			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+				if args[0] == "ls-remote" {
+					return nil, errors.New("connection refused")
+				}
+				return runner.RunFunc(ctx, workDir, name, args...)
+			},
+		}
+		err := HandleRepoSubmodules(context.Background(), newConfig(&out), failingRunner, "", false)
+		if err == nil || !strings.Contains(err.Error(), "3 of 3 packages") {
+			t.Errorf("Expected lookup failure error, got %v", err)
+		}
+	})
+}
@@ -3,73 +3,56 @@ package app
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/config"
 	"github.com/gyr/relx-go/pkg/gitea"
+	"github.com/gyr/relx-go/pkg/progress"
 )
 
 // HandleReview initializes the Gitea client, fetches PRs, and prints the results.
 // This function encapsulates the business logic for the 'review' command.
-func HandleReview(ctx context.Context, cfg *config.Config, runner command.Runner, branch string, prIDs []string, repository, user string) error {
-	cfg.Logger.Debugf("Handling review for branch=%s, prIDs=%v, repository=%s", branch, prIDs, repository)
+// The open PRs of all targets are fetched concurrently and reviewed as one queue, grouped
+// by repository and branch.
+// Each PR is shown in the configured viewer; show selects the part (see gitea.ShowParts).
+// The answers to the prompts are read from cfg.InputReader. If no PRs are pending review,
+// the error wraps ErrNotFound; if some PRs could not be shown or approved, or the PRs of
+// some targets could not be fetched, it is a *PartialError.
+func HandleReview(ctx context.Context, cfg *config.Config, runner command.Runner, targets []config.ReviewTarget, prIDs []string, user, show string) error {
+	cfg.Logger.Debugf("Handling review for targets=%v, prIDs=%v", targets, prIDs)
 
-	var reviewer string
-	if user != "" {
-		reviewer = user
-	} else {
-		reviewer = cfg.PRReviewer
-	}
-
-	if reviewer == "" {
-		return fmt.Errorf("missing 'pr_reviewer' configuration and no user specified with -u/--user")
+	reviewer, err := reviewerFor(cfg, user)
+	if err != nil {
+		return err
 	}
 
 	giteaClient := gitea.NewClient(runner, cfg)
 
-	// Always get PRs by branch first, as branch is now mandatory.
-	fetchedPRs, err := giteaClient.GetOpenPullRequests(ctx, reviewer, branch, repository)
+	queue, err := fetchReviewQueue(ctx, cfg, giteaClient, reviewer, targets, prIDs)
 	if err != nil {
-		return fmt.Errorf("failed to get open pull requests for branch '%s': %w", branch, err)
-	}
-
-	var prsToReview []string
-	if len(prIDs) > 0 {
-		// User provided specific PR IDs, so filter the fetched PRs.
-		fetchedPRsMap := make(map[string]struct{}, len(fetchedPRs))
-		for _, id := range fetchedPRs {
-			fetchedPRsMap[id] = struct{}{}
-		}
-
-		for _, providedID := range prIDs {
-			if _, exists := fetchedPRsMap[providedID]; exists {
-				prsToReview = append(prsToReview, providedID)
-			} else {
-				if _, err := fmt.Fprintf(cfg.OutputWriter, "Info: PR #%s (provided with -p) was not found pending review on branch '%s'.\n", providedID, branch); err != nil {
-					return err
-				}
-			}
-		}
-	} else {
-		// No specific PR IDs provided, review all fetched PRs for the branch.
-		prsToReview = fetchedPRs
-	}
-
-	if len(prsToReview) == 0 {
-		if _, err := fmt.Fprintf(cfg.OutputWriter, "No open pull requests found for review.\n"); err != nil {
-			return err
-		}
-		return nil
+		return err
 	}
+	grouped := len(queue.targets) > 1
 
 	if _, err := fmt.Fprintf(cfg.OutputWriter, "\n--- Open Pull Requests for Review ---\n"); err != nil {
 		return err
 	}
-	for _, id := range prsToReview {
-		if _, err := fmt.Fprintf(cfg.OutputWriter, "PR ID: %s\n", id); err != nil {
+	for i, pr := range queue.prs {
+		if grouped && (i == 0 || pr.target != queue.prs[i-1].target) {
+			if _, err := fmt.Fprintf(cfg.OutputWriter, "%s:\n", targetName(pr.target)); err != nil {
+				return err
+			}
+		}
+		indent := ""
+		if grouped {
+			indent = "  "
+		}
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "%sPR ID: %s\n", indent, pr.id); err != nil {
 			return err
 		}
 	}
@@ -78,17 +61,25 @@ func HandleReview(ctx context.Context, cfg *config.Config, runner command.Runner
 		return err
 	}
 
-	reader := bufio.NewReader(os.Stdin)
+	reader := bufio.NewReader(cfg.InputReader)
 	response, err := reader.ReadString('\n')
 	if err != nil {
 		return fmt.Errorf("failed to read user input: %w", err)
 	}
 	response = strings.ToLower(strings.TrimSpace(response))
 
+	var failed []error
 	if response == "y" || response == "yes" {
-		for _, id := range prsToReview {
-			if err := giteaClient.ShowPullRequest(ctx, repository, id); err != nil {
-				cfg.Logger.Warnf("Failed to show pull request %s: %v. Skipping.", id, err)
+		for i, pr := range queue.prs {
+			if grouped && (i == 0 || pr.target != queue.prs[i-1].target) {
+				if _, err := fmt.Fprintf(cfg.OutputWriter, "\n--- %s ---\n", targetName(pr.target)); err != nil {
+					return err
+				}
+			}
+
+			if err := giteaClient.ShowPullRequest(ctx, pr.target.Repository, pr.id, show); err != nil {
+				cfg.Logger.Warnf("Failed to show pull request %s: %v. Skipping.", pr.label, err)
+				failed = append(failed, err)
 				continue
 			}
 
@@ -104,15 +95,16 @@ func HandleReview(ctx context.Context, cfg *config.Config, runner command.Runner
 
 			switch actionResponse {
 			case "a", "approve":
-				if err := giteaClient.ApprovePullRequest(ctx, repository, id, reviewer); err != nil {
-					cfg.Logger.Warnf("Failed to approve pull request %s: %v.", id, err)
+				if err := giteaClient.ApprovePullRequest(ctx, pr.target.Repository, pr.id, reviewer); err != nil {
+					cfg.Logger.Errorf("Failed to approve pull request %s: %v.", pr.label, err)
+					failed = append(failed, err)
 				} else {
-					if _, err := fmt.Fprintf(cfg.OutputWriter, "PR %s approved.\n", id); err != nil {
+					if _, err := fmt.Fprintf(cfg.OutputWriter, "PR %s approved.\n", pr.label); err != nil {
 						return err
 					}
 				}
 			case "s", "skip":
-				if _, err := fmt.Fprintf(cfg.OutputWriter, "Skipping PR %s.\n", id); err != nil {
+				if _, err := fmt.Fprintf(cfg.OutputWriter, "Skipping PR %s.\n", pr.label); err != nil {
 					return err
 				}
 				continue
@@ -120,9 +112,9 @@ func HandleReview(ctx context.Context, cfg *config.Config, runner command.Runner
 				if _, err := fmt.Fprintf(cfg.OutputWriter, "Exiting review process.\n"); err != nil {
 					return err
 				}
-				return nil
+				return queue.result(failed)
 			default:
-				if _, err := fmt.Fprintf(cfg.OutputWriter, "Invalid option. Skipping PR %s.\n", id); err != nil {
+				if _, err := fmt.Fprintf(cfg.OutputWriter, "Invalid option. Skipping PR %s.\n", pr.label); err != nil {
 					return err
 				}
 			}
@@ -133,5 +125,190 @@ func HandleReview(ctx context.Context, cfg *config.Config, runner command.Runner
 		}
 	}
 
+	return queue.result(failed)
+}
+
+// reviewerFor returns the reviewer given with -u/--user, or the configured pr_reviewer.
+func reviewerFor(cfg *config.Config, user string) (string, error) {
+	if user != "" {
+		return user, nil
+	}
+	if cfg.PRReviewer == "" {
+		return "", fmt.Errorf("missing 'pr_reviewer' configuration and no user specified with -u/--user")
+	}
+	return cfg.PRReviewer, nil
+}
+
+// queuedPR is a pull request in the review queue.
+type queuedPR struct {
+	target config.ReviewTarget
+	id     string
+	// label names the PR in messages: its ID, or repository#ID if the queue spans
+	// several repositories.
+	label string
+}
+
+// reviewQueue is the merged queue of the PRs of several targets.
+type reviewQueue struct {
+	targets    []config.ReviewTarget
+	prs        []queuedPR
+	targetErrs []error // Errors of the targets whose PRs could not be fetched
+}
+
+// fetchReviewQueue fetches the open PRs that request a review from reviewer on all
+// targets concurrently, and merges them into one queue without duplicates, grouped by
+// target in the given order. With prIDs, only the given PRs are kept; an ID matches the
+// PR of any target, and "repository#ID" only that of the repository. If there are no PRs,
+// the error wraps ErrNotFound. If the PRs of some targets cannot be fetched, the others
+// are reviewed; the error of the first target is returned only if all of them failed.
+func fetchReviewQueue(ctx context.Context, cfg *config.Config, giteaClient *gitea.Client, reviewer string, targets []config.ReviewTarget, prIDs []string) (*reviewQueue, error) {
+	queue := &reviewQueue{targets: dedupeTargets(targets)}
+	if len(queue.targets) == 0 {
+		return nil, errors.New("no repository and branch to review")
+	}
+
+	// Results are stored by index so that the queue keeps the order of the targets.
+	fetched := make([][]string, len(queue.targets))
+	errs := make([]error, len(queue.targets))
+	var task *progress.Task
+	if len(queue.targets) > 1 {
+		task = cfg.Progress.Start("Fetching review queues", len(queue.targets))
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentPullRequestFetches)
+
+	for i, target := range queue.targets {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, target config.ReviewTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fetched[i], errs[i] = giteaClient.GetOpenPullRequests(ctx, reviewer, target.Branch, target.Repository)
+			task.Add(1)
+		}(i, target)
+	}
+	wg.Wait()
+	task.Finish()
+
+	repositories := make(map[string]struct{})
+	for _, target := range queue.targets {
+		repositories[target.Repository] = struct{}{}
+	}
+	var all []queuedPR
+	seen := make(map[string]struct{})
+	for i, target := range queue.targets {
+		if errs[i] != nil {
+			queue.targetErrs = append(queue.targetErrs, errs[i])
+			if len(queue.targets) > 1 {
+				cfg.Logger.Errorf("Failed to get open pull requests for %s: %v", targetName(target), errs[i])
+			}
+			continue
+		}
+		for _, id := range fetched[i] {
+			label := id
+			if len(repositories) > 1 {
+				label = target.Repository + "#" + id
+			}
+			// The same PR is only listed twice if its repository and branch are given twice.
+			if _, dup := seen[target.Repository+"#"+id]; dup {
+				continue
+			}
+			seen[target.Repository+"#"+id] = struct{}{}
+			all = append(all, queuedPR{target: target, id: id, label: label})
+		}
+	}
+	if len(queue.targetErrs) == len(queue.targets) {
+		target := queue.targets[0]
+		return nil, fmt.Errorf("failed to get open pull requests for branch '%s': %w", target.Branch, errs[0])
+	}
+
+	if len(prIDs) > 0 {
+		// User provided specific PR IDs, so filter the fetched PRs.
+		picked := make(map[string]struct{})
+		for _, providedID := range prIDs {
+			found := false
+			for _, pr := range all {
+				if providedID != pr.id && providedID != pr.target.Repository+"#"+pr.id {
+					continue
+				}
+				found = true
+				if _, dup := picked[pr.target.Repository+"#"+pr.id]; !dup {
+					picked[pr.target.Repository+"#"+pr.id] = struct{}{}
+					queue.prs = append(queue.prs, pr)
+				}
+			}
+			if !found {
+				if _, err := fmt.Fprintf(cfg.OutputWriter, "Info: PR #%s (provided with -p) was not found pending review on %s.\n", providedID, describeTargets(queue.targets)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		// Keep the PRs grouped by target, in the order they were given within a target.
+		order := make(map[config.ReviewTarget]int, len(queue.targets))
+		for i, target := range queue.targets {
+			order[target] = i
+		}
+		sort.SliceStable(queue.prs, func(i, j int) bool {
+			return order[queue.prs[i].target] < order[queue.prs[j].target]
+		})
+	} else {
+		// No specific PR IDs provided, review all fetched PRs.
+		queue.prs = all
+	}
+
+	if len(queue.prs) == 0 {
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "No open pull requests found for review.\n"); err != nil {
+			return nil, err
+		}
+		return nil, notFoundf("no open pull requests found for review on %s", describeTargets(queue.targets))
+	}
+	return queue, nil
+}
+
+// result returns a *PartialError if PRs could not be shown or approved, with the errors
+// in failed, or if the PRs of some targets could not be fetched.
+func (q *reviewQueue) result(failed []error) error {
+	if err := reviewResult(failed, len(q.prs)); err != nil {
+		return err
+	}
+	if len(q.targetErrs) > 0 {
+		return partialf(q.targetErrs, len(q.targets), "failed to get the pull requests of %d of %d branches", len(q.targetErrs), len(q.targets))
+	}
+	return nil
+}
+
+// dedupeTargets returns targets without repeated entries, in their order.
+func dedupeTargets(targets []config.ReviewTarget) []config.ReviewTarget {
+	seen := make(map[config.ReviewTarget]struct{}, len(targets))
+	var unique []config.ReviewTarget
+	for _, target := range targets {
+		if _, dup := seen[target]; !dup {
+			seen[target] = struct{}{}
+			unique = append(unique, target)
+		}
+	}
+	return unique
+}
+
+// targetName returns the name of a target in headings, e.g. "products/SLFO (main)".
+func targetName(target config.ReviewTarget) string {
+	return fmt.Sprintf("%s (%s)", target.Repository, target.Branch)
+}
+
+// describeTargets describes the targets in messages, e.g. "branch 'main'" or "any of 5 branches".
+func describeTargets(targets []config.ReviewTarget) string {
+	if len(targets) == 1 {
+		return fmt.Sprintf("branch '%s'", targets[0].Branch)
+	}
+	return fmt.Sprintf("any of %d branches", len(targets))
+}
+
+// reviewResult returns a *PartialError if some of total PRs could not be shown or
+// approved, with the errors in failed.
+func reviewResult(failed []error, total int) error {
+	if len(failed) > 0 {
+		return partialf(failed, total, "failed to show or approve %d of %d pull requests", len(failed), total)
+	}
 	return nil
 }
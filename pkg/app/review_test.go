@@ -5,43 +5,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/command/commandtest"
 	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/gitea"
 	"github.com/gyr/relx-go/pkg/logging"
 )
 
-// mockStdin is a helper function to simulate user input for interactive tests.
-func mockStdin(t *testing.T, input string) func() {
-	t.Helper()
-
-	oldStdin := os.Stdin
-	r, w, err := os.Pipe()
-	if err != nil {
-		t.Fatalf("failed to create pipe: %v", err)
-	}
-
-	os.Stdin = r
-
-	go func() {
-		defer func() {
-			if err := w.Close(); err != nil {
-				t.Errorf("failed to close pipe writer: %v", err)
-			}
-		}()
-		_, err := w.WriteString(input)
-		if err != nil {
-			t.Errorf("failed to write to pipe: %v", err)
-		}
-	}()
-
-	return func() {
-		os.Stdin = oldStdin
-	}
-}
 func TestHandleReview(t *testing.T) {
 	// Common test setup
 	const branch = "test-branch"
@@ -53,6 +27,7 @@ func TestHandleReview(t *testing.T) {
 		return &config.Config{
 			Logger:       logging.NewLogger(logging.LevelDebug),
 			PRReviewer:   reviewer,
+			PRViewer:     gitea.ViewerPager,
 			OutputWriter: &bytes.Buffer{},
 		}
 	}
@@ -86,7 +61,8 @@ func TestHandleReview(t *testing.T) {
 					}
 					return nil, nil // Default for other commands
 				},
-				RunPipelineFunc: func(ctx context.Context, workDir string, cmd1, cmd2 []string) error {
+				RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+					cmd1 := p.Stages[0]
 					if cmd1[0] == "git-obs" && cmd1[1] == "pr" && cmd1[2] == "show" {
 						return nil
 					}
@@ -112,7 +88,7 @@ func TestHandleReview(t *testing.T) {
 					}
 					return nil, nil
 				},
-				RunPipelineFunc: func(ctx context.Context, workDir string, cmd1, cmd2 []string) error {
+				RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
 					return nil // For 'pr show'
 				},
 			},
@@ -133,7 +109,7 @@ func TestHandleReview(t *testing.T) {
 					return nil, nil
 				},
 			},
-			wantErr: "",
+			wantErr: "no open pull requests found for review",
 			wantOutput: []string{
 				"Info: PR #999 (provided with -p) was not found pending review on branch 'test-branch'.",
 				"No open pull requests found for review.",
@@ -153,7 +129,7 @@ func TestHandleReview(t *testing.T) {
 					return nil, nil
 				},
 			},
-			wantErr:    "",
+			wantErr:    "no open pull requests found for review",
 			wantOutput: []string{"No open pull requests found"},
 		},
 		{
@@ -175,13 +151,37 @@ func TestHandleReview(t *testing.T) {
 					}
 					return nil, nil
 				},
-				RunPipelineFunc: func(ctx context.Context, workDir string, cmd1, cmd2 []string) error {
+				RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
 					return nil
 				},
 			},
 			wantErr:    "",
 			wantOutput: []string{"PR 123 approved."},
 		},
+		{
+			name:           "Partial failure - PR cannot be shown",
+			userInput:      "y\na\n",
+			userFlag:       "",
+			configReviewer: reviewer,
+			prIDs:          []string{},
+			runner: &commandtest.MockRunner{
+				RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+					if name == "git-obs" && args[0] == "pr" && args[1] == "list" {
+						return []byte("ID: #123\nID: #456"), nil
+					}
+					return nil, nil
+				},
+				RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+					cmd1 := p.Stages[0]
+					if cmd1[len(cmd1)-1] == "test-repo#123" {
+						return errors.New("git-obs crashed")
+					}
+					return nil
+				},
+			},
+			wantErr:    "failed to show or approve 1 of 2 pull requests",
+			wantOutput: []string{"PR 456 approved."},
+		},
 		{
 			name:           "No reviewer configured",
 			userInput:      "",
@@ -206,17 +206,17 @@ func TestHandleReview(t *testing.T) {
 		},
 	}
 
+	// The PRs are piped to $PAGER, which any system has.
+	t.Setenv("PAGER", "cat")
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if tc.userInput != "" {
-				restoreStdin := mockStdin(t, tc.userInput)
-				defer restoreStdin()
-			}
-
 			cfg := baseConfig()
 			cfg.PRReviewer = tc.configReviewer
+			cfg.InputReader = strings.NewReader(tc.userInput)
 
-			err := HandleReview(context.Background(), cfg, tc.runner, branch, tc.prIDs, repository, tc.userFlag)
+			targets := []config.ReviewTarget{{Repository: repository, Branch: branch}}
+			err := HandleReview(context.Background(), cfg, tc.runner, targets, tc.prIDs, tc.userFlag, gitea.ShowAll)
 
 			if tc.wantErr != "" {
 				if err == nil {
@@ -225,10 +225,7 @@ func TestHandleReview(t *testing.T) {
 				if !strings.Contains(err.Error(), tc.wantErr) {
 					t.Errorf("Expected error message to contain %q, but got %q", tc.wantErr, err.Error())
 				}
-				return // Test ends here if an error is expected
-			}
-
-			if err != nil {
+			} else if err != nil {
 				t.Fatalf("Expected no error, but got: %v", err)
 			}
 
@@ -241,3 +238,83 @@ func TestHandleReview(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleReviewMultipleTargets(t *testing.T) {
+	targets := []config.ReviewTarget{
+		{Repository: "products/SLFO", Branch: "main"},
+		{Repository: "products/SLES", Branch: "16.0"},
+		{Repository: "products/SLFO", Branch: "main"}, // Given twice
+		{Repository: "products/SLES", Branch: "15.7"},
+	}
+	var mu sync.Mutex
+	var approved []string
+	runner := &commandtest.MockRunner{
+		RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+			switch {
+			case args[1] == "list":
+				// The repository and the branch are the last arguments.
+				switch strings.Join(args[len(args)-3:], " ") {
+				case "--target-branch main products/SLFO":
+					return []byte("ID: #1\nID: #2"), nil
+				case "--target-branch 16.0 products/SLES":
+					return []byte("ID: #1"), nil
+				}
+				return nil, errors.New("gitea is down")
+			case args[1] == "comment":
+				mu.Lock()
+				defer mu.Unlock()
+				approved = append(approved, args[2])
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unexpected command: %v", args)
+		},
+		RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+			return nil
+		},
+	}
+	t.Setenv("PAGER", "cat")
+
+	var out bytes.Buffer
+	cfg := &config.Config{
+		Logger:       logging.NewLogger(logging.LevelError),
+		PRReviewer:   "test-reviewer",
+		PRViewer:     gitea.ViewerPager,
+		OutputWriter: &out,
+		InputReader:  strings.NewReader("y\na\ns\na\n"),
+	}
+	err := HandleReview(context.Background(), cfg, runner, targets, nil, "", gitea.ShowAll)
+
+	// The PRs of 15.7 could not be listed; the others were reviewed.
+	var partial *PartialError
+	if !errors.As(err, &partial) || partial.Failed != 1 || partial.Total != 3 {
+		t.Fatalf("Expected a PartialError for 1 of 3 branches, got %v", err)
+	}
+	want := []string{"products/SLFO#1", "products/SLES#1"}
+	if strings.Join(approved, ",") != strings.Join(want, ",") {
+		t.Errorf("approved = %v, want %v", approved, want)
+	}
+	for _, expected := range []string{
+		"products/SLFO (main):\n  PR ID: 1\n  PR ID: 2\nproducts/SLES (16.0):\n  PR ID: 1\n",
+		"--- products/SLES (16.0) ---",
+		"PR products/SLFO#1 approved.",
+		"Skipping PR products/SLFO#2.",
+		"PR products/SLES#1 approved.",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Output missing expected string %q. Full output:\n%s", expected, out.String())
+		}
+	}
+
+	t.Run("FilterByRepository", func(t *testing.T) {
+		out.Reset()
+		cfg.InputReader = strings.NewReader("n\n")
+		err := HandleReview(context.Background(), cfg, runner, targets[:2], []string{"products/SLES#1", "3"}, "", gitea.ShowAll)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(out.String(), "Info: PR #3 (provided with -p) was not found pending review on any of 2 branches.") ||
+			!strings.Contains(out.String(), "products/SLES (16.0):\n  PR ID: 1\nDo you want") {
+			t.Errorf("Unexpected output:\n%s", out.String())
+		}
+	})
+}
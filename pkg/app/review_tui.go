@@ -0,0 +1,207 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/gitea"
+	"github.com/gyr/relx-go/pkg/tui"
+)
+
+// maxConcurrentPullRequestFetches limits the number of PR details fetched at once.
+const maxConcurrentPullRequestFetches = 8
+
+// openScreen opens the full-screen UI on the terminal of cfg and returns a func that
+// restores the terminal. Tests replace it with a scripted screen.
+var openScreen = func(cfg *config.Config) (tui.Screen, func() error, error) {
+	in, inOK := cfg.InputReader.(*os.File)
+	out, outOK := cfg.OutputWriter.(*os.File)
+	if !inOK || !outOK || !isTerminal(out) {
+		return nil, nil, errors.New("--tui needs a terminal for input and output")
+	}
+	terminal, err := tui.Open(in, out)
+	if err != nil {
+		return nil, nil, err
+	}
+	return terminal, terminal.Close, nil
+}
+
+// isTerminal reports whether f is a character device, e.g. a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// HandleReviewTUI reviews the same pull requests as HandleReview, but in a full-screen
+// terminal UI: a list of the PRs with their author, age and CI state above the part of the
+// selected PR chosen by show (see gitea.ShowParts), with keys to approve, skip, comment on
+// and open them. A summary is printed when the UI is closed. If no PRs are pending review,
+// the error wraps ErrNotFound; if some PRs could not be shown or approved, or the PRs of
+// some targets could not be fetched, it is a *PartialError.
+func HandleReviewTUI(ctx context.Context, cfg *config.Config, runner command.Runner, targets []config.ReviewTarget, prIDs []string, user, show string) error {
+	cfg.Logger.Debugf("Handling TUI review for targets=%v, prIDs=%v", targets, prIDs)
+
+	reviewer, err := reviewerFor(cfg, user)
+	if err != nil {
+		return err
+	}
+
+	giteaClient := gitea.NewClient(runner, cfg)
+
+	queue, err := fetchReviewQueue(ctx, cfg, giteaClient, reviewer, targets, prIDs)
+	if err != nil {
+		return err
+	}
+
+	items, prs := reviewItems(ctx, cfg, giteaClient, queue)
+
+	screen, closeScreen, err := openScreen(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open the review UI: %w", err)
+	}
+	actions := &reviewActions{
+		ctx:      ctx,
+		runner:   runner,
+		client:   giteaClient,
+		prs:      prs,
+		reviewer: reviewer,
+		show:     show,
+		failed:   make(map[*tui.ReviewItem]error),
+	}
+	title := fmt.Sprintf("Review %s as %s", targetName(queue.targets[0]), reviewer)
+	if len(queue.targets) > 1 {
+		title = fmt.Sprintf("Review %d branches as %s", len(queue.targets), reviewer)
+	}
+	err = tui.RunReview(screen, title, items, actions)
+	if closeErr := closeScreen(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("review UI failed: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(cfg.OutputWriter, "\n--- Review Summary ---\n"); err != nil {
+		return err
+	}
+	for _, item := range items {
+		status := item.Status
+		if status == tui.StatusPending {
+			status = "not reviewed"
+		}
+		if _, err := fmt.Fprintf(cfg.OutputWriter, "PR %s: %s\n", prs[item].label, status); err != nil {
+			return err
+		}
+	}
+	var failed []error
+	for _, item := range items {
+		if err, exists := actions.failed[item]; exists {
+			failed = append(failed, err)
+		}
+	}
+	return queue.result(failed)
+}
+
+// reviewItems fetches the details of the PRs of the queue concurrently, and returns the
+// items of the UI with the PRs they stand for. A PR whose details cannot be fetched is
+// still listed, with its ID only.
+func reviewItems(ctx context.Context, cfg *config.Config, giteaClient *gitea.Client, queue *reviewQueue) ([]*tui.ReviewItem, map[*tui.ReviewItem]queuedPR) {
+	items := make([]*tui.ReviewItem, len(queue.prs))
+	prs := make(map[*tui.ReviewItem]queuedPR, len(queue.prs))
+	task := cfg.Progress.Start("Loading pull requests", len(queue.prs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentPullRequestFetches)
+
+	for i, pr := range queue.prs {
+		items[i] = &tui.ReviewItem{ID: pr.id, Group: targetName(pr.target)}
+		prs[items[i]] = pr
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item *tui.ReviewItem, pr queuedPR) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer task.Add(1)
+
+			details, err := giteaClient.GetPullRequest(ctx, pr.target.Repository, pr.id)
+			if err != nil {
+				cfg.Logger.Warnf("Failed to get details of pull request %s: %v", pr.label, err)
+				return
+			}
+			item.Title = details.Title
+			item.Author = details.User.Login
+			item.Created = details.CreatedAt
+			item.CIState = details.CIState
+			item.URL = details.URL
+		}(items[i], pr)
+	}
+	wg.Wait()
+	task.Finish()
+	return items, prs
+}
+
+// reviewActions implements tui.ReviewActions with the Gitea client. It records the PRs
+// that could not be shown or approved.
+type reviewActions struct {
+	ctx      context.Context
+	runner   command.Runner
+	client   *gitea.Client
+	prs      map[*tui.ReviewItem]queuedPR
+	reviewer string
+	show     string
+	failed   map[*tui.ReviewItem]error
+}
+
+func (a *reviewActions) Diff(item *tui.ReviewItem) (string, error) {
+	pr := a.prs[item]
+	text, err := a.client.PullRequestText(a.ctx, pr.target.Repository, pr.id, a.show)
+	if err != nil {
+		a.failed[item] = err
+		return "", err
+	}
+	return string(text), nil
+}
+
+func (a *reviewActions) Approve(item *tui.ReviewItem) error {
+	pr := a.prs[item]
+	if err := a.client.ApprovePullRequest(a.ctx, pr.target.Repository, pr.id, a.reviewer); err != nil {
+		a.failed[item] = err
+		return err
+	}
+	delete(a.failed, item)
+	return nil
+}
+
+func (a *reviewActions) Comment(item *tui.ReviewItem, message string) error {
+	pr := a.prs[item]
+	return a.client.CommentPullRequest(a.ctx, pr.target.Repository, pr.id, message)
+}
+
+func (a *reviewActions) Open(item *tui.ReviewItem) error {
+	if item.URL == "" {
+		return fmt.Errorf("the URL of pull request %s is unknown", a.prs[item].label)
+	}
+	browser := browserCommand()
+	args := append(browser[1:], item.URL)
+	_, err := a.runner.Exec(a.ctx, "" /* workDir */, browser[0], args...)
+	return err
+}
+
+// browserCommand returns the command that opens a URL in a web browser: $BROWSER, or the
+// opener of the desktop.
+func browserCommand() []string {
+	if browser := os.Getenv("BROWSER"); browser != "" {
+		return []string{browser}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"open"}
+	case "windows":
+		return []string{"rundll32", "url.dll,FileProtocolHandler"}
+	}
+	return []string{"xdg-open"}
+}
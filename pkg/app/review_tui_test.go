@@ -0,0 +1,105 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/command/commandtest"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/gitea"
+	"github.com/gyr/relx-go/pkg/logging"
+	"github.com/gyr/relx-go/pkg/tui"
+)
+
+// keyScreen is a tui.Screen that returns scripted keys and records the last frame.
+type keyScreen struct {
+	keys   []tui.Key
+	last   string
+	closed bool
+}
+
+func (s *keyScreen) Size() (int, int) { return 120, 30 }
+
+func (s *keyScreen) ReadKey() (tui.Key, error) {
+	if len(s.keys) == 0 {
+		return tui.Key{}, errors.New("out of keys")
+	}
+	key := s.keys[0]
+	s.keys = s.keys[1:]
+	return key, nil
+}
+
+func (s *keyScreen) Draw(lines []string) error {
+	s.last = strings.Join(lines, "\n")
+	return nil
+}
+
+func TestHandleReviewTUI(t *testing.T) {
+	const branch = "test-branch"
+	const repository = "test-repo"
+	const reviewer = "test-reviewer"
+
+	// PR 123 is approved, the diff of PR 456 fails to load and it is skipped.
+	var comments []string
+	runner := &commandtest.MockRunner{
+		ExecFunc: func(ctx context.Context, workDir, name string, args ...string) (*command.Result, error) {
+			switch {
+			case args[0] == "pr" && args[1] == "list":
+				return &command.Result{Stdout: []byte("ID: #123\nID: #456\n")}, nil
+			case args[0] == "api" && args[1] == "/repos/test-repo/pulls/123":
+				return &command.Result{Stdout: []byte(`{"title": "Update foo", "user": {"login": "alice"}, "head": {"sha": "abc"}, "html_url": "https://src.example.com/test-repo/pulls/123"}`)}, nil
+			case args[0] == "api" && args[1] == "/repos/test-repo/commits/abc/status":
+				return &command.Result{Stdout: []byte(`{"state": "success"}`)}, nil
+			case args[0] == "api":
+				return &command.Result{ExitCode: 1}, errors.New("not found")
+			case args[0] == "pr" && args[1] == "show" && args[len(args)-1] == "test-repo#123":
+				return &command.Result{Stdout: []byte("+added line\n")}, nil
+			case args[0] == "pr" && args[1] == "show":
+				return &command.Result{ExitCode: 1}, errors.New("show failed")
+			case args[0] == "pr" && args[1] == "comment":
+				comments = append(comments, fmt.Sprintf("%s %s", args[2], args[4]))
+				return &command.Result{}, nil
+			}
+			return nil, fmt.Errorf("unexpected command: %s %v", name, args)
+		},
+	}
+
+	screen := &keyScreen{keys: []tui.Key{{Code: tui.KeyRune, Rune: 'a'}, {Code: tui.KeyRune, Rune: 's'}, {Code: tui.KeyRune, Rune: 'q'}}}
+	defer func(orig func(*config.Config) (tui.Screen, func() error, error)) { openScreen = orig }(openScreen)
+	openScreen = func(cfg *config.Config) (tui.Screen, func() error, error) {
+		return screen, func() error { screen.closed = true; return nil }, nil
+	}
+
+	var out bytes.Buffer
+	cfg := &config.Config{
+		Logger:       logging.NewLogger(logging.LevelError),
+		PRReviewer:   reviewer,
+		OutputWriter: &out,
+	}
+	targets := []config.ReviewTarget{{Repository: repository, Branch: branch}}
+	err := HandleReviewTUI(context.Background(), cfg, runner, targets, nil, "", gitea.ShowDiff)
+
+	var partial *PartialError
+	if !errors.As(err, &partial) || partial.Failed != 1 || partial.Total != 2 {
+		t.Fatalf("HandleReviewTUI() error = %v, want a PartialError for 1 of 2", err)
+	}
+	if !screen.closed {
+		t.Error("screen was not closed")
+	}
+	if want := []string{"test-repo#123  @test-reviewer: approve"}; strings.Join(comments, "|") != strings.Join(want, "|") {
+		t.Errorf("comments = %q, want %q", comments, want)
+	}
+	if !strings.Contains(screen.last, "Update foo") || !strings.Contains(screen.last, "alice") || !strings.Contains(screen.last, "success") {
+		t.Errorf("PR details missing in the last frame:\n%s", screen.last)
+	}
+	for _, want := range []string{"PR 123: approved", "PR 456: skipped"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q. Full output:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,513 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// blobsDirName is the directory inside the cache that holds the key/value blob store.
+// Blobs are stored once per content under objects/<hash[:2]>/<hash>, and index.json
+// maps keys to content hashes and expiry times.
+const blobsDirName = ".blobs"
+
+// blobsLockName is the cache lock protecting the blob index.
+const blobsLockName = ".blobs"
+
+// blobsLockTimeout is how long index updates wait for other processes.
+const blobsLockTimeout = 10 * time.Second
+
+// staleTempAge is the age after which Prune removes temporary files left behind in the
+// blob store, e.g. by an interrupted RememberLines.
+const staleTempAge = 24 * time.Hour
+
+// ErrBlobCorrupt is returned by Get when a stored blob does not match its recorded hash.
+// The corrupt entry is dropped, so the next Get is a regular miss.
+var ErrBlobCorrupt = errors.New("cached blob failed integrity check")
+
+// indexMu serializes index updates within the process, in addition to the file lock
+// that serializes them across processes.
+var indexMu sync.Mutex
+
+// blobIndexEntry describes a stored key.
+type blobIndexEntry struct {
+	Hash      string    `json:"hash"`
+	Size      int64     `json:"size"`
+	StoredAt  time.Time `json:"stored_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // Zero means the entry never expires
+}
+
+func (e blobIndexEntry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// blobIndex maps keys to stored blobs.
+type blobIndex map[string]blobIndexEntry
+
+func (c *Cache) blobsDir() string {
+	return filepath.Join(c.baseDir, blobsDirName)
+}
+
+func (c *Cache) indexPath() string {
+	return filepath.Join(c.blobsDir(), "index.json")
+}
+
+func (c *Cache) objectPath(hash string) string {
+	return filepath.Join(c.blobsDir(), "objects", hash[:2], hash)
+}
+
+// readIndex loads the blob index. A missing index is an empty index.
+func (c *Cache) readIndex() (blobIndex, error) {
+	data, err := os.ReadFile(c.indexPath())
+	if os.IsNotExist(err) {
+		return blobIndex{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache: error reading blob index: %w", err)
+	}
+	index := blobIndex{}
+	if err := json.Unmarshal(data, &index); err != nil {
+		// A damaged index only loses memoized data; start over instead of failing forever.
+		return blobIndex{}, nil
+	}
+	return index, nil
+}
+
+// updateIndex runs fn on the blob index while holding the index lock and writes the result back.
+// Objects that are no longer referenced by any key are removed afterwards.
+func (c *Cache) updateIndex(fn func(index blobIndex) error) error {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), blobsLockTimeout)
+	defer cancel()
+	lock, err := c.Lock(ctx, blobsLockName, LockOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	index, err := c.readIndex()
+	if err != nil {
+		return err
+	}
+	before := referencedHashes(index)
+
+	if err := fn(index); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("cache: error encoding blob index: %w", err)
+	}
+	if err := os.MkdirAll(c.blobsDir(), 0755); err != nil {
+		return fmt.Errorf("cache: error creating blob directory: %w", err)
+	}
+	if err := writeFileAtomic(c.indexPath(), data); err != nil {
+		return fmt.Errorf("cache: error writing blob index: %w", err)
+	}
+
+	after := referencedHashes(index)
+	for hash := range before {
+		if _, used := after[hash]; !used {
+			_ = os.Remove(c.objectPath(hash))
+		}
+	}
+	return nil
+}
+
+// referencedHashes returns the set of object hashes referenced by the index.
+func referencedHashes(index blobIndex) map[string]struct{} {
+	hashes := make(map[string]struct{}, len(index))
+	for _, e := range index {
+		hashes[e.Hash] = struct{}{}
+	}
+	return hashes
+}
+
+// Put stores data under key. The entry expires after ttl; a ttl of 0 means it never expires.
+// Identical data stored under different keys is kept only once.
+func (c *Cache) Put(key string, data []byte, ttl time.Duration) error {
+	sum := sha256.Sum256(data)
+	return c.store(key, hex.EncodeToString(sum[:]), int64(len(data)), ttl, func(objPath string) error {
+		return writeFileAtomic(objPath, data)
+	})
+}
+
+// putFile stores the file at path, whose content has the given hash and size, under key
+// like Put. The file is moved into the store, or removed if the content is already stored.
+func (c *Cache) putFile(key, path, hash string, size int64, ttl time.Duration) error {
+	defer func() { _ = os.Remove(path) }()
+	return c.store(key, hash, size, ttl, func(objPath string) error {
+		if err := os.Chmod(path, 0644); err != nil {
+			return err
+		}
+		return os.Rename(path, objPath)
+	})
+}
+
+// store points key to the object with the given hash, calling write to create the object
+// if it does not exist yet.
+func (c *Cache) store(key, hash string, size int64, ttl time.Duration, write func(objPath string) error) error {
+	if key == "" {
+		return fmt.Errorf("cache: blob key cannot be empty")
+	}
+	now := time.Now()
+	entry := blobIndexEntry{Hash: hash, Size: size, StoredAt: now}
+	if ttl > 0 {
+		entry.ExpiresAt = now.Add(ttl)
+	}
+
+	return c.updateIndex(func(index blobIndex) error {
+		// The object is written while holding the index lock, so that a concurrent
+		// update cannot remove it as unreferenced before the new key points to it.
+		objPath := c.objectPath(hash)
+		if _, err := os.Stat(objPath); err == nil {
+			c.touchObject(hash)
+		} else {
+			if err := os.MkdirAll(filepath.Dir(objPath), 0755); err != nil {
+				return fmt.Errorf("cache: error creating blob directory: %w", err)
+			}
+			if err := write(objPath); err != nil {
+				return fmt.Errorf("cache: error writing blob for %s: %w", key, err)
+			}
+		}
+
+		// Drop expired entries while we are at it, so the store does not grow forever.
+		for k, e := range index {
+			if e.expired(now) {
+				delete(index, k)
+			}
+		}
+		index[key] = entry
+		return nil
+	})
+}
+
+// Get returns the data stored under key. The boolean is false if the key is unknown or expired.
+// The content is verified against its hash; on mismatch the entry is dropped and an error
+// wrapping ErrBlobCorrupt is returned.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+	index, err := c.readIndex()
+	if err != nil {
+		return nil, false, err
+	}
+	entry, found := index[key]
+	if !found || entry.expired(time.Now()) {
+		return nil, false, nil
+	}
+
+	data, err := os.ReadFile(c.objectPath(entry.Hash))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("cache: error reading blob for %s: %w", key, err)
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != entry.Hash {
+		return nil, false, c.dropCorrupt(key, entry.Hash)
+	}
+	c.touchObject(entry.Hash)
+	return data, true, nil
+}
+
+// open returns the file of the data stored under key like Get, but without reading it
+// into memory: the content is verified while reading it once, and the file is then
+// rewound. The caller closes the file.
+func (c *Cache) open(key string) (*os.File, bool, error) {
+	index, err := c.readIndex()
+	if err != nil {
+		return nil, false, err
+	}
+	entry, found := index[key]
+	if !found || entry.expired(time.Now()) {
+		return nil, false, nil
+	}
+
+	f, err := os.Open(c.objectPath(entry.Hash))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("cache: error reading blob for %s: %w", key, err)
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		_ = f.Close()
+		return nil, false, fmt.Errorf("cache: error reading blob for %s: %w", key, err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != entry.Hash {
+		_ = f.Close()
+		return nil, false, c.dropCorrupt(key, entry.Hash)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, false, fmt.Errorf("cache: error reading blob for %s: %w", key, err)
+	}
+	c.touchObject(entry.Hash)
+	return f, true, nil
+}
+
+// touchObject records a use of a stored object in its modification time, which Prune
+// uses as the last access of the keys pointing to it. Failures are ignored.
+func (c *Cache) touchObject(hash string) {
+	now := time.Now()
+	_ = os.Chtimes(c.objectPath(hash), now, now)
+}
+
+// dropCorrupt removes a stored object that failed the integrity check and the key
+// pointing to it, and returns an error wrapping ErrBlobCorrupt.
+func (c *Cache) dropCorrupt(key, hash string) error {
+	_ = os.Remove(c.objectPath(hash))
+	_ = c.updateIndex(func(index blobIndex) error {
+		delete(index, key)
+		return nil
+	})
+	return fmt.Errorf("cache: %w: %s", ErrBlobCorrupt, key)
+}
+
+// Invalidate removes all keys starting with prefix (all keys if prefix is empty)
+// and returns the number of removed keys.
+func (c *Cache) Invalidate(prefix string) (int, error) {
+	var removed int
+	err := c.updateIndex(func(index blobIndex) error {
+		for k := range index {
+			if strings.HasPrefix(k, prefix) {
+				delete(index, k)
+				removed++
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Keys returns the unexpired keys starting with prefix, sorted.
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	index, err := c.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var keys []string
+	for k, e := range index {
+		if strings.HasPrefix(k, prefix) && !e.expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// BlobsSize returns the disk usage of the blob store in bytes.
+func (c *Cache) BlobsSize() (int64, error) {
+	size, err := dirSize(c.blobsDir())
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("cache: error computing size of the blob store: %w", err)
+	}
+	return size, nil
+}
+
+// lookupEntries returns the unexpired keys of index as entries for Prune, with the size
+// and last use of the object they point to.
+func (c *Cache) lookupEntries(index blobIndex, now time.Time) []Entry {
+	var entries []Entry
+	for key, e := range index {
+		if e.expired(now) {
+			continue
+		}
+		path := c.objectPath(e.Hash)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // Nothing to free; Get treats the key as a miss.
+		}
+		entries = append(entries, Entry{Name: key, Path: path, Size: e.Size, LastAccess: info.ModTime(), Lookup: true})
+	}
+	return entries
+}
+
+// dropExpiredBlobs removes expired keys, and thereby the objects only they pointed to,
+// as well as stale temporary files.
+func (c *Cache) dropExpiredBlobs(now time.Time) error {
+	if _, err := os.Stat(c.blobsDir()); os.IsNotExist(err) {
+		return nil
+	}
+	temps, _ := filepath.Glob(filepath.Join(c.blobsDir(), ".*.tmp*"))
+	for _, path := range temps {
+		if info, err := os.Stat(path); err == nil && now.Sub(info.ModTime()) > staleTempAge {
+			_ = os.Remove(path)
+		}
+	}
+	return c.updateIndex(func(index blobIndex) error {
+		for k, e := range index {
+			if e.expired(now) {
+				delete(index, k)
+			}
+		}
+		return nil
+	})
+}
+
+// removeBlobs removes keys from the index; objects no longer referenced are deleted.
+func (c *Cache) removeBlobs(keys []string) error {
+	return c.updateIndex(func(index blobIndex) error {
+		for _, k := range keys {
+			delete(index, k)
+		}
+		return nil
+	})
+}
+
+// Remember returns the data stored under key in the cache at baseDir. On a miss, it calls
+// fetch and stores a successful result for ttl. Memoization is disabled, and fetch is always
+// called, if baseDir is empty or ttl is not positive. Cache failures are not fatal: the
+// data is fetched instead, since the cache is only an optimization.
+func Remember(baseDir, key string, ttl time.Duration, fetch func() ([]byte, error)) ([]byte, error) {
+	if baseDir == "" || ttl <= 0 {
+		return fetch()
+	}
+	c, err := New(baseDir)
+	if err != nil {
+		return fetch()
+	}
+	if data, found, err := c.Get(key); err == nil && found {
+		return data, nil
+	}
+
+	data, err := fetch()
+	if err != nil {
+		return data, err
+	}
+	_ = c.Put(key, data, ttl)
+	return data, nil
+}
+
+// RememberLines is like Remember for data made of lines, which are passed to onLine as they
+// become available: on a hit, the stored lines are replayed; on a miss, fetch emits them
+// while they are produced, e.g. by a streamed command, and they are stored once fetch
+// succeeded. An error of onLine stops fetch and is returned, and nothing is stored.
+//
+// The lines are never held in memory as a whole, so that large outputs can be memoized:
+// on a miss they are written to a temporary file that becomes the stored object, and on
+// a hit the object is verified and then replayed from disk.
+func RememberLines(baseDir, key string, ttl time.Duration, fetch func(emit func(line string) error) error, onLine func(line string) error) error {
+	var c *Cache
+	if baseDir != "" && ttl > 0 {
+		c, _ = New(baseDir)
+	}
+	if c != nil {
+		if f, found, err := c.open(key); err == nil && found {
+			defer f.Close()
+			return replayLines(f, onLine)
+		}
+	}
+
+	var w *lineWriter
+	if c != nil {
+		// Without a temporary file, the lines are only passed on.
+		w, _ = c.newLineWriter()
+	}
+	err := fetch(func(line string) error {
+		w.writeLine(line)
+		return onLine(line)
+	})
+	if err != nil {
+		w.discard()
+		return err
+	}
+	w.store(c, key, ttl)
+	return nil
+}
+
+// replayLines passes the lines read from r to onLine.
+func replayLines(r io.Reader, onLine func(line string) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("cache: error reading blob: %w", err)
+		}
+		if err := onLine(strings.TrimSuffix(line, "\n")); err != nil {
+			return err
+		}
+	}
+}
+
+// lineWriter writes the lines of RememberLines to a temporary file in the blob store,
+// computing their hash on the way. A write error just stops memoization. A nil
+// *lineWriter discards everything.
+type lineWriter struct {
+	file   *os.File
+	buf    *bufio.Writer
+	hasher hash.Hash
+	size   int64
+	err    error
+}
+
+// newLineWriter creates a lineWriter with a new temporary file.
+func (c *Cache) newLineWriter() (*lineWriter, error) {
+	if err := os.MkdirAll(c.blobsDir(), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(c.blobsDir(), ".lines.tmp*")
+	if err != nil {
+		return nil, err
+	}
+	w := &lineWriter{file: file, hasher: sha256.New()}
+	w.buf = bufio.NewWriter(io.MultiWriter(file, w.hasher))
+	return w, nil
+}
+
+func (w *lineWriter) writeLine(line string) {
+	if w == nil || w.err != nil {
+		return
+	}
+	n, err := w.buf.WriteString(line + "\n")
+	w.size += int64(n)
+	w.err = err
+}
+
+// store moves the written lines into the store under key, unless writing them failed.
+func (w *lineWriter) store(c *Cache, key string, ttl time.Duration) {
+	if w == nil {
+		return
+	}
+	if w.err == nil {
+		w.err = w.buf.Flush()
+	}
+	if err := w.file.Close(); w.err == nil {
+		w.err = err
+	}
+	if w.err != nil {
+		_ = os.Remove(w.file.Name())
+		return
+	}
+	_ = c.putFile(key, w.file.Name(), hex.EncodeToString(w.hasher.Sum(nil)), w.size, ttl)
+}
+
+// discard removes the temporary file.
+func (w *lineWriter) discard() {
+	if w == nil {
+		return
+	}
+	_ = w.file.Close()
+	_ = os.Remove(w.file.Name())
+}
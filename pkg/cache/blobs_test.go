@@ -0,0 +1,296 @@
+package cache_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gyr/relx-go/pkg/cache"
+)
+
+func TestPutGet(t *testing.T) {
+	c, _ := cache.New(t.TempDir())
+
+	if _, found, err := c.Get("missing"); found || err != nil {
+		t.Fatalf("Get() of unknown key returned found=%v, err=%v", found, err)
+	}
+
+	if err := c.Put("osc/ls/project", []byte("pkg1\npkg2\n"), time.Hour); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	data, found, err := c.Get("osc/ls/project")
+	if err != nil || !found {
+		t.Fatalf("Get() returned found=%v, err=%v", found, err)
+	}
+	if string(data) != "pkg1\npkg2\n" {
+		t.Errorf("Get() returned %q", data)
+	}
+}
+
+func TestPutDeduplicatesContent(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := cache.New(dir)
+
+	for _, key := range []string{"a", "b"} {
+		if err := c.Put(key, []byte("same content"), 0); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+	}
+
+	objects, err := filepath.Glob(filepath.Join(dir, ".blobs", "objects", "*", "*"))
+	if err != nil {
+		t.Fatalf("Glob() failed: %v", err)
+	}
+	if len(objects) != 1 {
+		t.Errorf("Expected one stored object, got %d", len(objects))
+	}
+
+	// The object must survive as long as one key references it.
+	if _, err := c.Invalidate("a"); err != nil {
+		t.Fatalf("Invalidate() failed: %v", err)
+	}
+	if _, found, _ := c.Get("b"); !found {
+		t.Error("Removing one key removed content still referenced by another key")
+	}
+	if _, err := c.Invalidate("b"); err != nil {
+		t.Fatalf("Invalidate() failed: %v", err)
+	}
+	if objects, _ := filepath.Glob(filepath.Join(dir, ".blobs", "objects", "*", "*")); len(objects) != 0 {
+		t.Errorf("Expected unreferenced objects to be removed, got %v", objects)
+	}
+}
+
+func TestGetExpired(t *testing.T) {
+	c, _ := cache.New(t.TempDir())
+	if err := c.Put("short", []byte("x"), time.Nanosecond); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, found, err := c.Get("short"); found || err != nil {
+		t.Errorf("Get() of expired key returned found=%v, err=%v", found, err)
+	}
+}
+
+func TestGetIntegrityCheck(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := cache.New(dir)
+	if err := c.Put("key", []byte("original"), 0); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	objects, _ := filepath.Glob(filepath.Join(dir, ".blobs", "objects", "*", "*"))
+	if len(objects) != 1 {
+		t.Fatalf("Expected one stored object, got %v", objects)
+	}
+	if err := os.WriteFile(objects[0], []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to tamper with object: %v", err)
+	}
+
+	if _, found, err := c.Get("key"); found || !errors.Is(err, cache.ErrBlobCorrupt) {
+		t.Errorf("Expected ErrBlobCorrupt, got found=%v, err=%v", found, err)
+	}
+	if _, found, err := c.Get("key"); found || err != nil {
+		t.Errorf("Expected corrupt entry to be dropped, got found=%v, err=%v", found, err)
+	}
+}
+
+func TestInvalidateAndKeys(t *testing.T) {
+	c, _ := cache.New(t.TempDir())
+	for _, key := range []string{"gitea/pr-list/repo1/main", "gitea/pr-list/repo2/main", "osc/ls/project"} {
+		if err := c.Put(key, []byte(key), time.Hour); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+	}
+
+	keys, err := c.Keys("gitea/")
+	if err != nil {
+		t.Fatalf("Keys() failed: %v", err)
+	}
+	if strings.Join(keys, ",") != "gitea/pr-list/repo1/main,gitea/pr-list/repo2/main" {
+		t.Errorf("Keys() returned %v", keys)
+	}
+
+	removed, err := c.Invalidate("gitea/pr-list/repo1/")
+	if err != nil || removed != 1 {
+		t.Fatalf("Invalidate() returned %d, %v", removed, err)
+	}
+	if keys, _ := c.Keys(""); len(keys) != 2 {
+		t.Errorf("Expected 2 remaining keys, got %v", keys)
+	}
+
+	if removed, _ := c.Invalidate(""); removed != 2 {
+		t.Errorf("Invalidate(\"\") removed %d keys, want 2", removed)
+	}
+}
+
+func TestConcurrentPut(t *testing.T) {
+	c, _ := cache.New(t.TempDir())
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "key" + string(rune('a'+i))
+			if err := c.Put(key, []byte(key), time.Hour); err != nil {
+				t.Errorf("Put(%s) failed: %v", key, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if keys, _ := c.Keys("key"); len(keys) != 20 {
+		t.Errorf("Expected 20 keys after concurrent puts, got %d", len(keys))
+	}
+}
+
+func TestRemember(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	fetch := func() ([]byte, error) {
+		calls++
+		return []byte("fetched"), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		data, err := cache.Remember(dir, "key", time.Hour, fetch)
+		if err != nil || string(data) != "fetched" {
+			t.Fatalf("Remember() returned %q, %v", data, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected one fetch with memoization, got %d", calls)
+	}
+
+	// Disabled memoization always fetches.
+	calls = 0
+	for _, tc := range []struct {
+		dir string
+		ttl time.Duration
+	}{{"", time.Hour}, {dir, 0}} {
+		if _, err := cache.Remember(tc.dir, "key", tc.ttl, fetch); err != nil {
+			t.Fatalf("Remember() failed: %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected two fetches without memoization, got %d", calls)
+	}
+
+	// Errors are returned and not stored.
+	fetchErr := errors.New("network down")
+	if _, err := cache.Remember(dir, "other", time.Hour, func() ([]byte, error) { return nil, fetchErr }); !errors.Is(err, fetchErr) {
+		t.Errorf("Expected fetch error, got %v", err)
+	}
+	if _, found, _ := mustCache(t, dir).Get("other"); found {
+		t.Error("Failed fetch result was stored")
+	}
+}
+
+func TestRememberLines(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	fetch := func(emit func(line string) error) error {
+		calls++
+		for _, line := range []string{"pkg1", "", "pkg2"} {
+			if err := emit(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		var lines []string
+		err := cache.RememberLines(dir, "key", time.Hour, fetch, func(line string) error {
+			lines = append(lines, line)
+			return nil
+		})
+		if err != nil || strings.Join(lines, ",") != "pkg1,,pkg2" {
+			t.Fatalf("RememberLines() passed %q, %v", lines, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected one fetch with memoization, got %d", calls)
+	}
+
+	// An error of onLine stops fetching, and nothing is stored.
+	stop := errors.New("enough")
+	calls = 0
+	err := cache.RememberLines(dir, "other", time.Hour, fetch, func(line string) error { return stop })
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected the error of onLine after one fetch, got %v after %d", err, calls)
+	}
+	if _, found, _ := mustCache(t, dir).Get("other"); found {
+		t.Error("Stopped fetch result was stored")
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(dir, ".blobs", ".lines.tmp*")); len(tmp) != 0 {
+		t.Errorf("Expected temporary files to be removed, got %v", tmp)
+	}
+}
+
+func TestRememberLinesFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("x", 256*1024) // Longer than the default line limit of bufio.Scanner
+	calls := 0
+	fetch := func(emit func(line string) error) error {
+		calls++
+		for _, line := range []string{"first", long, "last"} {
+			if err := emit(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	remember := func() []string {
+		t.Helper()
+		var lines []string
+		err := cache.RememberLines(dir, "key", time.Hour, fetch, func(line string) error {
+			lines = append(lines, line)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("RememberLines() failed: %v", err)
+		}
+		return lines
+	}
+
+	for i := 0; i < 2; i++ {
+		if lines := remember(); len(lines) != 3 || lines[1] != long || lines[2] != "last" {
+			t.Fatalf("RememberLines() passed %d line(s)", len(lines))
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected one fetch with memoization, got %d", calls)
+	}
+	data, found, err := mustCache(t, dir).Get("key")
+	if err != nil || !found || string(data) != "first\n"+long+"\nlast\n" {
+		t.Errorf("Unexpected stored lines: found=%v, err=%v, %d bytes", found, err, len(data))
+	}
+
+	// A corrupt object is not replayed, but fetched again.
+	objects, _ := filepath.Glob(filepath.Join(dir, ".blobs", "objects", "*", "*"))
+	if len(objects) != 1 {
+		t.Fatalf("Expected one stored object, got %v", objects)
+	}
+	if err := os.WriteFile(objects[0], []byte("tampered\n"), 0644); err != nil {
+		t.Fatalf("Failed to tamper with object: %v", err)
+	}
+	if lines := remember(); len(lines) != 3 || lines[0] != "first" {
+		t.Errorf("Expected the fetched lines after corruption, got %d line(s)", len(lines))
+	}
+	if calls != 2 {
+		t.Errorf("Expected a second fetch after corruption, got %d", calls)
+	}
+}
+
+func mustCache(t *testing.T, dir string) *cache.Cache {
+	t.Helper()
+	c, err := cache.New(dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	return c
+}
@@ -1,9 +1,15 @@
 package cache
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
 // Cache handles the caching of artifacts.
@@ -41,3 +47,285 @@ func (c *Cache) Has(artifactName string, innerPath string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+// metaDirName is the directory inside the cache that holds access metadata.
+// Entries whose name starts with a dot are internal and never listed or evicted.
+const metaDirName = ".meta"
+
+// Entry describes a top-level artifact in the cache.
+type Entry struct {
+	// Name is the artifact name, as used with GetPath.
+	Name string
+	// Path is the full path of the artifact.
+	Path string
+	// Size is the total size of the artifact in bytes (recursively for directories).
+	Size int64
+	// LastAccess is the last time the artifact was used. If no access was recorded,
+	// the modification time of the artifact is used.
+	LastAccess time.Time
+	// Lookup is true for a memoized lookup of the blob store, which Prune reports by its
+	// key in Name. Path is then the stored object.
+	Lookup bool
+}
+
+// accessMeta is the access metadata stored for each artifact.
+type accessMeta struct {
+	LastAccess time.Time `json:"last_access"`
+}
+
+// metaPath returns the path of the access metadata file of an artifact.
+func (c *Cache) metaPath(artifactName string) string {
+	return filepath.Join(c.baseDir, metaDirName, artifactName+".json")
+}
+
+// Touch records that an artifact was used now. The access time drives LRU eviction in Prune.
+func (c *Cache) Touch(artifactName string) error {
+	path := c.metaPath(artifactName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("cache: error creating metadata directory: %w", err)
+	}
+	data, err := json.Marshal(accessMeta{LastAccess: time.Now()})
+	if err != nil {
+		return fmt.Errorf("cache: error encoding access metadata for %s: %w", artifactName, err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("cache: error writing access metadata for %s: %w", artifactName, err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to path,
+// so that concurrent readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Entries returns all artifacts in the cache, sorted by name.
+func (c *Cache) Entries() ([]Entry, error) {
+	dirEntries, err := os.ReadDir(c.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cache: error reading cache directory %s: %w", c.baseDir, err)
+	}
+
+	var entries []Entry
+	for _, de := range dirEntries {
+		if strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		entry, err := c.entry(de.Name())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// entry collects size and access information for a single artifact.
+func (c *Cache) entry(artifactName string) (Entry, error) {
+	path := c.GetPath(artifactName)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Entry{}, fmt.Errorf("cache: error reading %s: %w", path, err)
+	}
+
+	entry := Entry{Name: artifactName, Path: path, LastAccess: info.ModTime()}
+	if data, err := os.ReadFile(c.metaPath(artifactName)); err == nil {
+		var meta accessMeta
+		if err := json.Unmarshal(data, &meta); err == nil && !meta.LastAccess.IsZero() {
+			entry.LastAccess = meta.LastAccess
+		}
+	}
+
+	entry.Size, err = dirSize(path)
+	if err != nil {
+		return Entry{}, fmt.Errorf("cache: error computing size of %s: %w", path, err)
+	}
+
+	return entry, nil
+}
+
+// dirSize returns the total size of the regular files below path.
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Remove deletes an artifact and its access metadata from the cache.
+// It fails with an error wrapping ErrLocked if the artifact is locked by someone else.
+func (c *Cache) Remove(artifactName string) error {
+	if artifactName == "" || strings.HasPrefix(artifactName, ".") || strings.ContainsRune(artifactName, filepath.Separator) {
+		return fmt.Errorf("cache: invalid artifact name %q", artifactName)
+	}
+	lock, err := c.TryLock(artifactName)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	if err := os.RemoveAll(c.GetPath(artifactName)); err != nil {
+		return fmt.Errorf("cache: error removing %s: %w", artifactName, err)
+	}
+	if err := os.Remove(c.metaPath(artifactName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cache: error removing access metadata for %s: %w", artifactName, err)
+	}
+	return nil
+}
+
+// Clear removes all artifacts from the cache and returns the removed ones.
+// Artifacts that are locked by someone else are skipped and reported in the error.
+func (c *Cache) Clear() ([]Entry, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Entry
+	var busy []string
+	for _, entry := range entries {
+		if err := c.Remove(entry.Name); err != nil {
+			if errors.Is(err, ErrLocked) {
+				busy = append(busy, entry.Name)
+				continue
+			}
+			return removed, err
+		}
+		removed = append(removed, entry)
+	}
+	if len(busy) > 0 {
+		return removed, fmt.Errorf("cache: %w: skipped %s", ErrLocked, strings.Join(busy, ", "))
+	}
+	return removed, nil
+}
+
+// PruneOptions controls which artifacts Prune evicts.
+type PruneOptions struct {
+	// MaxSize is the maximum total size of the cache in bytes. 0 means unlimited.
+	MaxSize int64
+	// MaxAge is the maximum time since an artifact was last used. 0 means unlimited.
+	MaxAge time.Duration
+	// Keep lists artifacts that must not be evicted, e.g. because they are in use.
+	Keep []string
+	// DryRun only reports the artifacts that would be evicted.
+	DryRun bool
+	// Now is the reference time for MaxAge. The zero value means time.Now().
+	Now time.Time
+}
+
+// Prune evicts artifacts that were not used within MaxAge, and then the least recently
+// used artifacts until the cache fits into MaxSize. Locked artifacts are skipped.
+// Memoized lookups of the blob store count towards MaxSize and are evicted the same way;
+// expired ones are always dropped. It returns the evicted artifacts and lookups.
+func (c *Cache) Prune(opts PruneOptions) ([]Entry, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if !opts.DryRun {
+		if err := c.dropExpiredBlobs(now); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, err
+	}
+	index, err := c.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	blobsSize, err := c.BlobsSize()
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]struct{}, len(opts.Keep))
+	for _, name := range opts.Keep {
+		keep[name] = struct{}{}
+	}
+
+	total := blobsSize
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	// Identical content stored under several keys is only freed with the last of them.
+	refs := make(map[string]int, len(index))
+	for _, e := range index {
+		refs[e.Hash]++
+	}
+	entries = append(entries, c.lookupEntries(index, now)...)
+
+	// Least recently used first.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastAccess.Before(entries[j].LastAccess) })
+
+	var evicted []Entry
+	var keys []string
+	for _, entry := range entries {
+		if _, exists := keep[entry.Name]; exists && !entry.Lookup {
+			continue
+		}
+		expired := opts.MaxAge > 0 && now.Sub(entry.LastAccess) > opts.MaxAge
+		oversized := opts.MaxSize > 0 && total > opts.MaxSize
+		if !expired && !oversized {
+			continue
+		}
+		if entry.Lookup {
+			hash := index[entry.Name].Hash
+			if refs[hash]--; refs[hash] == 0 {
+				total -= entry.Size
+			}
+			keys = append(keys, entry.Name)
+			evicted = append(evicted, entry)
+			continue
+		}
+		if !opts.DryRun {
+			if err := c.Remove(entry.Name); err != nil {
+				if errors.Is(err, ErrLocked) {
+					continue // In use by another process; try again next time.
+				}
+				return evicted, err
+			}
+		}
+		total -= entry.Size
+		evicted = append(evicted, entry)
+	}
+
+	if !opts.DryRun && len(keys) > 0 {
+		if err := c.removeBlobs(keys); err != nil {
+			return evicted, err
+		}
+	}
+	return evicted, nil
+}
@@ -1,9 +1,13 @@
 package cache_test
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gyr/relx-go/pkg/cache"
 )
@@ -86,3 +90,190 @@ func TestHas(t *testing.T) {
 		t.Error("Has() returned true for existent directory with non-existent inner path")
 	}
 }
+
+// createArtifact writes an artifact directory with a file of the given size and last use time.
+func createArtifact(t *testing.T, c *cache.Cache, name string, size int, lastUse time.Time) {
+	t.Helper()
+	dir := c.GetPath(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create artifact %s: %v", name, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data"), make([]byte, size), 0644); err != nil {
+		t.Fatalf("Failed to write artifact %s: %v", name, err)
+	}
+	// Without access metadata, the modification time is used as last access.
+	if err := os.Chtimes(dir, lastUse, lastUse); err != nil {
+		t.Fatalf("Failed to set times of artifact %s: %v", name, err)
+	}
+}
+
+func entryNames(entries []cache.Entry) []string {
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	return names
+}
+
+func TestEntriesAndTouch(t *testing.T) {
+	c, _ := cache.New(t.TempDir())
+	old := time.Now().Add(-48 * time.Hour)
+	createArtifact(t, c, "repo-b", 100, old)
+	createArtifact(t, c, "repo-a", 50, old)
+
+	if err := c.Touch("repo-b"); err != nil {
+		t.Fatalf("Touch() failed: %v", err)
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		t.Fatalf("Entries() failed: %v", err)
+	}
+	if got := strings.Join(entryNames(entries), ","); got != "repo-a,repo-b" {
+		t.Fatalf("Entries() returned %s, want repo-a,repo-b (metadata directory must be hidden)", got)
+	}
+	if entries[0].Size != 50 || entries[1].Size != 100 {
+		t.Errorf("Unexpected sizes: %d, %d", entries[0].Size, entries[1].Size)
+	}
+	if entries[0].LastAccess.Sub(old).Abs() > time.Second {
+		t.Errorf("Expected repo-a to fall back to its modification time, got %v", entries[0].LastAccess)
+	}
+	if time.Since(entries[1].LastAccess) > time.Minute {
+		t.Errorf("Expected repo-b to use the recorded access time, got %v", entries[1].LastAccess)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	setup := func(t *testing.T) *cache.Cache {
+		c, _ := cache.New(t.TempDir())
+		createArtifact(t, c, "oldest", 100, now.Add(-30*24*time.Hour))
+		createArtifact(t, c, "older", 100, now.Add(-2*24*time.Hour))
+		createArtifact(t, c, "recent", 100, now.Add(-time.Hour))
+		return c
+	}
+
+	t.Run("MaxAge", func(t *testing.T) {
+		c := setup(t)
+		evicted, err := c.Prune(cache.PruneOptions{MaxAge: 7 * 24 * time.Hour, Now: now})
+		if err != nil {
+			t.Fatalf("Prune() failed: %v", err)
+		}
+		if got := strings.Join(entryNames(evicted), ","); got != "oldest" {
+			t.Errorf("Evicted %s, want oldest", got)
+		}
+		if c.Has("oldest", "") {
+			t.Error("Evicted artifact still exists")
+		}
+	})
+
+	t.Run("MaxSizeEvictsLeastRecentlyUsed", func(t *testing.T) {
+		c := setup(t)
+		evicted, err := c.Prune(cache.PruneOptions{MaxSize: 150, Now: now})
+		if err != nil {
+			t.Fatalf("Prune() failed: %v", err)
+		}
+		if got := strings.Join(entryNames(evicted), ","); got != "oldest,older" {
+			t.Errorf("Evicted %s, want oldest,older", got)
+		}
+		if !c.Has("recent", "") {
+			t.Error("Most recently used artifact was evicted")
+		}
+	})
+
+	t.Run("KeepAndDryRun", func(t *testing.T) {
+		c := setup(t)
+		evicted, err := c.Prune(cache.PruneOptions{MaxSize: 1, Keep: []string{"oldest"}, DryRun: true, Now: now})
+		if err != nil {
+			t.Fatalf("Prune() failed: %v", err)
+		}
+		if got := strings.Join(entryNames(evicted), ","); got != "older,recent" {
+			t.Errorf("Evicted %s, want older,recent", got)
+		}
+		for _, name := range []string{"oldest", "older", "recent"} {
+			if !c.Has(name, "") {
+				t.Errorf("Dry run removed %s", name)
+			}
+		}
+	})
+}
+
+func TestPruneBlobs(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	c, _ := cache.New(dir)
+	createArtifact(t, c, "oldest", 100, now.Add(-30*24*time.Hour))
+	createArtifact(t, c, "recent", 100, now.Add(-time.Hour))
+	stale := []byte(strings.Repeat("x", 1000))
+	for key, data := range map[string][]byte{"stale": stale, "fresh": []byte("fresh"), "expiring": []byte("expiring")} {
+		ttl := time.Duration(0)
+		if key == "expiring" {
+			ttl = time.Hour
+		}
+		if err := c.Put(key, data, ttl); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+	}
+	sum := sha256.Sum256(stale)
+	hash := hex.EncodeToString(sum[:])
+	staleObject := filepath.Join(dir, ".blobs", "objects", hash[:2], hash)
+	lastUse := now.Add(-10 * 24 * time.Hour)
+	if err := os.Chtimes(staleObject, lastUse, lastUse); err != nil {
+		t.Fatalf("Failed to set object time: %v", err)
+	}
+
+	size, err := c.BlobsSize()
+	if err != nil || size <= 1000 {
+		t.Fatalf("BlobsSize() = %d, %v; want more than the stored content", size, err)
+	}
+
+	// After the expired key is dropped, evicting the oldest artifact and the stale lookup
+	// brings the cache below the limit.
+	evicted, err := c.Prune(cache.PruneOptions{MaxSize: 500, Now: now.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if got := strings.Join(entryNames(evicted), ","); got != "oldest,stale" {
+		t.Errorf("Evicted %s, want oldest,stale", got)
+	}
+	if !evicted[1].Lookup {
+		t.Error("Evicted blob is not reported as a lookup")
+	}
+	if _, err := os.Stat(staleObject); !os.IsNotExist(err) {
+		t.Error("Expected the object of the evicted lookup to be removed")
+	}
+	keys, _ := c.Keys("")
+	if got := strings.Join(keys, ","); got != "fresh" {
+		t.Errorf("Keys() = %s, want fresh", got)
+	}
+}
+
+func TestRemoveAndClear(t *testing.T) {
+	c, _ := cache.New(t.TempDir())
+	createArtifact(t, c, "a", 1, time.Now())
+	createArtifact(t, c, "b", 1, time.Now())
+	if err := c.Touch("a"); err != nil {
+		t.Fatalf("Touch() failed: %v", err)
+	}
+
+	if err := c.Remove("a"); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if c.Has("a", "") || c.Has(".meta", "a.json") {
+		t.Error("Remove() left the artifact or its metadata behind")
+	}
+	if err := c.Remove("../outside"); err == nil {
+		t.Error("Remove() accepted a name outside the cache")
+	}
+
+	cleared, err := c.Clear()
+	if err != nil {
+		t.Fatalf("Clear() failed: %v", err)
+	}
+	if len(cleared) != 1 || cleared[0].Name != "b" {
+		t.Errorf("Clear() returned %v, want [b]", entryNames(cleared))
+	}
+	if entries, _ := c.Entries(); len(entries) != 0 {
+		t.Errorf("Cache not empty after Clear(): %v", entryNames(entries))
+	}
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// locksDirName is the directory inside the cache that holds the lock files.
+const locksDirName = ".locks"
+
+// lockPollInterval is how often a contended lock is retried.
+const lockPollInterval = 100 * time.Millisecond
+
+var (
+	// ErrLockTimeout is returned when a lock could not be acquired within the timeout.
+	ErrLockTimeout = errors.New("timed out waiting for cache lock")
+	// ErrLocked is returned when an entry is locked by another process and the caller does not wait.
+	ErrLocked = errors.New("cache entry is locked")
+)
+
+// LockOptions controls how Lock waits for a contended lock.
+type LockOptions struct {
+	// Timeout is the maximum time to wait for the lock. 0 means wait until the context is done.
+	Timeout time.Duration
+	// OnWait, if set, is called once when the lock is held by someone else, with the PID
+	// recorded by the holder (0 if unknown).
+	OnWait func(holderPID int)
+}
+
+// Lock is an advisory, exclusive lock on a cache entry.
+// Locks are based on flock(2) and are released automatically when the process exits.
+type Lock struct {
+	file *os.File
+}
+
+// lockPath returns the path of the lock file of an artifact.
+func (c *Cache) lockPath(artifactName string) string {
+	return filepath.Join(c.baseDir, locksDirName, artifactName+".lock")
+}
+
+// openLockFile opens (and creates) the lock file of an artifact.
+func (c *Cache) openLockFile(artifactName string) (*os.File, error) {
+	path := c.lockPath(artifactName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("cache: error creating locks directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("cache: error opening lock file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Lock acquires the exclusive lock of an artifact, waiting while another process
+// (or goroutine) holds it. Every writer of a cache entry must hold its lock.
+func (c *Cache) Lock(ctx context.Context, artifactName string, opts LockOptions) (*Lock, error) {
+	f, err := c.openLockFile(artifactName)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	notified := false
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("cache: error locking %s: %w", artifactName, err)
+		}
+		if locked {
+			return newLock(f)
+		}
+
+		holder := c.lockHolder(artifactName)
+		if !notified && opts.OnWait != nil {
+			opts.OnWait(holder)
+			notified = true
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("cache: %w %s held by PID %d", ErrLockTimeout, artifactName, holder)
+			}
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// TryLock acquires the exclusive lock of an artifact without waiting.
+// It returns an error wrapping ErrLocked if the lock is held by someone else.
+func (c *Cache) TryLock(artifactName string) (*Lock, error) {
+	f, err := c.openLockFile(artifactName)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := tryLockFile(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("cache: error locking %s: %w", artifactName, err)
+	}
+	if !locked {
+		_ = f.Close()
+		return nil, fmt.Errorf("cache: %w: %s is held by PID %d", ErrLocked, artifactName, c.lockHolder(artifactName))
+	}
+	return newLock(f)
+}
+
+// newLock records the current PID in an acquired lock file.
+func newLock(f *os.File) (*Lock, error) {
+	pid := []byte(strconv.Itoa(os.Getpid()) + "\n")
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt(pid, 0)
+	}
+	return &Lock{file: f}, nil
+}
+
+// lockHolder returns the PID recorded in the lock file of an artifact, or 0 if unknown.
+func (c *Cache) lockHolder(artifactName string) int {
+	data, err := os.ReadFile(c.lockPath(artifactName))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// Unlock releases the lock. It is safe to call Unlock on a nil Lock.
+func (l *Lock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	// The lock file itself is kept: removing it would race with processes waiting on it.
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package cache
+
+import "os"
+
+// tryLockFile always succeeds: advisory file locks are only implemented on unix systems.
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlockFile is a no-op on systems without flock.
+func unlockFile(f *os.File) error {
+	return nil
+}
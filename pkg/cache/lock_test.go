@@ -0,0 +1,85 @@
+//go:build unix
+
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gyr/relx-go/pkg/cache"
+)
+
+func TestLock(t *testing.T) {
+	c, _ := cache.New(t.TempDir())
+
+	first, err := c.Lock(context.Background(), "repo", cache.LockOptions{})
+	if err != nil {
+		t.Fatalf("Lock() failed: %v", err)
+	}
+
+	t.Run("TimeoutReportsHolder", func(t *testing.T) {
+		var waitedFor int
+		_, err := c.Lock(context.Background(), "repo", cache.LockOptions{
+			Timeout: 250 * time.Millisecond,
+			OnWait:  func(pid int) { waitedFor = pid },
+		})
+		if !errors.Is(err, cache.ErrLockTimeout) {
+			t.Fatalf("Expected ErrLockTimeout, got %v", err)
+		}
+		if waitedFor != os.Getpid() {
+			t.Errorf("OnWait reported PID %d, want %d", waitedFor, os.Getpid())
+		}
+	})
+
+	t.Run("TryLockAndRemoveFailWhileLocked", func(t *testing.T) {
+		if _, err := c.TryLock("repo"); !errors.Is(err, cache.ErrLocked) {
+			t.Errorf("Expected TryLock() to fail with ErrLocked, got %v", err)
+		}
+		if err := os.MkdirAll(c.GetPath("repo"), 0755); err != nil {
+			t.Fatalf("Failed to create artifact: %v", err)
+		}
+		if err := c.Remove("repo"); !errors.Is(err, cache.ErrLocked) {
+			t.Errorf("Expected Remove() to fail with ErrLocked, got %v", err)
+		}
+		evicted, err := c.Prune(cache.PruneOptions{MaxAge: time.Nanosecond, Now: time.Now().Add(time.Hour)})
+		if err != nil || len(evicted) != 0 {
+			t.Errorf("Expected Prune() to skip the locked entry, got %v, %v", evicted, err)
+		}
+	})
+
+	t.Run("WaiterAcquiresAfterUnlock", func(t *testing.T) {
+		acquired := make(chan error, 1)
+		go func() {
+			second, err := c.Lock(context.Background(), "repo", cache.LockOptions{Timeout: 5 * time.Second})
+			if err == nil {
+				err = second.Unlock()
+			}
+			acquired <- err
+		}()
+
+		time.Sleep(150 * time.Millisecond)
+		if err := first.Unlock(); err != nil {
+			t.Fatalf("Unlock() failed: %v", err)
+		}
+		if err := <-acquired; err != nil {
+			t.Errorf("Waiting Lock() failed after unlock: %v", err)
+		}
+	})
+
+	t.Run("ContextCancelled", func(t *testing.T) {
+		held, err := c.TryLock("other")
+		if err != nil {
+			t.Fatalf("TryLock() failed: %v", err)
+		}
+		defer func() { _ = held.Unlock() }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := c.Lock(ctx, "other", cache.LockOptions{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,252 @@
+// Package cli is a small framework for command-line programs with nested subcommands.
+// Each command declares its flags, arguments, help text and action; the package parses
+// short and long flags, prints help for `<program> help <command>` and generates
+// bash, zsh and fish completion scripts.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Action runs a command with its positional arguments.
+type Action func(args []string) error
+
+// Completer returns candidates for shell completion. Candidates not matching the word
+// being completed are filtered out by the caller.
+type Completer func() []string
+
+// Command is a command of a program. A command either has subcommands or an action.
+// The root command is the program itself; its flags are the global flags, which are also
+// accepted after the name of a subcommand unless the subcommand has a flag of that name.
+type Command struct {
+	Name    string
+	Args    string // Synopsis of the positional arguments, e.g. "<path>..."
+	Summary string // One line shown in command lists
+	Help    string // Longer description shown by help; Summary if empty
+	// Setup defines the flags of the command and returns its action, which reads the
+	// flag values. It is called once per run, and also to print help and to complete.
+	// Commands with subcommands return nil.
+	Setup func(fs *FlagSet) Action
+	// Complete returns candidates for the positional arguments in shell completion.
+	Complete Completer
+	// Commands are the subcommands.
+	Commands []*Command
+	// Hidden commands are not listed in help and completion.
+	Hidden bool
+	// Before is called on the root command with the selected command after all flags
+	// are parsed and before its action runs, e.g. to load the configuration.
+	Before func(cmd *Command) error
+
+	parent *Command
+	flags  *FlagSet
+	action Action
+}
+
+// UsageError reports a wrong invocation of a command. Execute prints it together with
+// the usage of the command.
+type UsageError struct {
+	Command *Command
+	Message string
+}
+
+func (e *UsageError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("usage: %s", e.Command.FullName())
+	}
+	return e.Message
+}
+
+// Usagef returns a UsageError for the running command. Actions return it for wrong
+// arguments, e.g. cli.Usagef("you must provide at least one path").
+func Usagef(format string, args ...interface{}) error {
+	return &UsageError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Execute runs the command selected by args, which do not include the program name.
+// Help requested with -h, --help or the help command is printed to stdout. Usage errors
+// are printed to stderr together with the usage of the command and returned as
+// *UsageError. Errors of the action and of Before are returned unchanged.
+func (c *Command) Execute(args []string, stdout, stderr io.Writer) error {
+	c.link()
+	err := c.run(args, stdout)
+	var usage *UsageError
+	if errors.As(err, &usage) {
+		if usage.Message != "" {
+			fmt.Fprintf(stderr, "Error: %s\n\n", usage.Message)
+		}
+		usage.Command.PrintHelp(stderr)
+	}
+	return err
+}
+
+// FullName returns the names of the command and its parents, e.g. "relx-go repo sync".
+func (c *Command) FullName() string {
+	if c.parent == nil {
+		return c.Name
+	}
+	return c.parent.FullName() + " " + c.Name
+}
+
+// Path returns the names of the command and its parents without the root,
+// e.g. ["repo", "sync"].
+func (c *Command) Path() []string {
+	if c.parent == nil {
+		return nil
+	}
+	return append(c.parent.Path(), c.Name)
+}
+
+// Lookup returns the subcommand named name, or nil.
+func (c *Command) Lookup(name string) *Command {
+	for _, sub := range c.Commands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// root returns the root command.
+func (c *Command) root() *Command {
+	for c.parent != nil {
+		c = c.parent
+	}
+	return c
+}
+
+// link sets the parents of all subcommands.
+func (c *Command) link() {
+	for _, sub := range c.Commands {
+		sub.parent = c
+		sub.link()
+	}
+}
+
+// flagSet returns the flags of the command, calling Setup on first use. The flags of
+// subcommands include the global flags.
+func (c *Command) flagSet() *FlagSet {
+	if c.flags == nil {
+		c.flags = newFlagSet(c.Name)
+		if c.Setup != nil {
+			c.action = c.Setup(c.flags)
+		}
+		if c.parent != nil {
+			c.flags.inherit(c.root().flagSet())
+		}
+	}
+	return c.flags
+}
+
+// run parses the flags of the command and runs it or the selected subcommand.
+func (c *Command) run(args []string, stdout io.Writer) error {
+	fs := c.flagSet()
+	if err := fs.set.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			c.PrintHelp(stdout)
+			return nil
+		}
+		return &UsageError{Command: c, Message: err.Error()}
+	}
+	args = fs.set.Args()
+
+	if len(c.Commands) > 0 {
+		if len(args) == 0 {
+			return &UsageError{Command: c}
+		}
+		if args[0] == "help" {
+			return c.help(args[1:], stdout)
+		}
+		sub := c.Lookup(args[0])
+		if sub == nil {
+			return &UsageError{Command: c, Message: fmt.Sprintf("unknown command '%s'", strings.Join(append(c.Path(), args[0]), " "))}
+		}
+		return sub.run(args[1:], stdout)
+	}
+
+	if before := c.root().Before; before != nil {
+		if err := before(c); err != nil {
+			return err
+		}
+	}
+	if c.action == nil {
+		return fmt.Errorf("cli: command '%s' has no action", c.FullName())
+	}
+	err := c.action(args)
+	var usage *UsageError
+	if errors.As(err, &usage) && usage.Command == nil {
+		usage.Command = c
+	}
+	return err
+}
+
+// help prints the help of the subcommand selected by path, or of c if path is empty.
+func (c *Command) help(path []string, stdout io.Writer) error {
+	cmd := c
+	for _, name := range path {
+		sub := cmd.Lookup(name)
+		if sub == nil {
+			return &UsageError{Command: cmd, Message: fmt.Sprintf("unknown command '%s'", strings.Join(append(cmd.Path(), name), " "))}
+		}
+		cmd = sub
+	}
+	cmd.PrintHelp(stdout)
+	return nil
+}
+
+// PrintHelp writes the usage, description, subcommands and flags of the command to w.
+func (c *Command) PrintHelp(w io.Writer) {
+	synopsis := []string{"Usage:", c.FullName()}
+	if len(c.flagSet().flags) > 0 {
+		synopsis = append(synopsis, "[flags]")
+	}
+	if len(c.Commands) > 0 {
+		synopsis = append(synopsis, "<command>")
+	}
+	if c.Args != "" {
+		synopsis = append(synopsis, c.Args)
+	}
+	fmt.Fprintln(w, strings.Join(synopsis, " "))
+
+	if help := c.helpText(); help != "" {
+		fmt.Fprintf(w, "\n%s\n", help)
+	}
+
+	if len(c.Commands) > 0 {
+		fmt.Fprintf(w, "\nCommands:\n")
+		tw := newTable(w)
+		for _, sub := range c.Commands {
+			if !sub.Hidden {
+				fmt.Fprintf(tw, "  %s\t%s\n", sub.Name, sub.Summary)
+			}
+		}
+		fmt.Fprintf(tw, "  help\tShow help for a command\n")
+		tw.Flush()
+	}
+
+	printFlags(w, "Flags", c.flagSet().flags)
+	if len(c.Commands) == 0 {
+		printFlags(w, "Global flags", c.flagSet().global)
+	}
+
+	if len(c.Commands) > 0 {
+		fmt.Fprintf(w, "\nRun '%s help <command>' for more information on a command.\n", c.FullName())
+	}
+}
+
+// helpText returns the description of the command.
+func (c *Command) helpText() string {
+	if c.Help != "" {
+		return strings.TrimSpace(c.Help)
+	}
+	return c.Summary
+}
+
+// newTable returns a tabwriter for aligned columns in help texts.
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+}
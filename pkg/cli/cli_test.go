@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestCommand returns a program with a global flag, a command with flags and
+// a group of subcommands. The actions record how they were called in calls.
+func newTestCommand(calls *[]string) *Command {
+	var verbose *bool
+	return &Command{
+		Name: "prog",
+		Setup: func(fs *FlagSet) Action {
+			verbose = fs.Bool("v", "verbose", "Verbose output")
+			return nil
+		},
+		Before: func(cmd *Command) error {
+			*calls = append(*calls, "before "+strings.Join(cmd.Path(), " "))
+			return nil
+		},
+		Commands: []*Command{
+			{
+				Name:    "cat",
+				Args:    "<path>...",
+				Summary: "Print files",
+				Setup: func(fs *FlagSet) Action {
+					branch := fs.String("b", "branch", "<ref>", "main", "Branch to read from")
+					fs.SetCompleter("branch", func() []string { return []string{"main", "maint"} })
+					return func(args []string) error {
+						if len(args) == 0 {
+							return Usagef("at least one path is required")
+						}
+						*calls = append(*calls, "cat "+*branch+" "+strings.Join(args, " ")+" verbose="+map[bool]string{true: "yes", false: "no"}[*verbose])
+						return nil
+					}
+				},
+			},
+			{
+				Name:    "repo",
+				Summary: "Manage repositories",
+				Commands: []*Command{
+					{
+						Name:     "sync",
+						Summary:  "Sync repositories",
+						Complete: func() []string { return []string{"sles", "slfo"} },
+						Setup: func(fs *FlagSet) Action {
+							return func(args []string) error {
+								*calls = append(*calls, "sync "+strings.Join(args, " "))
+								return nil
+							}
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantCalls []string
+		wantUsage string // Expected UsageError message, if any
+		wantOut   string // Expected in stdout
+		wantErr   string // Expected in stderr
+	}{
+		{"ShortFlags", []string{"-v", "cat", "-b", "dev", "a", "b"}, []string{"before cat", "cat dev a b verbose=yes"}, "", "", ""},
+		{"LongFlags", []string{"--verbose", "cat", "--branch=dev", "a"}, []string{"before cat", "cat dev a verbose=yes"}, "", "", ""},
+		{"Default", []string{"cat", "a"}, []string{"before cat", "cat main a verbose=no"}, "", "", ""},
+		{"Subcommand", []string{"repo", "sync", "sles"}, []string{"before repo sync", "sync sles"}, "", "", ""},
+		{"GlobalFlagAfterCommand", []string{"cat", "-b", "dev", "--verbose", "a"}, []string{"before cat", "cat dev a verbose=yes"}, "", "", ""},
+		{"GlobalFlagInGroup", []string{"repo", "-v", "sync", "sles"}, []string{"before repo sync", "sync sles"}, "", "", ""},
+		{"MissingArgs", []string{"cat"}, []string{"before cat"}, "at least one path is required", "", "Usage: prog cat [flags] <path>..."},
+		{"UnknownFlag", []string{"cat", "--bogus"}, nil, "flag provided but not defined: -bogus", "", "Error: flag provided but not defined"},
+		{"UnknownCommand", []string{"repo", "bogus"}, nil, "unknown command 'repo bogus'", "", "Usage: prog repo <command>"},
+		{"NoCommand", []string{}, nil, "", "", "Usage: prog [flags] <command>"},
+		{"Help", []string{"help", "repo", "sync"}, nil, "", "Usage: prog repo sync", ""},
+		{"HelpFlag", []string{"cat", "--help"}, nil, "", "-b, --branch <ref>", ""},
+		{"HelpGlobalFlags", []string{"help", "cat"}, nil, "", "Global flags:\n  -v, --verbose", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			var stdout, stderr bytes.Buffer
+			err := newTestCommand(&calls).Execute(tt.args, &stdout, &stderr)
+
+			var usage *UsageError
+			switch {
+			case tt.wantUsage != "" || tt.wantErr != "":
+				if !errors.As(err, &usage) {
+					t.Fatalf("Expected a UsageError, got %v", err)
+				}
+				if !strings.Contains(usage.Message, tt.wantUsage) {
+					t.Errorf("Expected usage error %q, got %q", tt.wantUsage, usage.Message)
+				}
+			case err != nil:
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("Expected calls %q, got %q", tt.wantCalls, calls)
+			}
+			if !strings.Contains(stdout.String(), tt.wantOut) {
+				t.Errorf("Expected stdout to contain %q, got:\n%s", tt.wantOut, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Errorf("Expected stderr to contain %q, got:\n%s", tt.wantErr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{""}, []string{"cat", "help", "repo"}},
+		{[]string{"c"}, []string{"cat"}},
+		{[]string{"-"}, []string{"--verbose"}},
+		{[]string{"-v", "repo", ""}, []string{"help", "sync"}},
+		{[]string{"repo", "sync", "sl"}, []string{"sles", "slfo"}},
+		{[]string{"cat", "--"}, []string{"--branch", "--verbose"}},
+		{[]string{"repo", "sync", "--v"}, []string{"--verbose"}},
+		{[]string{"cat", "-b", "mai"}, []string{"main", "maint"}},
+		{[]string{"cat", "--branch", "main", ""}, nil},
+		{[]string{"help", "re"}, []string{"repo"}},
+	}
+
+	for _, tt := range tests {
+		var calls []string
+		if got := Complete(newTestCommand(&calls), tt.words); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q): expected %q, got %q", tt.words, tt.want, got)
+		}
+	}
+}
+
+func TestCompletionCommands(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var calls []string
+		var stdout, stderr bytes.Buffer
+		root := newTestCommand(&calls)
+		root.Commands = append(root.Commands, CompletionCommands(root.Name, &stdout)...)
+
+		if err := root.Execute([]string{"completion", shell}, &stdout, &stderr); err != nil {
+			t.Fatalf("completion %s failed: %v", shell, err)
+		}
+		if !strings.Contains(stdout.String(), "prog __complete -- ") {
+			t.Errorf("Expected the %s script to call the completion command, got:\n%s", shell, stdout.String())
+		}
+	}
+
+	var calls []string
+	var stdout, stderr bytes.Buffer
+	root := newTestCommand(&calls)
+	root.Commands = append(root.Commands, CompletionCommands(root.Name, &stdout)...)
+	if err := root.Execute([]string{"__complete", "--", "repo", "sync", ""}, &stdout, &stderr); err != nil {
+		t.Fatalf("__complete failed: %v", err)
+	}
+	if got := stdout.String(); got != "sles\nslfo\n" {
+		t.Errorf("Expected the candidates, got %q", got)
+	}
+	if !IsCompletion(root.Lookup("__complete")) || IsCompletion(root.Lookup("cat")) {
+		t.Errorf("IsCompletion does not recognize the completion command")
+	}
+	for _, candidate := range Complete(root, []string{""}) {
+		if candidate == "__complete" {
+			t.Errorf("Expected the completion command to be hidden")
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// completeCommand is the hidden command called by the completion scripts.
+const completeCommand = "__complete"
+
+// CompletionCommands returns the 'completion' command, which prints the bash, zsh and fish
+// completion scripts, and the hidden command the scripts call to get candidates. Add them
+// to the subcommands of the root command named program. Scripts and candidates are
+// written to stdout.
+func CompletionCommands(program string, stdout io.Writer) []*Command {
+	completion := &Command{
+		Name:    "completion",
+		Summary: "Print a shell completion script (bash, zsh or fish)",
+		Help: fmt.Sprintf(`Print a shell completion script. To enable completion, add one of these lines
+to your shell's configuration:
+
+  bash: source <(%[1]s completion bash)
+  zsh:  source <(%[1]s completion zsh)
+  fish: %[1]s completion fish | source`, program),
+	}
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		shell := shell
+		completion.Commands = append(completion.Commands, &Command{
+			Name:    shell,
+			Summary: fmt.Sprintf("Print the %s completion script", shell),
+			Setup: func(fs *FlagSet) Action {
+				return func(args []string) error {
+					if len(args) > 0 {
+						return Usagef("unexpected arguments: %s", strings.Join(args, " "))
+					}
+					return writeScript(stdout, shell, program)
+				}
+			},
+		})
+	}
+	complete := &Command{
+		Name:   completeCommand,
+		Hidden: true,
+		Setup: func(fs *FlagSet) Action {
+			return func(words []string) error {
+				for _, candidate := range Complete(completion.root(), words) {
+					if _, err := fmt.Fprintln(stdout, candidate); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		},
+	}
+	return []*Command{completion, complete}
+}
+
+// IsCompletion reports whether cmd is the hidden command called by completion scripts.
+// Such commands must not fail or print anything but candidates, e.g. when the
+// configuration is broken.
+func IsCompletion(cmd *Command) bool {
+	return cmd.Name == completeCommand && cmd.parent != nil && cmd.parent.parent == nil
+}
+
+// Complete returns the candidates for the last of words, which are the command-line
+// words after the program name. The last word is the one being completed and may be empty.
+func Complete(root *Command, words []string) []string {
+	root.link()
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	cmd := root
+	var valueOf *Flag // The flag whose value is being completed
+	for _, word := range words[:len(words)-1] {
+		switch {
+		case valueOf != nil:
+			valueOf = nil
+		case word == "--":
+		case strings.HasPrefix(word, "-"):
+			name := strings.TrimLeft(word, "-")
+			if strings.Contains(name, "=") {
+				continue
+			}
+			if fl := cmd.flagSet().lookup(name); fl != nil && fl.Value != "" {
+				valueOf = fl
+			}
+		default:
+			if sub := cmd.Lookup(word); sub != nil && !sub.Hidden {
+				cmd = sub
+			}
+		}
+	}
+
+	var candidates []string
+	switch {
+	case valueOf != nil:
+		if valueOf.Complete != nil {
+			candidates = valueOf.Complete()
+		}
+	case strings.HasPrefix(current, "-"):
+		for _, fl := range cmd.flagSet().all() {
+			if fl.Long != "" {
+				candidates = append(candidates, "--"+fl.Long)
+			} else {
+				candidates = append(candidates, "-"+fl.Short)
+			}
+		}
+	case len(cmd.Commands) > 0:
+		for _, sub := range cmd.Commands {
+			if !sub.Hidden {
+				candidates = append(candidates, sub.Name)
+			}
+		}
+		candidates = append(candidates, "help")
+	case cmd.Complete != nil:
+		candidates = cmd.Complete()
+	}
+
+	var matches []string
+	seen := make(map[string]struct{})
+	for _, candidate := range candidates {
+		if _, dup := seen[candidate]; dup || !strings.HasPrefix(candidate, current) {
+			continue
+		}
+		seen[candidate] = struct{}{}
+		matches = append(matches, candidate)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// writeScript writes the completion script for shell.
+func writeScript(w io.Writer, shell, program string) error {
+	data := struct {
+		Program  string
+		Function string
+		Complete string
+	}{program, "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program), completeCommand}
+	if err := scripts.ExecuteTemplate(w, shell, data); err != nil {
+		return fmt.Errorf("cli: failed to write %s completion script: %w", shell, err)
+	}
+	return nil
+}
+
+// scripts are the completion scripts. They pass the words of the command line up to the
+// cursor to the hidden completion command and offer the candidates it prints. The words
+// follow "--", so that they are not parsed as flags of the hidden command.
+var scripts = template.Must(template.New("bash").Parse(`# bash completion for {{.Program}}
+{{.Function}}() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null; then
+        # Don't split words at ':', which is part of OBS project names.
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}" words=("${COMP_WORDS[@]}") cword=$COMP_CWORD
+    fi
+    local IFS=$'\n'
+    COMPREPLY=($({{.Program}} {{.Complete}} -- "${words[@]:1:cword}" 2>/dev/null))
+    if declare -F __ltrim_colon_completions >/dev/null; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+complete -o default -F {{.Function}} {{.Program}}
+`))
+
+func init() {
+	template.Must(scripts.New("zsh").Parse(`#compdef {{.Program}}
+# zsh completion for {{.Program}}
+{{.Function}}() {
+    local -a candidates
+    candidates=(${(f)"$({{.Program}} {{.Complete}} -- "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    if (( ${#candidates} )); then
+        compadd -- "${candidates[@]}"
+    else
+        _files
+    fi
+}
+compdef {{.Function}} {{.Program}}
+`))
+	template.Must(scripts.New("fish").Parse(`# fish completion for {{.Program}}
+function {{.Function}}_complete
+    set -l words (commandline -opc) (commandline -ct)
+    {{.Program}} {{.Complete}} -- $words[2..-1] 2>/dev/null
+end
+complete -c {{.Program}} -f -a '({{.Function}}_complete)'
+`))
+}
@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Flag describes a flag of a command. A flag has a short name, a long name or both,
+// and can be given as -b, --b, -branch or --branch on the command line.
+type Flag struct {
+	Short string // e.g. "b"
+	Long  string // e.g. "branch"
+	// Value is the placeholder of the flag's value in help texts, e.g. "<branch>".
+	// It is empty for boolean flags.
+	Value string
+	Usage string
+	// Complete returns candidates for the value of the flag in shell completion.
+	Complete Completer
+}
+
+// names returns the names the flag is registered under.
+func (f *Flag) names() []string {
+	var names []string
+	for _, name := range []string{f.Short, f.Long} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// synopsis returns the flag as shown in help texts, e.g. "-b, --branch <branch>".
+func (f *Flag) synopsis() string {
+	var parts []string
+	if f.Short != "" {
+		parts = append(parts, "-"+f.Short)
+	}
+	if f.Long != "" {
+		parts = append(parts, "--"+f.Long)
+	}
+	synopsis := strings.Join(parts, ", ")
+	if f.Value != "" {
+		synopsis += " " + f.Value
+	}
+	return synopsis
+}
+
+// FlagSet holds the flags of a command. It wraps a flag.FlagSet, registering each flag
+// under its short and long name.
+type FlagSet struct {
+	set    *flag.FlagSet
+	flags  []*Flag
+	global []*Flag // The global flags accepted by the command, see inherit
+}
+
+func newFlagSet(name string) *FlagSet {
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	set.SetOutput(io.Discard) // Errors and usage are printed by Execute
+	set.Usage = func() {}
+	return &FlagSet{set: set}
+}
+
+// String defines a string flag with the given names, value placeholder, default and usage.
+func (f *FlagSet) String(short, long, value, def, usage string) *string {
+	p := new(string)
+	*p = def
+	f.Var((*stringValue)(p), short, long, value, usage)
+	return p
+}
+
+// Int defines an int flag.
+func (f *FlagSet) Int(short, long, value string, def int, usage string) *int {
+	p := new(int)
+	f.add(&Flag{Short: short, Long: long, Value: value, Usage: usage}, func(name string) {
+		f.set.IntVar(p, name, def, usage)
+	})
+	return p
+}
+
+// Bool defines a boolean flag, which takes no value.
+func (f *FlagSet) Bool(short, long, usage string) *bool {
+	p := new(bool)
+	f.add(&Flag{Short: short, Long: long, Usage: usage}, func(name string) {
+		f.set.BoolVar(p, name, false, usage)
+	})
+	return p
+}
+
+// Duration defines a time.Duration flag, e.g. "10m".
+func (f *FlagSet) Duration(short, long, value string, def time.Duration, usage string) *time.Duration {
+	p := new(time.Duration)
+	f.add(&Flag{Short: short, Long: long, Value: value, Usage: usage}, func(name string) {
+		f.set.DurationVar(p, name, def, usage)
+	})
+	return p
+}
+
+// Strings defines a flag that can be repeated and collects its values.
+func (f *FlagSet) Strings(short, long, value, usage string) *[]string {
+	p := new([]string)
+	f.Var((*stringsValue)(p), short, long, value, usage)
+	return p
+}
+
+// Var defines a flag with a custom flag.Value.
+func (f *FlagSet) Var(v flag.Value, short, long, value, usage string) {
+	f.add(&Flag{Short: short, Long: long, Value: value, Usage: usage}, func(name string) {
+		f.set.Var(v, name, usage)
+	})
+}
+
+// SetCompleter sets the completer of the flag named name (short or long).
+func (f *FlagSet) SetCompleter(name string, complete Completer) {
+	if fl := f.lookup(name); fl != nil {
+		fl.Complete = complete
+	}
+}
+
+// Flags returns the flags in the order they were defined.
+func (f *FlagSet) Flags() []*Flag {
+	return f.flags
+}
+
+func (f *FlagSet) add(fl *Flag, define func(name string)) {
+	for _, name := range fl.names() {
+		define(name)
+	}
+	f.flags = append(f.flags, fl)
+}
+
+// inherit registers the global flags of the root command in f, so that they can also be
+// given after the name of the command. They share their values with the root command.
+// Names that f already defines are left out.
+func (f *FlagSet) inherit(root *FlagSet) {
+	for _, fl := range root.flags {
+		inherited := *fl
+		for _, name := range fl.names() {
+			if f.set.Lookup(name) != nil {
+				if name == fl.Short {
+					inherited.Short = ""
+				} else {
+					inherited.Long = ""
+				}
+				continue
+			}
+			value := root.set.Lookup(name)
+			f.set.Var(value.Value, name, value.Usage)
+		}
+		if len(inherited.names()) > 0 {
+			f.global = append(f.global, &inherited)
+		}
+	}
+}
+
+// all returns the flags of the command followed by the global flags it accepts.
+func (f *FlagSet) all() []*Flag {
+	return append(append([]*Flag(nil), f.flags...), f.global...)
+}
+
+// lookup returns the flag named name, or nil. Global flags are found, too.
+func (f *FlagSet) lookup(name string) *Flag {
+	for _, fl := range f.all() {
+		if name != "" && (fl.Short == name || fl.Long == name) {
+			return fl
+		}
+	}
+	return nil
+}
+
+// stringValue is a flag.Value for string flags.
+type stringValue string
+
+func (s *stringValue) String() string { return string(*s) }
+
+func (s *stringValue) Set(value string) error {
+	*s = stringValue(value)
+	return nil
+}
+
+// stringsValue is a flag.Value collecting the values of a repeatable flag.
+type stringsValue []string
+
+func (s *stringsValue) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsValue) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// printFlags writes a table of flags.
+func printFlags(w io.Writer, title string, flags []*Flag) {
+	if len(flags) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	tw := newTable(w)
+	for _, fl := range flags {
+		fmt.Fprintf(tw, "  %s\t%s\n", fl.synopsis(), fl.Usage)
+	}
+	tw.Flush()
+}
@@ -3,9 +3,17 @@
 package command
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"syscall"
+	"time"
 )
 
 // Runner defines a standard interface for executing external commands.
@@ -16,10 +24,138 @@ type Runner interface {
 	// It uses a context for timeout and cancellation control.
 	// It returns the combined stdout and stderr output, or an error if the command fails.
 	Run(ctx context.Context, workDir, name string, args ...string) ([]byte, error)
+	// Exec executes the specified command like Run, but keeps its standard output and
+	// error apart, so that warnings on stderr are not mistaken for results. It always
+	// returns a Result; if the command fails, the error is an *ExitError.
+	Exec(ctx context.Context, workDir, name string, args ...string) (*Result, error)
+	// Stream executes the specified command like Exec, but passes each line of its
+	// standard output to onLine while the command runs instead of buffering it, so that
+	// long listings can be processed progressively. The Result has no Stdout. If onLine
+	// returns an error, the command is stopped and that error is returned.
+	Stream(ctx context.Context, workDir string, onLine func(line string) error, name string, args ...string) (*Result, error)
 	// RunInteractive executes the specified command in interactive mode.
 	RunInteractive(ctx context.Context, workDir, name string, args ...string) error
-	// RunPipeline executes a pipeline of two commands.
-	RunPipeline(ctx context.Context, workDir string, cmd1, cmd2 []string) error
+	// RunPipeline executes the stages of a pipeline like a shell pipeline with pipefail:
+	// it fails if any stage fails, and the error is then a *PipelineError.
+	RunPipeline(ctx context.Context, p Pipeline) error
+}
+
+// Result is the outcome of a command run with Exec.
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int // -1 if the command did not start or was killed by a signal
+	Duration time.Duration
+}
+
+// ExitError reports a command run with Exec that failed, either with a non-zero exit
+// status or because it could not be started. It unwraps to the error of os/exec, e.g.
+// *exec.ExitError or exec.ErrNotFound, and to the error of the context if the context
+// ended before the command, so that a timeout can be told from a failure.
+type ExitError struct {
+	Args       []string
+	ExitCode   int
+	Stderr     []byte
+	Err        error
+	ContextErr error // e.g. context.DeadlineExceeded; nil if the context was still live
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("'%s' failed: %v", strings.Join(e.Args, " "), e.Err)
+	if e.ContextErr != nil {
+		msg += fmt.Sprintf(" (%v)", e.ContextErr)
+	}
+	if stderr := strings.TrimSpace(string(e.Stderr)); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// Unwrap returns the error of os/exec and the error of the context, if any.
+func (e *ExitError) Unwrap() []error {
+	if e.ContextErr != nil {
+		return []error{e.Err, e.ContextErr}
+	}
+	return []error{e.Err}
+}
+
+// Pipeline describes commands whose output is the input of the next one, like
+// `git archive ... | tar -xO` in a shell, but without a shell.
+type Pipeline struct {
+	WorkDir string
+	Stages  [][]string // The commands, each a name followed by its arguments
+	Stdin   io.Reader  // Input of the first stage; none if nil
+	Stdout  io.Writer  // Output of the last stage; discarded if nil
+	// Stderr also receives the error output of all stages, which is always captured,
+	// e.g. os.Stderr to show it while the pipeline runs. Optional.
+	Stderr io.Writer
+}
+
+// StageResult is the outcome of one stage of a pipeline.
+type StageResult struct {
+	Args     []string
+	ExitCode int    // -1 if the stage did not start or was killed by a signal
+	Err      error  // nil if the stage succeeded
+	Stderr   []byte // The captured error output
+}
+
+// PipelineError reports a failed pipeline with the results of all its stages.
+// It unwraps to the errors of the failed stages, e.g. *exec.ExitError.
+type PipelineError struct {
+	Stages []StageResult
+}
+
+func (e *PipelineError) Error() string {
+	var failures []string
+	for _, stage := range e.Stages {
+		if stage.Err == nil {
+			continue
+		}
+		failure := fmt.Sprintf("'%s' failed: %v", strings.Join(stage.Args, " "), stage.Err)
+		if stderr := strings.TrimSpace(string(stage.Stderr)); stderr != "" {
+			failure += ": " + stderr
+		}
+		failures = append(failures, failure)
+	}
+	return "command: pipeline failed: " + strings.Join(failures, "; ")
+}
+
+// Unwrap returns the errors of the failed stages.
+func (e *PipelineError) Unwrap() []error {
+	var errs []error
+	for _, stage := range e.Stages {
+		if stage.Err != nil {
+			errs = append(errs, stage.Err)
+		}
+	}
+	return errs
+}
+
+// OnlyBrokenPipes reports whether the last stage succeeded and the other stages only failed
+// because the stage after them stopped reading, e.g. a pager that was quit early. Such a
+// stage was killed by SIGPIPE, or got EPIPE and reported a broken pipe, like Python
+// programs do.
+func (e *PipelineError) OnlyBrokenPipes() bool {
+	if len(e.Stages) == 0 || e.Stages[len(e.Stages)-1].Err != nil {
+		return false
+	}
+	for _, stage := range e.Stages[:len(e.Stages)-1] {
+		if stage.Err != nil && !brokenPipe(stage) {
+			return false
+		}
+	}
+	return true
+}
+
+// brokenPipe reports whether a stage failed because its output was no longer read.
+func brokenPipe(stage StageResult) bool {
+	var exitErr *exec.ExitError
+	if errors.As(stage.Err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGPIPE {
+			return true
+		}
+	}
+	return errors.Is(stage.Err, syscall.EPIPE) || bytes.Contains(stage.Stderr, []byte("Broken pipe"))
 }
 
 // DefaultRunner is the default implementation of the Runner interface.
@@ -36,6 +172,103 @@ func (r *DefaultRunner) Run(ctx context.Context, workDir, name string, args ...s
 	return cmd.CombinedOutput()
 }
 
+// Exec executes a command using exec.CommandContext and captures its standard output
+// and error separately.
+func (r *DefaultRunner) Exec(ctx context.Context, workDir, name string, args ...string) (*Result, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if workDir != "" {
+		cmd.Dir = workDir
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	result := &Result{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		ExitCode: -1,
+		Duration: time.Since(start),
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if err != nil {
+		return result, &ExitError{
+			Args:       append([]string{name}, args...),
+			ExitCode:   result.ExitCode,
+			Stderr:     result.Stderr,
+			Err:        err,
+			ContextErr: ctx.Err(),
+		}
+	}
+	return result, nil
+}
+
+// maxLineSize is the longest line of output that Stream accepts.
+const maxLineSize = 1024 * 1024
+
+// Stream executes a command using exec.CommandContext and reads its standard output
+// line by line.
+func (r *DefaultRunner) Stream(ctx context.Context, workDir string, onLine func(line string) error, name string, args ...string) (*Result, error) {
+	// The command is stopped if onLine fails.
+	cmdCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(cmdCtx, name, args...)
+	if workDir != "" {
+		cmd.Dir = workDir
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return &Result{ExitCode: -1}, fmt.Errorf("command: failed to create pipe: %w", err)
+	}
+
+	start := time.Now()
+	result := &Result{ExitCode: -1}
+	if err := cmd.Start(); err != nil {
+		result.Duration = time.Since(start)
+		return result, &ExitError{Args: append([]string{name}, args...), ExitCode: -1, Err: err, ContextErr: ctx.Err()}
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	var lineErr error
+	for scanner.Scan() {
+		if lineErr = onLine(scanner.Text()); lineErr != nil {
+			cancel()
+			break
+		}
+	}
+	if lineErr == nil {
+		if lineErr = scanner.Err(); lineErr != nil {
+			lineErr = fmt.Errorf("command: failed to read the output of %s: %w", name, lineErr)
+			cancel()
+		}
+	}
+
+	err = cmd.Wait()
+	result.Stderr = stderr.Bytes()
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	result.Duration = time.Since(start)
+	if lineErr != nil {
+		return result, lineErr
+	}
+	if err != nil {
+		return result, &ExitError{
+			Args:       append([]string{name}, args...),
+			ExitCode:   result.ExitCode,
+			Stderr:     result.Stderr,
+			Err:        err,
+			ContextErr: ctx.Err(),
+		}
+	}
+	return result, nil
+}
+
 // RunInteractive executes a command in interactive mode.
 func (r *DefaultRunner) RunInteractive(ctx context.Context, workDir, name string, args ...string) error {
 	cmd := exec.CommandContext(ctx, name, args...)
@@ -48,37 +281,86 @@ func (r *DefaultRunner) RunInteractive(ctx context.Context, workDir, name string
 	return cmd.Run()
 }
 
-// RunPipeline executes a pipeline of two commands.
-func (r *DefaultRunner) RunPipeline(ctx context.Context, workDir string, cmd1Args, cmd2Args []string) error {
-	cmd1 := exec.CommandContext(ctx, cmd1Args[0], cmd1Args[1:]...)
-	cmd2 := exec.CommandContext(ctx, cmd2Args[0], cmd2Args[1:]...)
-
-	if workDir != "" {
-		cmd1.Dir = workDir
-		cmd2.Dir = workDir
+// RunPipeline runs the stages of the pipeline concurrently, connecting the standard output
+// of each stage to the standard input of the next, and waits for all of them. The standard
+// error of every stage is captured. If any stage fails, the error is a *PipelineError with
+// the status of each stage.
+func (r *DefaultRunner) RunPipeline(ctx context.Context, p Pipeline) error {
+	if len(p.Stages) == 0 {
+		return fmt.Errorf("command: pipeline has no stages")
+	}
+	for i, stage := range p.Stages {
+		if len(stage) == 0 {
+			return fmt.Errorf("command: stage %d of the pipeline has no command", i+1)
+		}
 	}
+	// Stages that did start are stopped if a later one cannot be started.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	pipe, err := cmd1.StdoutPipe()
-	if err != nil {
-		return err
+	results := make([]StageResult, len(p.Stages))
+	stderrs := make([]bytes.Buffer, len(p.Stages))
+	cmds := make([]*exec.Cmd, len(p.Stages))
+	for i, stage := range p.Stages {
+		results[i] = StageResult{Args: stage, ExitCode: -1}
+		cmd := exec.CommandContext(ctx, stage[0], stage[1:]...)
+		cmd.Dir = p.WorkDir
+		cmd.Stderr = &stderrs[i]
+		if p.Stderr != nil {
+			cmd.Stderr = io.MultiWriter(&stderrs[i], p.Stderr)
+		}
+		cmds[i] = cmd
 	}
-	cmd2.Stdin = pipe
-	cmd2.Stdout = os.Stdout
-	cmd2.Stderr = os.Stderr
+	cmds[0].Stdin = p.Stdin
+	cmds[len(cmds)-1].Stdout = p.Stdout
 
-	if err := cmd1.Start(); err != nil {
-		return err
+	// Connect the stages with OS pipes, so that the data flows between the processes
+	// directly. The ends are closed in this process once the stages have been started.
+	var pipeEnds []io.Closer
+	for i := 0; i+1 < len(cmds); i++ {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			closeAll(pipeEnds)
+			return fmt.Errorf("command: failed to create pipe: %w", err)
+		}
+		cmds[i].Stdout = writer
+		cmds[i+1].Stdin = reader
+		pipeEnds = append(pipeEnds, reader, writer)
 	}
-	if err := cmd2.Start(); err != nil {
-		return err
+
+	started := 0
+	for i, cmd := range cmds {
+		if err := cmd.Start(); err != nil {
+			results[i].Err = err
+			cancel()
+			break
+		}
+		started++
 	}
+	closeAll(pipeEnds)
 
-	if err := cmd1.Wait(); err != nil {
-		return err
+	failed := started < len(cmds)
+	for i := 0; i < started; i++ {
+		err := cmds[i].Wait()
+		results[i].Err = err
+		results[i].ExitCode = cmds[i].ProcessState.ExitCode()
+		if err != nil {
+			failed = true
+		}
 	}
-	if err := cmd2.Wait(); err != nil {
-		return err
+	for i := range results {
+		results[i].Stderr = stderrs[i].Bytes()
 	}
 
+	if failed {
+		return &PipelineError{Stages: results}
+	}
 	return nil
 }
+
+// closeAll closes all closers, ignoring errors.
+func closeAll(closers []io.Closer) {
+	for _, c := range closers {
+		_ = c.Close()
+	}
+}
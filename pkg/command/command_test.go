@@ -0,0 +1,212 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPipeline(t *testing.T) {
+	runner := &DefaultRunner{}
+
+	t.Run("ThreeStages", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := runner.RunPipeline(context.Background(), Pipeline{
+			Stages: [][]string{
+				{"cat"},
+				{"tr", "a-z", "A-Z"},
+				{"sort"},
+			},
+			Stdin:  strings.NewReader("pear\napple\n"),
+			Stdout: &stdout,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got, want := stdout.String(), "APPLE\nPEAR\n"; got != want {
+			t.Errorf("Expected output %q, got %q", want, got)
+		}
+	})
+
+	t.Run("FailingStage", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		err := runner.RunPipeline(context.Background(), Pipeline{
+			Stages: [][]string{
+				{"sh", "-c", "echo 'fatal: no such ref' >&2; exit 3"},
+				{"cat"},
+			},
+			Stdout: &stdout,
+			Stderr: &stderr,
+		})
+
+		var pipelineErr *PipelineError
+		if !errors.As(err, &pipelineErr) {
+			t.Fatalf("Expected a *PipelineError, got %v", err)
+		}
+		first, second := pipelineErr.Stages[0], pipelineErr.Stages[1]
+		if first.ExitCode != 3 || first.Err == nil || string(first.Stderr) != "fatal: no such ref\n" {
+			t.Errorf("Unexpected result of the failing stage: %+v", first)
+		}
+		if second.ExitCode != 0 || second.Err != nil {
+			t.Errorf("Expected the second stage to succeed, got %+v", second)
+		}
+		if !strings.Contains(err.Error(), "'sh -c echo 'fatal: no such ref' >&2; exit 3' failed: exit status 3: fatal: no such ref") {
+			t.Errorf("Unexpected error message: %v", err)
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+			t.Errorf("Expected the error to unwrap to the exit status, got %v", err)
+		}
+		if stderr.String() != "fatal: no such ref\n" {
+			t.Errorf("Expected the error output to be passed on, got %q", stderr.String())
+		}
+	})
+
+	t.Run("ReaderQuitsEarly", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			stages [][]string
+			want   bool
+		}{
+			{"SIGPIPE", [][]string{{"yes"}, {"head", "-n", "1"}}, true},
+			{"EPIPE", [][]string{{"sh", "-c", "echo 'BrokenPipeError: [Errno 32] Broken pipe' >&2; exit 120"}, {"true"}}, true},
+			{"OtherFailure", [][]string{{"sh", "-c", "exit 3"}, {"true"}}, false},
+			{"LastStageFails", [][]string{{"yes"}, {"sh", "-c", "head -n 1; exit 2"}}, false},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				err := runner.RunPipeline(context.Background(), Pipeline{Stages: tc.stages})
+				var pipelineErr *PipelineError
+				if !errors.As(err, &pipelineErr) {
+					t.Fatalf("Expected a *PipelineError, got %v", err)
+				}
+				if got := pipelineErr.OnlyBrokenPipes(); got != tc.want {
+					t.Errorf("OnlyBrokenPipes() = %v, want %v for %v", got, tc.want, err)
+				}
+			})
+		}
+	})
+
+	t.Run("MissingCommand", func(t *testing.T) {
+		err := runner.RunPipeline(context.Background(), Pipeline{
+			Stages: [][]string{
+				{"cat"},
+				{"relx-go-no-such-command"},
+			},
+			Stdin: strings.NewReader("input"),
+		})
+
+		var pipelineErr *PipelineError
+		if !errors.As(err, &pipelineErr) {
+			t.Fatalf("Expected a *PipelineError, got %v", err)
+		}
+		if got := pipelineErr.Stages[1].ExitCode; got != -1 {
+			t.Errorf("Expected exit code -1 for a stage that did not start, got %d", got)
+		}
+		if !errors.Is(err, exec.ErrNotFound) {
+			t.Errorf("Expected the error to unwrap to exec.ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("NoStages", func(t *testing.T) {
+		if err := runner.RunPipeline(context.Background(), Pipeline{}); err == nil {
+			t.Error("Expected an error for a pipeline without stages")
+		}
+	})
+}
+
+func TestExec(t *testing.T) {
+	runner := &DefaultRunner{}
+
+	t.Run("SeparateOutput", func(t *testing.T) {
+		result, err := runner.Exec(context.Background(), "", "sh", "-c", "echo out; echo warning >&2")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if string(result.Stdout) != "out\n" || string(result.Stderr) != "warning\n" || result.ExitCode != 0 {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("ExitStatus", func(t *testing.T) {
+		result, err := runner.Exec(context.Background(), "", "sh", "-c", "echo partial; echo 'fatal: bad' >&2; exit 2")
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("Expected an *ExitError, got %v", err)
+		}
+		if exitErr.ExitCode != 2 || result.ExitCode != 2 || string(result.Stdout) != "partial\n" {
+			t.Errorf("Unexpected result %+v for error %v", result, exitErr)
+		}
+		if want := "'sh -c echo partial; echo 'fatal: bad' >&2; exit 2' failed: exit status 2: fatal: bad"; err.Error() != want {
+			t.Errorf("Expected error %q, got %q", want, err.Error())
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			t.Error("Expected a failure, not a timeout")
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		result, err := runner.Exec(ctx, "", "sleep", "5")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the error to wrap context.DeadlineExceeded, got %v", err)
+		}
+		if result.ExitCode != -1 {
+			t.Errorf("Expected exit code -1 for a killed command, got %d", result.ExitCode)
+		}
+	})
+
+	t.Run("MissingCommand", func(t *testing.T) {
+		_, err := runner.Exec(context.Background(), "", "relx-go-no-such-command")
+		if !errors.Is(err, exec.ErrNotFound) {
+			t.Errorf("Expected the error to wrap exec.ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	runner := &DefaultRunner{}
+
+	t.Run("Lines", func(t *testing.T) {
+		var lines []string
+		result, err := runner.Stream(context.Background(), "", func(line string) error {
+			lines = append(lines, line)
+			return nil
+		}, "sh", "-c", "printf 'a\\nb\\nc'; echo warning >&2")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if strings.Join(lines, ",") != "a,b,c" {
+			t.Errorf("Expected lines a, b and c, got %q", lines)
+		}
+		if string(result.Stderr) != "warning\n" || result.Stdout != nil {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("StopEarly", func(t *testing.T) {
+		stop := errors.New("found it")
+		start := time.Now()
+		_, err := runner.Stream(context.Background(), "", func(line string) error {
+			return stop
+		}, "sh", "-c", "echo first; exec sleep 5")
+		if !errors.Is(err, stop) {
+			t.Errorf("Expected the error of the callback, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 4*time.Second {
+			t.Errorf("Expected the command to be stopped, but it ran for %s", elapsed)
+		}
+	})
+
+	t.Run("ExitStatus", func(t *testing.T) {
+		_, err := runner.Stream(context.Background(), "", func(string) error { return nil }, "sh", "-c", "echo 'fatal: bad' >&2; exit 4")
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode != 4 || string(exitErr.Stderr) != "fatal: bad\n" {
+			t.Errorf("Expected an *ExitError with status 4, got %v", err)
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package commandtest
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 
@@ -11,8 +13,10 @@ import (
 // It is shared across different packages to test components that depend on command.Runner.
 type MockRunner struct {
 	RunFunc            func(ctx context.Context, workDir, name string, args ...string) ([]byte, error)
+	ExecFunc           func(ctx context.Context, workDir, name string, args ...string) (*command.Result, error)
+	StreamFunc         func(ctx context.Context, workDir string, onLine func(line string) error, name string, args ...string) (*command.Result, error)
 	RunInteractiveFunc func(ctx context.Context, workDir, name string, args ...string) error
-	RunPipelineFunc    func(ctx context.Context, workDir string, cmd1, cmd2 []string) error
+	RunPipelineFunc    func(ctx context.Context, p command.Pipeline) error
 }
 
 // This line is a compile-time check to ensure MockRunner implements command.Runner.
@@ -26,6 +30,43 @@ func (m *MockRunner) Run(ctx context.Context, workDir, name string, args ...stri
 	return nil, fmt.Errorf("RunFunc not defined for mock runner")
 }
 
+// Exec executes the mock command. Without an ExecFunc, it falls back to RunFunc: the
+// output of RunFunc is the standard output of a successful command and the standard
+// error of a failed one.
+func (m *MockRunner) Exec(ctx context.Context, workDir, name string, args ...string) (*command.Result, error) {
+	if m.ExecFunc != nil {
+		return m.ExecFunc(ctx, workDir, name, args...)
+	}
+	if m.RunFunc != nil {
+		output, err := m.RunFunc(ctx, workDir, name, args...)
+		if err != nil {
+			return &command.Result{Stderr: output, ExitCode: 1}, err
+		}
+		return &command.Result{Stdout: output}, nil
+	}
+	return &command.Result{ExitCode: -1}, fmt.Errorf("ExecFunc not defined for mock runner")
+}
+
+// Stream executes the mock streaming command. Without a StreamFunc, it falls back to Exec
+// and passes the lines of its standard output to onLine.
+func (m *MockRunner) Stream(ctx context.Context, workDir string, onLine func(line string) error, name string, args ...string) (*command.Result, error) {
+	if m.StreamFunc != nil {
+		return m.StreamFunc(ctx, workDir, onLine, name, args...)
+	}
+	result, err := m.Exec(ctx, workDir, name, args...)
+	if err != nil {
+		return result, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(result.Stdout))
+	for scanner.Scan() {
+		if err := onLine(scanner.Text()); err != nil {
+			return result, err
+		}
+	}
+	result.Stdout = nil
+	return result, nil
+}
+
 // RunInteractive executes the mock interactive command.
 func (m *MockRunner) RunInteractive(ctx context.Context, workDir, name string, args ...string) error {
 	if m.RunInteractiveFunc != nil {
@@ -35,9 +76,9 @@ func (m *MockRunner) RunInteractive(ctx context.Context, workDir, name string, a
 }
 
 // RunPipeline executes the mock pipeline command.
-func (m *MockRunner) RunPipeline(ctx context.Context, workDir string, cmd1, cmd2 []string) error {
+func (m *MockRunner) RunPipeline(ctx context.Context, p command.Pipeline) error {
 	if m.RunPipelineFunc != nil {
-		return m.RunPipelineFunc(ctx, workDir, cmd1, cmd2)
+		return m.RunPipelineFunc(ctx, p)
 	}
 	return fmt.Errorf("RunPipelineFunc not defined for mock runner")
 }
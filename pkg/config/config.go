@@ -1,16 +1,20 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"os/user"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v3"
 
 	"github.com/gyr/relx-go/pkg/logging"
+	"github.com/gyr/relx-go/pkg/progress"
 )
 
 // PackageFilter defines the structure for a package filter, associating
@@ -20,57 +24,195 @@ type PackageFilter struct {
 	Repository string `yaml:"repository"`
 }
 
+// Repository describes a git repository that is kept up to date in the cache.
+// When Name is empty, it is derived from the last component of the URL.
+type Repository struct {
+	Name           string   `yaml:"name"`
+	URL            string   `yaml:"url"`
+	Branch         string   `yaml:"branch"`
+	UpdateStrategy string   `yaml:"update_strategy"` // Overrides repo_update_strategy for this repository
+	Depth          int      `yaml:"depth"`           // Overrides repo_clone_depth for this repository
+	Filter         string   `yaml:"filter"`          // Overrides repo_clone_filter for this repository
+	SparsePaths    []string `yaml:"sparse_paths"`    // Overrides repo_sparse_paths for this repository
+}
+
+// ReviewTarget is a branch of a Gitea repository whose pull requests are reviewed by
+// 'review' when no -b/-r flags are given.
+type ReviewTarget struct {
+	Repository string `yaml:"repository"` // e.g. "products/SLFO"
+	Branch     string `yaml:"branch"`
+}
+
+// Timeouts overrides operation_timeout_seconds for single kinds of operations, in seconds.
+// Zero means operation_timeout_seconds.
+type Timeouts struct {
+	OscList      int `yaml:"osc_list"`      // Listing the packages of an OBS project
+	OscBinaries  int `yaml:"osc_binaries"`  // Listing the binaries of an OBS package
+	GitClone     int `yaml:"git_clone"`     // Cloning a repository into the cache
+	GitFetch     int `yaml:"git_fetch"`     // Updating a cached clone
+	GiteaList    int `yaml:"gitea_list"`    // Listing pull requests
+	GiteaComment int `yaml:"gitea_comment"` // Commenting on a pull request
+}
+
+const (
+	defaultOperationTimeoutSeconds = 300 // 5 minutes
+	defaultMetadataCacheTTLSeconds = 300 // 5 minutes
+)
+
+// Profile is a named set of settings that overrides the configuration when selected.
+// Its keys are the same as the top-level configuration keys.
+type Profile map[string]interface{}
+
 // Config holds the application's configuration.
 type Config struct {
-	CacheDir                string          `yaml:"cache_dir"`
-	RepoURL                 string          `yaml:"repo_url"`
-	RepoBranch              string          `yaml:"repo_branch"`
-	OBSAPIURL               string          `yaml:"obs_api_url"`
-	PRReviewer              string          `yaml:"pr_reviewer"`
-	Debug                   bool            `yaml:"debug"`
-	PackageFilterPatterns   []PackageFilter `yaml:"package_filter_patterns"`
-	BinaryFilterPatterns    []string        `yaml:"binary_filter_patterns"`
-	OperationTimeoutSeconds int             `yaml:"operation_timeout_seconds"` // Timeout for various operations in seconds
-	Logger                  *logging.Logger `yaml:"-"`                         // Ignore logger for YAML (it's not a config value)
-	OutputWriter            io.Writer       `yaml:"-"`                         // Ignore output writer for YAML (it's not a config value)
+	CacheDir                string             `yaml:"cache_dir"`
+	CacheMaxSizeMB          int                `yaml:"cache_max_size_mb"`          // Evict least recently used cache entries above this size (0 means unlimited)
+	CacheMaxAgeDays         int                `yaml:"cache_max_age_days"`         // Evict cache entries not used for this many days (0 means unlimited)
+	CacheLockTimeoutSeconds int                `yaml:"cache_lock_timeout_seconds"` // How long to wait for a cache entry locked by another process (0 means operation_timeout_seconds)
+	MetadataCacheTTLSeconds int                `yaml:"metadata_cache_ttl_seconds"` // How long to reuse fetched files and OBS listings (negative disables)
+	PRListCacheTTLSeconds   int                `yaml:"pr_list_cache_ttl_seconds"`  // How long to reuse Gitea PR lists (0 disables)
+	RepoURL                 string             `yaml:"repo_url"`
+	RepoBranch              string             `yaml:"repo_branch"`
+	RepoUpdateStrategy      string             `yaml:"repo_update_strategy"` // How to update cached clones: fail, stash, reset or reclone
+	RepoCloneDepth          int                `yaml:"repo_clone_depth"`     // Create shallow clones with this many commits (0 means full history)
+	RepoCloneFilter         string             `yaml:"repo_clone_filter"`    // Partial clone filter, e.g. "blob:none"
+	RepoSparsePaths         []string           `yaml:"repo_sparse_paths"`    // Only check out these paths (sparse-checkout patterns)
+	Repositories            []Repository       `yaml:"repositories"`
+	OBSAPIURL               string             `yaml:"obs_api_url"`
+	PRReviewer              string             `yaml:"pr_reviewer"`
+	PRViewer                string             `yaml:"pr_viewer"` // How 'review' shows pull requests: auto, delta, bat, less, pager ($PAGER) or none
+	ReviewTargets           []ReviewTarget     `yaml:"review_targets"`
+	Debug                   bool               `yaml:"debug"`
+	PackageFilterPatterns   []PackageFilter    `yaml:"package_filter_patterns"`
+	BinaryFilterPatterns    []string           `yaml:"binary_filter_patterns"`
+	OperationTimeoutSeconds int                `yaml:"operation_timeout_seconds"` // Timeout for various operations in seconds
+	Timeouts                Timeouts           `yaml:"timeouts"`                  // Per-operation overrides of operation_timeout_seconds
+	Profiles                map[string]Profile `yaml:"profiles"`                  // Named sets of settings, selected with --profile or RELX_GO_PROFILE
+	Profile                 string             `yaml:"-"`                         // The selected profile, set by Load
+	Logger                  *logging.Logger    `yaml:"-"`                         // Ignore logger for YAML (it's not a config value)
+	OutputWriter            io.Writer          `yaml:"-"`                         // Ignore output writer for YAML (it's not a config value)
+	InputReader             io.Reader          `yaml:"-"`                         // Answers to interactive prompts (not a config value)
+	Progress                *progress.Reporter `yaml:"-"`                         // Progress of long operations; nil reports nothing
+	Origins                 map[string]string  `yaml:"-"`                         // Where each setting came from, filled by Load
+	Files                   []string           `yaml:"-"`                         // Configuration files read by Load, in order
+
+	loadProblems []ValidationError // Unknown settings found by Load, reported by Validate
 }
 
-// LoadConfig loads the configuration from a YAML file.
+// MetadataCacheTTL returns how long memoized remote lookups stay valid.
+// A zero duration means memoization is disabled.
+func (c *Config) MetadataCacheTTL() time.Duration {
+	if c.MetadataCacheTTLSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(c.MetadataCacheTTLSeconds) * time.Second
+}
+
+// PRListCacheTTL returns how long memoized Gitea PR lists stay valid. Review queues
+// change often, so they are not memoized unless pr_list_cache_ttl_seconds is set.
+func (c *Config) PRListCacheTTL() time.Duration {
+	return time.Duration(c.PRListCacheTTLSeconds) * time.Second
+}
+
+// OperationTimeout returns the timeout of an operation, named like the keys of the
+// timeouts setting, e.g. "git_clone". Operations without a timeout of their own use
+// operation_timeout_seconds.
+func (c *Config) OperationTimeout(operation string) time.Duration {
+	values := reflect.ValueOf(c.Timeouts)
+	for i := 0; i < values.NumField(); i++ {
+		if yamlKey(values.Type().Field(i)) == operation {
+			if seconds := values.Field(i).Int(); seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+			break
+		}
+	}
+	return time.Duration(c.OperationTimeoutSeconds) * time.Second
+}
+
+// LoadConfig loads the configuration from a single YAML file and validates it.
+// Unknown settings are rejected. Use Load to read all configuration layers.
 func LoadConfig(configPath string) (*Config, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	cfg := &Config{}
-	if err := yaml.Unmarshal(data, cfg); err != nil {
+	cfg, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true) // Reject typos instead of silently ignoring them
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
 		return nil, fmt.Errorf("failed to unmarshal YAML config: %w", err)
 	}
 
-	// Set default OperationTimeoutSeconds if not provided
-	if cfg.OperationTimeoutSeconds == 0 {
-		cfg.OperationTimeoutSeconds = 300 // Default to 5 minutes
+	if err := applyDefaults(cfg, nil); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Set default CacheDir if not provided in config file
+	return cfg, nil
+}
+
+// Default returns the configuration used when nothing is configured: the default cache
+// directory and timeouts, a logger that reports warnings and errors, output to stdout
+// and input from stdin. LoadConfig and Load overlay the configured settings on top of it.
+func Default() (*Config, error) {
+	currentUser, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("config: could not get current user: %w", err)
+	}
+	return &Config{
+		CacheDir:                filepath.Join(currentUser.HomeDir, ".cache", "relx-go"),
+		MetadataCacheTTLSeconds: defaultMetadataCacheTTLSeconds,
+		OperationTimeoutSeconds: defaultOperationTimeoutSeconds,
+		Logger:                  logging.NewLogger(logging.LevelWarn),
+		OutputWriter:            os.Stdout,
+		InputReader:             os.Stdin,
+	}, nil
+}
+
+// applyDefaults restores the defaults of settings that were explicitly set to their zero
+// value, which means "use the default" for them, and expands the tilde in CacheDir.
+// If origins is not nil, restored settings are recorded in it.
+func applyDefaults(cfg *Config, origins map[string]string) error {
+	setDefault := func(key string) {
+		if origins != nil {
+			origins[key] = OriginDefault
+		}
+	}
+
+	if cfg.OperationTimeoutSeconds == 0 {
+		cfg.OperationTimeoutSeconds = defaultOperationTimeoutSeconds
+		setDefault("operation_timeout_seconds")
+	}
+	if cfg.MetadataCacheTTLSeconds == 0 {
+		cfg.MetadataCacheTTLSeconds = defaultMetadataCacheTTLSeconds
+		setDefault("metadata_cache_ttl_seconds")
+	}
 	if cfg.CacheDir == "" {
-		currentUser, err := user.Current()
+		defaults, err := Default()
 		if err != nil {
-			return nil, fmt.Errorf("config: could not get current user: %w", err)
+			return err
 		}
-		cfg.CacheDir = filepath.Join(currentUser.HomeDir, ".cache", "relx-go")
+		cfg.CacheDir = defaults.CacheDir
+		setDefault("cache_dir")
 	}
 
 	// Expand tilde in CacheDir path
 	if strings.HasPrefix(cfg.CacheDir, "~") {
 		currentUser, err := user.Current()
 		if err != nil {
-			return nil, fmt.Errorf("config: could not get current user to expand tilde in cache_dir: %w", err)
+			return fmt.Errorf("config: could not get current user to expand tilde in cache_dir: %w", err)
 		}
 		cfg.CacheDir = filepath.Join(currentUser.HomeDir, cfg.CacheDir[1:])
 	}
 
-	return cfg, nil
+	return nil
 }
 
 // FindConfigFile searches for the configuration file in a predefined order.
@@ -5,6 +5,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gyr/relx-go/pkg/config"
 	"github.com/gyr/relx-go/pkg/logging"
@@ -74,3 +75,96 @@ func TestLoadConfigDefaultCacheDir(t *testing.T) {
 		t.Errorf("Expected default CacheDir to be %q, but got %q", expectedPath, cfg.CacheDir)
 	}
 }
+
+func TestLoadConfigMetadataCacheTTL(t *testing.T) {
+	tests := []struct {
+		content string
+		want    time.Duration
+	}{
+		{"debug: true", 5 * time.Minute},
+		{"metadata_cache_ttl_seconds: 60", time.Minute},
+		{"metadata_cache_ttl_seconds: -1", 0},
+	}
+
+	for _, tt := range tests {
+		configFile := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+			t.Fatalf("Failed to write temp config file: %v", err)
+		}
+
+		cfg, err := config.LoadConfig(configFile)
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		if got := cfg.MetadataCacheTTL(); got != tt.want {
+			t.Errorf("For %q, expected MetadataCacheTTL() to be %v, but got %v", tt.content, tt.want, got)
+		}
+		// PR lists are only memoized when pr_list_cache_ttl_seconds is set.
+		if got := cfg.PRListCacheTTL(); got != 0 {
+			t.Errorf("For %q, expected PRListCacheTTL() to be 0, but got %v", tt.content, got)
+		}
+	}
+}
+
+func TestLoadConfigOperationTimeout(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	content := "operation_timeout_seconds: 60\ntimeouts:\n  git_clone: 900\n  gitea_comment: 10\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write temp config file: %v", err)
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	for operation, want := range map[string]time.Duration{
+		"git_clone":     15 * time.Minute,
+		"gitea_comment": 10 * time.Second,
+		"git_fetch":     time.Minute, // Not set, falls back to operation_timeout_seconds
+		"git_archive":   time.Minute, // No setting of its own
+	} {
+		if got := cfg.OperationTimeout(operation); got != want {
+			t.Errorf("Expected OperationTimeout(%q) to be %v, but got %v", operation, want, got)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	defaults, err := config.Default()
+	if err != nil {
+		t.Fatalf("Default failed: %v", err)
+	}
+	if defaults.OperationTimeoutSeconds <= 0 || defaults.CacheDir == "" || defaults.Logger == nil || defaults.OutputWriter == nil {
+		t.Errorf("Expected a usable default configuration, got %+v", defaults)
+	}
+	if err := defaults.Validate(); err != nil {
+		t.Errorf("Expected the default configuration to be valid, got %v", err)
+	}
+
+	// Loading without any configuration must give the same settings as Default.
+	dir := t.TempDir()
+	loaded, err := config.Load(config.LoadOptions{
+		SystemConfigPath: filepath.Join(dir, "missing-system.yaml"),
+		UserConfigPath:   filepath.Join(dir, "missing-user.yaml"),
+		Environ:          []string{},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.CacheDir != defaults.CacheDir || loaded.OperationTimeoutSeconds != defaults.OperationTimeoutSeconds || loaded.MetadataCacheTTLSeconds != defaults.MetadataCacheTTLSeconds {
+		t.Errorf("Load without files differs from Default: %+v vs %+v", loaded, defaults)
+	}
+
+	// An explicit zero timeout still means the default.
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("operation_timeout_seconds: 0\n"), 0644); err != nil {
+		t.Fatalf("Failed to write temp config file: %v", err)
+	}
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.OperationTimeoutSeconds != defaults.OperationTimeoutSeconds {
+		t.Errorf("Expected the default timeout, got %d", cfg.OperationTimeoutSeconds)
+	}
+}
@@ -0,0 +1,509 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of environment variables that override single settings,
+// e.g. RELX_GO_OBS_API_URL overrides obs_api_url.
+const EnvPrefix = "RELX_GO_"
+
+// ConfigFileEnv is the environment variable naming an additional configuration file.
+const ConfigFileEnv = "RELX_GO_CONFIG_FILE"
+
+// ProfileEnv is the environment variable selecting a profile when --profile is not given.
+const ProfileEnv = "RELX_GO_PROFILE"
+
+// profilesKey is the setting holding the named profiles.
+const profilesKey = "profiles"
+
+// Origins of settings that do not come from a configuration file.
+// Settings read from a file have the origin "<path>:<line>:<column>".
+const (
+	OriginDefault = "default"
+	OriginSet     = "--set"
+)
+
+// LoadOptions controls which layers Load reads.
+type LoadOptions struct {
+	// ConfigPath is the file given with -c. It must exist if set.
+	ConfigPath string
+	// Sets are "key=value" overrides given with --set. They take precedence over everything else.
+	Sets []string
+	// Environ is the environment in the form returned by os.Environ. Nil means os.Environ().
+	Environ []string
+	// SystemConfigPath overrides the system-wide configuration file (/etc/relx-go/config.yaml).
+	SystemConfigPath string
+	// UserConfigPath overrides the per-user configuration file (~/.config/relx-go/config.yaml).
+	UserConfigPath string
+	// Profile is the profile given with --profile. If empty, RELX_GO_PROFILE is used.
+	Profile string
+	// SkipValidation returns the configuration even if Config.Validate reports problems,
+	// e.g. to show or validate a broken configuration.
+	SkipValidation bool
+}
+
+// layeredConfig collects the settings of all layers. Later layers replace whole settings
+// of earlier ones; lists and nested values are not merged.
+type layeredConfig struct {
+	values  map[string]*yaml.Node
+	origins map[string]string
+	// profiles holds the settings of each profile. Profiles defined in several
+	// files are merged setting by setting.
+	profiles map[string]map[string]profileSetting
+	// problems collects unknown settings; they are reported by Config.Validate.
+	problems []ValidationError
+}
+
+// profileSetting is a setting of a profile, with the function returning the origin of its nodes.
+type profileSetting struct {
+	key, value *yaml.Node
+	originAt   func(*yaml.Node) string
+}
+
+// Load builds the configuration from several layers, each one overriding the previous ones:
+//
+//  1. the system configuration file (/etc/relx-go/config.yaml)
+//  2. the user configuration file (~/.config/relx-go/config.yaml)
+//  3. the file named by RELX_GO_CONFIG_FILE
+//  4. the file given with -c (opts.ConfigPath)
+//  5. the selected profile (opts.Profile or RELX_GO_PROFILE)
+//  6. RELX_GO_<KEY> environment variables, e.g. RELX_GO_REPO_BRANCH
+//  7. --set key=value overrides
+//
+// Missing system and user files are skipped; files named explicitly must exist.
+// Values from the environment and --set are parsed as YAML, so lists can be given
+// in flow style, e.g. --set 'binary_filter_patterns=["*.iso"]'.
+// The origin of every effective setting, including nested ones like "repositories[1].url",
+// is recorded in Config.Origins. Unless opts.SkipValidation is set, the configuration is
+// validated and ValidationErrors are returned for unknown settings and invalid values.
+func Load(opts LoadOptions) (*Config, error) {
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, found := strings.Cut(kv, "="); found {
+			env[k] = v
+		}
+	}
+
+	systemPath := opts.SystemConfigPath
+	if systemPath == "" {
+		systemPath = filepath.Join("/etc", "relx-go", "config.yaml")
+	}
+	userPath := opts.UserConfigPath
+	if userPath == "" {
+		var err error
+		if userPath, err = UserConfigPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	layers := &layeredConfig{
+		values:   map[string]*yaml.Node{},
+		origins:  map[string]string{},
+		profiles: map[string]map[string]profileSetting{},
+	}
+	var files []string
+
+	for _, file := range []struct {
+		path     string
+		required bool
+	}{
+		{systemPath, false},
+		{userPath, false},
+		{env[ConfigFileEnv], true},
+		{opts.ConfigPath, true},
+	} {
+		if file.path == "" {
+			continue
+		}
+		loaded, err := layers.mergeFile(file.path, file.required)
+		if err != nil {
+			return nil, err
+		}
+		if loaded {
+			files = append(files, file.path)
+		}
+	}
+
+	// The profile is applied on top of all files, so that it can override any of them,
+	// while the environment and --set can still override the profile.
+	profile := opts.Profile
+	if profile == "" {
+		profile = env[ProfileEnv]
+	}
+	if profile != "" {
+		if err := layers.applyProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := layers.mergeEnv(env); err != nil {
+		return nil, err
+	}
+	for _, set := range opts.Sets {
+		if err := layers.mergeSet(set); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	if err := layers.decodeInto(cfg); err != nil {
+		return nil, err
+	}
+	if err := applyDefaults(cfg, layers.origins); err != nil {
+		return nil, err
+	}
+	cfg.Origins = layers.origins
+	cfg.Files = files
+	cfg.Profile = profile
+	cfg.loadProblems = layers.problems
+
+	if !opts.SkipValidation {
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// UserConfigPath returns the path of the per-user configuration file.
+func UserConfigPath() (string, error) {
+	currentUser, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("config: could not get current user: %w", err)
+	}
+	return filepath.Join(currentUser.HomeDir, ".config", "relx-go", "config.yaml"), nil
+}
+
+// mergeFile merges the settings of a YAML file. It reports whether the file was read;
+// a missing file is only an error if it is required.
+func (l *layeredConfig) mergeFile(path string, required bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("config: failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false, fmt.Errorf("config: failed to parse %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return true, nil // Empty file
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return false, fmt.Errorf("config: %s:%d:%d: expected a mapping of settings", path, root.Line, root.Column)
+	}
+
+	location := func(n *yaml.Node) string {
+		return fmt.Sprintf("%s:%d:%d", path, n.Line, n.Column)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if err := l.set(root.Content[i], root.Content[i+1], location); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// mergeProfiles merges a mapping of profile names to settings. originAt returns the
+// origin of a setting from its node.
+func (l *layeredConfig) mergeProfiles(node *yaml.Node, originAt func(*yaml.Node) string) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("config: %s: profiles must map profile names to settings", originAt(node))
+	}
+	fields := settingFields()
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, settings := node.Content[i].Value, node.Content[i+1]
+		if settings.Kind != yaml.MappingNode {
+			return fmt.Errorf("config: %s: profile %q must be a mapping of settings", originAt(settings), name)
+		}
+		if l.profiles[name] == nil {
+			l.profiles[name] = map[string]profileSetting{}
+		}
+
+		path := fmt.Sprintf("profiles.%s", name)
+		for j := 0; j+1 < len(settings.Content); j += 2 {
+			key, value := settings.Content[j], settings.Content[j+1]
+			field, known := fields[key.Value]
+			if !known || key.Value == profilesKey {
+				l.problems = append(l.problems, unknownKeyError(originAt(key), path, key.Value, settingKeys()))
+				continue
+			}
+			if err := checkType(key.Value, value); err != nil {
+				return fmt.Errorf("config: %s: invalid value for %s in profile %q: %w", originAt(key), key.Value, name, err)
+			}
+			l.problems = append(l.problems, unknownKeys(value, field.Type, joinKey(path, key.Value), originAt)...)
+			l.profiles[name][key.Value] = profileSetting{key: key, value: value, originAt: originAt}
+		}
+	}
+	return nil
+}
+
+// applyProfile applies the settings of the named profile on top of the current settings.
+func (l *layeredConfig) applyProfile(name string) error {
+	settings, found := l.profiles[name]
+	if !found {
+		available := make([]string, 0, len(l.profiles))
+		for p := range l.profiles {
+			available = append(available, p)
+		}
+		sort.Strings(available)
+		if len(available) == 0 {
+			return fmt.Errorf("config: unknown profile %q: no profiles are configured", name)
+		}
+		return fmt.Errorf("config: unknown profile %q (available: %s)", name, strings.Join(available, ", "))
+	}
+
+	for _, key := range settingKeys() {
+		setting, found := settings[key]
+		if !found {
+			continue
+		}
+		l.store(setting.key, setting.value, func(n *yaml.Node) string {
+			return fmt.Sprintf("profile %s, %s", name, setting.originAt(n))
+		})
+	}
+	return nil
+}
+
+// profilesNode builds the YAML mapping of all merged profiles, sorted by name.
+func (l *layeredConfig) profilesNode() *yaml.Node {
+	names := make([]string, 0, len(l.profiles))
+	for name := range l.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	profiles := make(map[string]*yaml.Node, len(names))
+	for _, name := range names {
+		settings := make(map[string]*yaml.Node, len(l.profiles[name]))
+		for key, setting := range l.profiles[name] {
+			settings[key] = setting.value
+		}
+		profiles[name] = mappingNode(settings, settingKeys())
+	}
+	return mappingNode(profiles, names)
+}
+
+// mergeEnv merges RELX_GO_<KEY> environment variables. Variables that do not name
+// a setting, like RELX_GO_CONFIG_FILE, are ignored.
+func (l *layeredConfig) mergeEnv(env map[string]string) error {
+	fields := settingFields()
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	// Apply in a stable order, so that errors are deterministic.
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		key := strings.ToLower(strings.TrimPrefix(name, EnvPrefix))
+		if _, known := fields[key]; !known {
+			continue
+		}
+		value, err := parseValue(key, env[name])
+		if err != nil {
+			return fmt.Errorf("config: invalid value of %s: %w", name, err)
+		}
+		if err := l.set(keyNode(key), value, constantOrigin("env "+name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeSet merges a "key=value" override.
+func (l *layeredConfig) mergeSet(set string) error {
+	key, raw, found := strings.Cut(set, "=")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return fmt.Errorf("config: invalid --set %q, expected key=value", set)
+	}
+	if _, known := settingFields()[key]; !known {
+		return fmt.Errorf("config: unknown setting %q in --set", key)
+	}
+	value, err := parseValue(key, raw)
+	if err != nil {
+		return fmt.Errorf("config: invalid value in --set %s: %w", key, err)
+	}
+	return l.set(keyNode(key), value, constantOrigin(OriginSet))
+}
+
+// set records the value of a setting, replacing the value of earlier layers. originAt
+// returns the origin of a node of the setting. Unknown settings are recorded as problems,
+// and the value is checked against the type of the setting right away, so that errors
+// point to the layer that caused them.
+func (l *layeredConfig) set(key, value *yaml.Node, originAt func(*yaml.Node) string) error {
+	if key.Value == profilesKey {
+		// Profiles are merged setting by setting instead of being replaced.
+		if err := l.mergeProfiles(value, originAt); err != nil {
+			return err
+		}
+		l.origins[profilesKey] = originAt(key)
+		return nil
+	}
+
+	field, known := settingFields()[key.Value]
+	if !known {
+		l.problems = append(l.problems, unknownKeyError(originAt(key), "", key.Value, settingKeys()))
+		return nil
+	}
+	if err := checkType(key.Value, value); err != nil {
+		return fmt.Errorf("config: %s: invalid value for %s: %w", originAt(key), key.Value, err)
+	}
+	l.problems = append(l.problems, unknownKeys(value, field.Type, key.Value, originAt)...)
+	l.store(key, value, originAt)
+	return nil
+}
+
+// store records the value of a known setting and the origins of the setting and its
+// nested values, replacing those of earlier layers.
+func (l *layeredConfig) store(key, value *yaml.Node, originAt func(*yaml.Node) string) {
+	for k := range l.origins {
+		if strings.HasPrefix(k, key.Value+".") || strings.HasPrefix(k, key.Value+"[") {
+			delete(l.origins, k)
+		}
+	}
+	l.values[key.Value] = value
+	l.origins[key.Value] = originAt(key)
+	l.recordOrigins(key.Value, value, originAt)
+}
+
+// recordOrigins records the origins of the entries of lists and mappings below path.
+func (l *layeredConfig) recordOrigins(path string, node *yaml.Node, originAt func(*yaml.Node) string) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			l.origins[itemPath] = originAt(item)
+			l.recordOrigins(itemPath, item, originAt)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			itemPath := joinKey(path, node.Content[i].Value)
+			l.origins[itemPath] = originAt(node.Content[i])
+			l.recordOrigins(itemPath, node.Content[i+1], originAt)
+		}
+	}
+}
+
+// checkType checks that value can be decoded into the setting key.
+func checkType(key string, value *yaml.Node) error {
+	var probe Config
+	return mappingNode(map[string]*yaml.Node{key: value}, []string{key}).Decode(&probe)
+}
+
+// keyNode returns a YAML node for a setting name that does not come from a file.
+func keyNode(key string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+}
+
+// constantOrigin returns an origin function for settings that do not come from a file.
+func constantOrigin(origin string) func(*yaml.Node) string {
+	return func(*yaml.Node) string { return origin }
+}
+
+// decodeInto overlays the merged settings on cfg. Settings that no layer sets keep
+// their value from cfg and are recorded as defaults if that value is not empty.
+func (l *layeredConfig) decodeInto(cfg *Config) error {
+	if len(l.profiles) > 0 {
+		l.values[profilesKey] = l.profilesNode()
+	}
+	if err := mappingNode(l.values, settingKeys()).Decode(cfg); err != nil {
+		return fmt.Errorf("config: failed to decode configuration: %w", err)
+	}
+
+	values := reflect.ValueOf(cfg).Elem()
+	for key, field := range settingFields() {
+		if _, set := l.origins[key]; !set && !values.FieldByIndex(field.Index).IsZero() {
+			l.origins[key] = OriginDefault
+		}
+	}
+	return nil
+}
+
+// mappingNode builds a YAML mapping of the given values, in the order of keys.
+func mappingNode(values map[string]*yaml.Node, keys []string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, key := range keys {
+		value, found := values[key]
+		if !found {
+			continue
+		}
+		node.Content = append(node.Content, keyNode(key), value)
+	}
+	return node
+}
+
+// parseValue converts a value given on the command line or in the environment into a YAML node.
+// Values of string settings are taken literally; all other values are parsed as YAML.
+func parseValue(key, raw string) (*yaml.Node, error) {
+	if field, known := settingFields()[key]; known && field.Type.Kind() == reflect.String {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: raw}, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: ""}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// settingFields maps the YAML keys of all settings to their Config fields.
+func settingFields() map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := yamlKey(t.Field(i)); key != "" {
+			fields[key] = t.Field(i)
+		}
+	}
+	return fields
+}
+
+// settingKeys returns the YAML keys of all settings in the order of the Config fields.
+func settingKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := yamlKey(t.Field(i)); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// yamlKey returns the YAML key of a struct field, or "" if the field is not a setting.
+func yamlKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" || name == "" {
+		return ""
+	}
+	return name
+}
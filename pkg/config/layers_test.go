@@ -0,0 +1,236 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/config"
+)
+
+// writeConfig writes a config file into dir and returns its path.
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	systemPath := writeConfig(t, dir, "system.yaml", `
+obs_api_url: "https://api.system.example.com"
+repo_url: "https://example.com/system.git"
+repo_branch: "main"
+binary_filter_patterns: ["*.iso"]
+`)
+	userPath := writeConfig(t, dir, "user.yaml", `
+repo_url: "https://example.com/user.git"
+pr_reviewer: "user"
+`)
+	envPath := writeConfig(t, dir, "env.yaml", `pr_reviewer: "env-file"`)
+	cliPath := writeConfig(t, dir, "cli.yaml", `
+operation_timeout_seconds: 60
+`)
+
+	cfg, err := config.Load(config.LoadOptions{
+		ConfigPath:       cliPath,
+		SystemConfigPath: systemPath,
+		UserConfigPath:   userPath,
+		Environ: []string{
+			"RELX_GO_CONFIG_FILE=" + envPath,
+			"RELX_GO_REPO_BRANCH=16.0",
+			"RELX_GO_UNRELATED=ignored",
+			"HOME=/home/user",
+		},
+		Sets: []string{"operation_timeout_seconds=30", `binary_filter_patterns=["*.qcow2", "*.raw"]`},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.OBSAPIURL != "https://api.system.example.com" {
+		t.Errorf("Expected obs_api_url from the system file, got %q", cfg.OBSAPIURL)
+	}
+	if cfg.RepoURL != "https://example.com/user.git" {
+		t.Errorf("Expected repo_url from the user file, got %q", cfg.RepoURL)
+	}
+	if cfg.PRReviewer != "env-file" {
+		t.Errorf("Expected pr_reviewer from RELX_GO_CONFIG_FILE, got %q", cfg.PRReviewer)
+	}
+	if cfg.RepoBranch != "16.0" {
+		t.Errorf("Expected repo_branch from the environment as a string, got %q", cfg.RepoBranch)
+	}
+	if cfg.OperationTimeoutSeconds != 30 {
+		t.Errorf("Expected operation_timeout_seconds from --set, got %d", cfg.OperationTimeoutSeconds)
+	}
+	if !reflect.DeepEqual(cfg.BinaryFilterPatterns, []string{"*.qcow2", "*.raw"}) {
+		t.Errorf("Expected binary_filter_patterns to be replaced by --set, got %v", cfg.BinaryFilterPatterns)
+	}
+
+	wantOrigins := map[string]string{
+		"obs_api_url":               systemPath + ":2:1",
+		"repo_url":                  userPath + ":2:1",
+		"pr_reviewer":               envPath + ":1:1",
+		"repo_branch":               "env RELX_GO_REPO_BRANCH",
+		"operation_timeout_seconds": config.OriginSet,
+		"cache_dir":                 config.OriginDefault,
+	}
+	for key, want := range wantOrigins {
+		if got := cfg.Origins[key]; got != want {
+			t.Errorf("Expected origin of %s to be %q, got %q", key, want, got)
+		}
+	}
+
+	wantFiles := []string{systemPath, userPath, envPath, cliPath}
+	if !reflect.DeepEqual(cfg.Files, wantFiles) {
+		t.Errorf("Expected files %v, got %v", wantFiles, cfg.Files)
+	}
+}
+
+func TestLoadWithoutFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := config.Load(config.LoadOptions{
+		SystemConfigPath: filepath.Join(dir, "missing-system.yaml"),
+		UserConfigPath:   filepath.Join(dir, "missing-user.yaml"),
+		Environ:          []string{},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Files) != 0 {
+		t.Errorf("Expected no files to be loaded, got %v", cfg.Files)
+	}
+	if cfg.OperationTimeoutSeconds != 300 || cfg.CacheDir == "" {
+		t.Errorf("Expected defaults to be applied, got timeout %d and cache_dir %q", cfg.OperationTimeoutSeconds, cfg.CacheDir)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	badFile := writeConfig(t, dir, "bad.yaml", "repo_url: example\noperation_timeout_seconds: soon\n")
+	base := config.LoadOptions{
+		SystemConfigPath: filepath.Join(dir, "missing-system.yaml"),
+		UserConfigPath:   filepath.Join(dir, "missing-user.yaml"),
+		Environ:          []string{},
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(opts *config.LoadOptions)
+		wantErr string
+	}{
+		{"MissingConfigFile", func(o *config.LoadOptions) { o.ConfigPath = filepath.Join(dir, "missing.yaml") }, "failed to read config file"},
+		{"MissingEnvConfigFile", func(o *config.LoadOptions) {
+			o.Environ = []string{"RELX_GO_CONFIG_FILE=" + filepath.Join(dir, "missing.yaml")}
+		}, "failed to read config file"},
+		{"InvalidFileValue", func(o *config.LoadOptions) { o.ConfigPath = badFile }, badFile + ":2:1: invalid value for operation_timeout_seconds"},
+		{"InvalidEnvValue", func(o *config.LoadOptions) { o.Environ = []string{"RELX_GO_REPO_CLONE_DEPTH=deep"} }, "env RELX_GO_REPO_CLONE_DEPTH: invalid value for repo_clone_depth"},
+		{"UnknownSet", func(o *config.LoadOptions) { o.Sets = []string{"obs_url=x"} }, `unknown setting "obs_url"`},
+		{"MalformedSet", func(o *config.LoadOptions) { o.Sets = []string{"debug"} }, "expected key=value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := base
+			tt.modify(&opts)
+			_, err := config.Load(opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	dir := t.TempDir()
+	systemPath := writeConfig(t, dir, "system.yaml", `
+obs_api_url: "https://api.opensuse.org"
+repo_url: "https://example.com/products/SLFO.git"
+repo_branch: "main"
+profiles:
+  suse-internal:
+    obs_api_url: "https://api.suse.de"
+    pr_reviewer: "release-manager"
+`)
+	userPath := writeConfig(t, dir, "user.yaml", `
+profiles:
+  suse-internal:
+    pr_reviewer: "me"
+  slfo-1.1:
+    repo_branch: "1.1"
+`)
+	base := config.LoadOptions{SystemConfigPath: systemPath, UserConfigPath: userPath, Environ: []string{}}
+
+	t.Run("FlagSelectsProfile", func(t *testing.T) {
+		opts := base
+		opts.Profile = "suse-internal"
+		cfg, err := config.Load(opts)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.OBSAPIURL != "https://api.suse.de" || cfg.PRReviewer != "me" || cfg.RepoBranch != "main" {
+			t.Errorf("Unexpected settings: obs_api_url=%q, pr_reviewer=%q, repo_branch=%q", cfg.OBSAPIURL, cfg.PRReviewer, cfg.RepoBranch)
+		}
+		if want := "profile suse-internal, " + userPath + ":4:5"; cfg.Origins["pr_reviewer"] != want {
+			t.Errorf("Expected origin %q, got %q", want, cfg.Origins["pr_reviewer"])
+		}
+		if cfg.Profile != "suse-internal" {
+			t.Errorf("Expected selected profile to be recorded, got %q", cfg.Profile)
+		}
+		if len(cfg.Profiles) != 2 {
+			t.Errorf("Expected both profiles to be merged, got %v", cfg.Profiles)
+		}
+	})
+
+	t.Run("EnvSelectsProfile", func(t *testing.T) {
+		opts := base
+		opts.Environ = []string{"RELX_GO_PROFILE=slfo-1.1"}
+		cfg, err := config.Load(opts)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.RepoBranch != "1.1" || cfg.OBSAPIURL != "https://api.opensuse.org" {
+			t.Errorf("Unexpected settings: repo_branch=%q, obs_api_url=%q", cfg.RepoBranch, cfg.OBSAPIURL)
+		}
+	})
+
+	t.Run("OverridesBeatProfile", func(t *testing.T) {
+		opts := base
+		opts.Profile = "suse-internal"
+		opts.Environ = []string{"RELX_GO_PROFILE=slfo-1.1", "RELX_GO_OBS_API_URL=https://api.example.com"}
+		opts.Sets = []string{"pr_reviewer=someone"}
+		cfg, err := config.Load(opts)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if cfg.Profile != "suse-internal" {
+			t.Errorf("Expected --profile to take precedence over RELX_GO_PROFILE, got %q", cfg.Profile)
+		}
+		if cfg.OBSAPIURL != "https://api.example.com" || cfg.PRReviewer != "someone" {
+			t.Errorf("Expected env and --set to override the profile, got obs_api_url=%q, pr_reviewer=%q", cfg.OBSAPIURL, cfg.PRReviewer)
+		}
+	})
+
+	t.Run("UnknownProfile", func(t *testing.T) {
+		opts := base
+		opts.Profile = "leap"
+		_, err := config.Load(opts)
+		if err == nil || !strings.Contains(err.Error(), `unknown profile "leap" (available: slfo-1.1, suse-internal)`) {
+			t.Errorf("Expected unknown profile error, got %v", err)
+		}
+	})
+
+	t.Run("InvalidProfileSetting", func(t *testing.T) {
+		opts := base
+		opts.ConfigPath = writeConfig(t, dir, "bad.yaml", "profiles:\n  broken:\n    obs_url: x\n")
+		_, err := config.Load(opts)
+		if err == nil || !strings.Contains(err.Error(), "bad.yaml:3:5: profiles.broken.obs_url: unknown setting") {
+			t.Errorf("Expected invalid profile error, got %v", err)
+		}
+	})
+}
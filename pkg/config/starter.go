@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// starterTemplate is the commented configuration written by 'relx-go config init'.
+// Settings without a value are written commented out, so that they are easy to fill in later.
+const starterTemplate = `# relx-go configuration, created by 'relx-go config init'.
+# 'relx-go config show --origin' shows the effective settings and where they come from,
+# and config.schema.json documents all of them.
+
+# OBS API used by 'relx-go artifact' (passed to osc -A).
+{{setting "obs_api_url" .OBSAPIURL}}
+
+# Product repository used by 'relx-go bugowner', 'cat', 'export' and 'repo'.
+{{setting "repo_url" .RepoURL}}
+{{setting "repo_branch" .RepoBranch}}
+
+# Default reviewer for 'relx-go review'.
+{{setting "pr_reviewer" .PRReviewer}}
+
+# How 'relx-go review' shows pull requests: auto (the first installed of delta, bat,
+# $PAGER and less), delta, bat, less, pager ($PAGER) or none.
+# pr_viewer: auto
+
+# Branches reviewed together by 'relx-go review' when no -b/-r flags are given.
+# review_targets:
+#   - repository: "products/SLFO"
+#     branch: "main"
+
+# Timeout for remote operations in seconds.
+operation_timeout_seconds: {{.OperationTimeoutSeconds}}
+
+# Glob patterns selecting the packages and binaries listed by 'relx-go artifact'.
+# package_filter_patterns:
+#   - pattern: "000productcompose:*"
+#     repository: "product"
+# binary_filter_patterns:
+#   - "*.iso"
+#   - "*.qcow2"
+
+# Named sets of settings, selected with --profile or RELX_GO_PROFILE.
+# profiles:
+#   internal:
+#     obs_api_url: "https://api.example.com"
+`
+
+var starter = template.Must(template.New("starter").Funcs(template.FuncMap{
+	"setting": starterSetting,
+}).Parse(starterTemplate))
+
+// WriteStarter writes a commented starter configuration with the OBS API URL, repository
+// and reviewer of cfg to w.
+func WriteStarter(w io.Writer, cfg *Config) error {
+	if err := starter.Execute(w, cfg); err != nil {
+		return fmt.Errorf("config: failed to write starter configuration: %w", err)
+	}
+	return nil
+}
+
+// starterSetting renders a string setting of the starter configuration, commented out if it is empty.
+func starterSetting(key, value string) (string, error) {
+	if value == "" {
+		return fmt.Sprintf("# %s: \"\"", key), nil
+	}
+	quoted, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s: %s", key, strings.TrimSpace(string(quoted))), nil
+}
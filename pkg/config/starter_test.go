@@ -0,0 +1,42 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/config"
+)
+
+func TestWriteStarter(t *testing.T) {
+	cfg, err := config.Default()
+	if err != nil {
+		t.Fatalf("Default failed: %v", err)
+	}
+	cfg.OBSAPIURL = "https://api.example.com"
+	cfg.RepoURL = "gitea@src.example.com:products/SLFO.git"
+	cfg.PRReviewer = "reviewer: with colon"
+
+	var buf bytes.Buffer
+	if err := config.WriteStarter(&buf, cfg); err != nil {
+		t.Fatalf("WriteStarter failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `# repo_branch: ""`) {
+		t.Errorf("Expected the empty repo_branch to be commented out. Got:\n%s", buf.String())
+	}
+
+	// The starter configuration must load and keep the values.
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	loaded, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig of the starter configuration failed: %v", err)
+	}
+	if loaded.OBSAPIURL != cfg.OBSAPIURL || loaded.RepoURL != cfg.RepoURL || loaded.PRReviewer != cfg.PRReviewer || loaded.RepoBranch != "" {
+		t.Errorf("Starter configuration did not round-trip: %+v", loaded)
+	}
+}
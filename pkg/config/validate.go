@@ -0,0 +1,356 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// validUpdateStrategies are the values accepted for update_strategy settings.
+// They must match the strategies implemented by gitutils.SyncRepo.
+var validUpdateStrategies = []string{"fail", "stash", "reset", "reclone"}
+
+// validPRViewers are the values of pr_viewer.
+var validPRViewers = []string{"auto", "delta", "bat", "less", "pager", "none"}
+
+// requiredKeys lists the settings each subcommand cannot work without.
+// Settings that can also be given with a flag, like the reviewer of 'review', are not listed.
+var requiredKeys = map[string][]string{
+	"artifact": {"package_filter_patterns"},
+	"bugowner": {"repo_url", "repo_branch"},
+	"cat":      {"repo_url"},
+	"export":   {"repo_url"},
+}
+
+// scpLikeURL matches git's scp-like syntax, e.g. gitea@src.example.com:products/SLFO.git.
+var scpLikeURL = regexp.MustCompile(`^([A-Za-z0-9._-]+@)?[A-Za-z0-9.-]+:[^/]`)
+
+// ValidationError describes a problem with a single setting.
+type ValidationError struct {
+	// Origin is where the setting came from, e.g. "config.yaml:12:3". It is empty for unset settings.
+	Origin string
+	// Key is the setting, e.g. "repositories[1].url".
+	Key string
+	// Message describes the problem.
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Origin == "" {
+		return fmt.Sprintf("%s: %s", e.Key, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Origin, e.Key, e.Message)
+}
+
+// ValidationErrors is the list of problems found in a configuration.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "  " + err.Error()
+	}
+	return fmt.Sprintf("config: %d problem(s) found:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// Validate checks the configuration for unknown settings, malformed URLs, invalid glob
+// patterns, negative timeouts and limits, unknown update strategies, and repository names
+// that cannot be used in the cache. All problems
+// are reported at once as ValidationErrors; nil means the configuration is valid.
+func (c *Config) Validate() error {
+	problems := append(ValidationErrors{}, c.loadProblems...)
+	add := func(key, format string, args ...interface{}) {
+		problems = append(problems, ValidationError{Origin: c.originOf(key), Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.RepoURL != "" {
+		if err := checkGitURL(c.RepoURL); err != nil {
+			add("repo_url", "%v", err)
+		}
+	}
+	if c.OBSAPIURL != "" {
+		if err := checkHTTPURL(c.OBSAPIURL); err != nil {
+			add("obs_api_url", "%v", err)
+		}
+	}
+
+	if c.OperationTimeoutSeconds <= 0 {
+		add("operation_timeout_seconds", "must be positive, got %d", c.OperationTimeoutSeconds)
+	}
+	for _, limit := range []struct {
+		key   string
+		value int
+	}{
+		{"cache_max_size_mb", c.CacheMaxSizeMB},
+		{"cache_max_age_days", c.CacheMaxAgeDays},
+		{"cache_lock_timeout_seconds", c.CacheLockTimeoutSeconds},
+		{"pr_list_cache_ttl_seconds", c.PRListCacheTTLSeconds},
+		{"repo_clone_depth", c.RepoCloneDepth},
+	} {
+		if limit.value < 0 {
+			add(limit.key, "must not be negative, got %d", limit.value)
+		}
+	}
+
+	timeouts := reflect.ValueOf(c.Timeouts)
+	for i := 0; i < timeouts.NumField(); i++ {
+		if seconds := timeouts.Field(i).Int(); seconds < 0 {
+			add("timeouts."+yamlKey(timeouts.Type().Field(i)), "must not be negative, got %d", seconds)
+		}
+	}
+
+	if err := checkUpdateStrategy(c.RepoUpdateStrategy); err != nil {
+		add("repo_update_strategy", "%v", err)
+	}
+	if err := checkPRViewer(c.PRViewer); err != nil {
+		add("pr_viewer", "%v", err)
+	}
+	for i, repo := range c.Repositories {
+		prefix := fmt.Sprintf("repositories[%d]", i)
+		if repo.URL == "" {
+			add(prefix+".url", "is required")
+		} else if err := checkGitURL(repo.URL); err != nil {
+			add(prefix+".url", "%v", err)
+		}
+		if repo.Branch == "" {
+			add(prefix+".branch", "is required")
+		}
+		if err := checkRepositoryName(repo.Name); err != nil {
+			add(prefix+".name", "%v", err)
+		}
+		if err := checkUpdateStrategy(repo.UpdateStrategy); err != nil {
+			add(prefix+".update_strategy", "%v", err)
+		}
+		if repo.Depth < 0 {
+			add(prefix+".depth", "must not be negative, got %d", repo.Depth)
+		}
+	}
+
+	for i, target := range c.ReviewTargets {
+		prefix := fmt.Sprintf("review_targets[%d]", i)
+		if target.Repository == "" {
+			add(prefix+".repository", "is required")
+		}
+		if target.Branch == "" {
+			add(prefix+".branch", "is required")
+		}
+	}
+
+	for i, filter := range c.PackageFilterPatterns {
+		if _, err := filepath.Match(filter.Pattern, ""); err != nil {
+			add(fmt.Sprintf("package_filter_patterns[%d].pattern", i), "invalid glob pattern %q", filter.Pattern)
+		}
+	}
+	for i, pattern := range c.BinaryFilterPatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			add(fmt.Sprintf("binary_filter_patterns[%d]", i), "invalid glob pattern %q", pattern)
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// CheckRequired returns ValidationErrors naming the settings that the given subcommand
+// needs but that are not set.
+func (c *Config) CheckRequired(command string) error {
+	values := reflect.ValueOf(c).Elem()
+	fields := settingFields()
+
+	var problems ValidationErrors
+	for _, key := range requiredKeys[command] {
+		if values.FieldByIndex(fields[key].Index).IsZero() {
+			problems = append(problems, ValidationError{Key: key, Message: fmt.Sprintf("is required by '%s'", command)})
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// originOf returns the origin of a setting. Nested settings without an origin of
+// their own, like "repositories[1]", fall back to the origin of their parent.
+func (c *Config) originOf(key string) string {
+	for key != "" {
+		if origin, found := c.Origins[key]; found {
+			return origin
+		}
+		cut := strings.LastIndexAny(key, ".[")
+		if cut == -1 {
+			break
+		}
+		key = key[:cut]
+	}
+	return ""
+}
+
+// checkGitURL checks that a repository URL has a form git understands: a URL with a
+// supported scheme, scp-like syntax or an absolute local path.
+func checkGitURL(s string) error {
+	if strings.HasPrefix(s, "/") {
+		return nil
+	}
+	if !strings.Contains(s, "://") {
+		if scpLikeURL.MatchString(s) {
+			return nil
+		}
+		return fmt.Errorf("invalid repository URL %q", s)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid repository URL %q: %v", s, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "ssh", "git":
+		if u.Host == "" {
+			return fmt.Errorf("repository URL %q has no host", s)
+		}
+	case "file":
+	default:
+		return fmt.Errorf("unsupported scheme %q in repository URL %q", u.Scheme, s)
+	}
+	return nil
+}
+
+// checkHTTPURL checks that s is an absolute http(s) URL.
+func checkHTTPURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", s, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL %q must start with http:// or https://", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL %q has no host", s)
+	}
+	return nil
+}
+
+// checkRepositoryName checks that name is empty or can name the clone in the cache: a
+// single path component that does not start with a dot, like the names cache.Remove accepts.
+func checkRepositoryName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/"+string(filepath.Separator)) {
+		return fmt.Errorf("invalid repository name %q: must not contain path separators or start with a dot", name)
+	}
+	return nil
+}
+
+// checkUpdateStrategy checks that strategy is empty or a known update strategy.
+func checkUpdateStrategy(strategy string) error {
+	if strategy == "" {
+		return nil
+	}
+	for _, valid := range validUpdateStrategies {
+		if strategy == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown update strategy %q (valid: %s)", strategy, strings.Join(validUpdateStrategies, ", "))
+}
+
+// checkPRViewer checks that viewer is empty or a known pull request viewer.
+func checkPRViewer(viewer string) error {
+	if viewer == "" {
+		return nil
+	}
+	for _, valid := range validPRViewers {
+		if viewer == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown viewer %q (valid: %s)", viewer, strings.Join(validPRViewers, ", "))
+}
+
+// unknownKeys walks a YAML value and reports mapping keys that do not correspond to a
+// field of t, including keys of nested structs like the entries of 'repositories'.
+// path is the setting the value belongs to, and originAt returns the origin of a node.
+func unknownKeys(node *yaml.Node, t reflect.Type, path string, originAt func(*yaml.Node) string) []ValidationError {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var problems []ValidationError
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		known := make(map[string]reflect.StructField)
+		var names []string
+		for i := 0; i < t.NumField(); i++ {
+			if key := yamlKey(t.Field(i)); key != "" {
+				known[key] = t.Field(i)
+				names = append(names, key)
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, found := known[key.Value]
+			if !found {
+				problems = append(problems, unknownKeyError(originAt(key), path, key.Value, names))
+				continue
+			}
+			problems = append(problems, unknownKeys(value, field.Type, joinKey(path, key.Value), originAt)...)
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			problems = append(problems, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), originAt)...)
+		}
+	}
+	return problems
+}
+
+// unknownKeyError reports an unknown key and suggests the closest known key, which
+// catches typos like "pacakge_filter_patterns".
+func unknownKeyError(origin, path, key string, known []string) ValidationError {
+	message := "unknown setting"
+	best, bestDistance := "", len(key)/3+1
+	for _, candidate := range known {
+		if d := editDistance(key, candidate); d <= bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best != "" {
+		message += fmt.Sprintf(", did you mean %q?", best)
+	}
+	return ValidationError{Origin: origin, Key: joinKey(path, key), Message: message}
+}
+
+// joinKey appends key to the setting path.
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,191 @@
+package config_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/config"
+)
+
+func TestValidate(t *testing.T) {
+	valid := func() *config.Config {
+		return &config.Config{
+			RepoURL:                 "gitea@src.example.com:products/SLFO.git",
+			OBSAPIURL:               "https://api.example.com",
+			OperationTimeoutSeconds: 300,
+			Repositories:            []config.Repository{{URL: "https://example.com/products/SLES.git", Branch: "16.0"}},
+			ReviewTargets:           []config.ReviewTarget{{Repository: "products/SLFO", Branch: "main"}},
+			PackageFilterPatterns:   []config.PackageFilter{{Pattern: "000productcompose:*"}},
+			BinaryFilterPatterns:    []string{"*.iso"},
+		}
+	}
+
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Expected a valid configuration, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.Config)
+		wantKey string
+		wantMsg string
+	}{
+		{"RepoURL", func(c *config.Config) { c.RepoURL = "example.com/repo" }, "repo_url", "invalid repository URL"},
+		{"RepoURLScheme", func(c *config.Config) { c.RepoURL = "ftp://example.com/repo" }, "repo_url", `unsupported scheme "ftp"`},
+		{"OBSAPIURL", func(c *config.Config) { c.OBSAPIURL = "api.example.com" }, "obs_api_url", "must start with http:// or https://"},
+		{"Timeout", func(c *config.Config) { c.OperationTimeoutSeconds = -1 }, "operation_timeout_seconds", "must be positive"},
+		{"NegativeOperationTimeout", func(c *config.Config) { c.Timeouts.GitClone = -1 }, "timeouts.git_clone", "must not be negative"},
+		{"NegativeLimit", func(c *config.Config) { c.CacheMaxSizeMB = -1 }, "cache_max_size_mb", "must not be negative"},
+		{"UpdateStrategy", func(c *config.Config) { c.RepoUpdateStrategy = "merge" }, "repo_update_strategy", `unknown update strategy "merge"`},
+		{"PRViewer", func(c *config.Config) { c.PRViewer = "vim" }, "pr_viewer", `unknown viewer "vim"`},
+		{"RepositoryBranch", func(c *config.Config) { c.Repositories[0].Branch = "" }, "repositories[0].branch", "is required"},
+		{"RepositoryURL", func(c *config.Config) { c.Repositories[0].URL = "" }, "repositories[0].url", "is required"},
+		{"RepositoryNameSeparator", func(c *config.Config) { c.Repositories[0].Name = "../SLES" }, "repositories[0].name", "invalid repository name"},
+		{"RepositoryNameDot", func(c *config.Config) { c.Repositories[0].Name = ".blobs" }, "repositories[0].name", "invalid repository name"},
+		{"ReviewTargetBranch", func(c *config.Config) { c.ReviewTargets[0].Branch = "" }, "review_targets[0].branch", "is required"},
+		{"PackagePattern", func(c *config.Config) { c.PackageFilterPatterns[0].Pattern = "pkg[" }, "package_filter_patterns[0].pattern", "invalid glob pattern"},
+		{"BinaryPattern", func(c *config.Config) { c.BinaryFilterPatterns = []string{`*.iso\`} }, "binary_filter_patterns[0]", "invalid glob pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+
+			var problems config.ValidationErrors
+			if err := cfg.Validate(); !errors.As(err, &problems) {
+				t.Fatalf("Expected ValidationErrors, got %v", err)
+			}
+			if len(problems) != 1 || problems[0].Key != tt.wantKey || !strings.Contains(problems[0].Message, tt.wantMsg) {
+				t.Errorf("Expected a problem with %s containing %q, got %v", tt.wantKey, tt.wantMsg, problems)
+			}
+		})
+	}
+}
+
+func TestLoadReportsProblemsWithLocation(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "config.yaml", `repo_url: "https://example.com/products/SLFO.git"
+pacakge_filter_patterns:
+  - pattern: "*"
+repositories:
+  - url: "https://example.com/products/SLES.git"
+    brnach: "16.0"
+binary_filter_patterns:
+  - "*.iso"
+  - "[broken"
+`)
+	opts := config.LoadOptions{
+		ConfigPath:       path,
+		SystemConfigPath: filepath.Join(dir, "missing-system.yaml"),
+		UserConfigPath:   filepath.Join(dir, "missing-user.yaml"),
+		Environ:          []string{},
+	}
+
+	_, err := config.Load(opts)
+	var problems config.ValidationErrors
+	if !errors.As(err, &problems) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+
+	want := []string{
+		path + `:2:1: pacakge_filter_patterns: unknown setting, did you mean "package_filter_patterns"?`,
+		path + `:6:5: repositories[0].brnach: unknown setting, did you mean "branch"?`,
+		path + `:5:5: repositories[0].branch: is required`,
+		path + `:9:5: binary_filter_patterns[1]: invalid glob pattern "[broken"`,
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Problems mismatch:\nGot:\n%s\nWant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Inspecting a broken configuration is still possible.
+	opts.SkipValidation = true
+	cfg, err := config.Load(opts)
+	if err != nil {
+		t.Fatalf("Expected no error with SkipValidation, got %v", err)
+	}
+	if err := cfg.Validate(); !errors.As(err, &problems) || len(problems) != 4 {
+		t.Errorf("Expected Validate to report the same problems, got %v", err)
+	}
+}
+
+func TestLoadConfigRejectsUnknownKeys(t *testing.T) {
+	configFile := writeConfig(t, t.TempDir(), "config.yaml", "pacakge_filter_patterns: []\n")
+	_, err := config.LoadConfig(configFile)
+	if err == nil || !strings.Contains(err.Error(), "field pacakge_filter_patterns not found") {
+		t.Errorf("Expected unknown field error, got %v", err)
+	}
+}
+
+func TestCheckRequired(t *testing.T) {
+	cfg := &config.Config{RepoURL: "https://example.com/products/SLFO.git"}
+
+	if err := cfg.CheckRequired("cat"); err != nil {
+		t.Errorf("Expected no error for 'cat', got %v", err)
+	}
+	if err := cfg.CheckRequired("review"); err != nil {
+		t.Errorf("Expected no error for 'review', got %v", err)
+	}
+
+	var problems config.ValidationErrors
+	if err := cfg.CheckRequired("bugowner"); !errors.As(err, &problems) || len(problems) != 1 || problems[0].Key != "repo_branch" {
+		t.Errorf("Expected repo_branch to be required by 'bugowner', got %v", err)
+	}
+}
+
+// TestSchemaMatchesConfig makes sure the published JSON Schema covers exactly the
+// settings of the configuration structs.
+func TestSchemaMatchesConfig(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "config.schema.json"))
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		typ        reflect.Type
+		properties map[string]json.RawMessage
+	}{
+		{"Config", reflect.TypeOf(config.Config{}), schema.Properties},
+		{"Repository", reflect.TypeOf(config.Repository{}), schema.Defs["repository"].Properties},
+		{"PackageFilter", reflect.TypeOf(config.PackageFilter{}), schema.Defs["packageFilter"].Properties},
+		{"ReviewTarget", reflect.TypeOf(config.ReviewTarget{}), schema.Defs["reviewTarget"].Properties},
+		{"Timeouts", reflect.TypeOf(config.Timeouts{}), schema.Defs["timeouts"].Properties},
+	} {
+		var want []string
+		for i := 0; i < tc.typ.NumField(); i++ {
+			name, _, _ := strings.Cut(tc.typ.Field(i).Tag.Get("yaml"), ",")
+			if name != "" && name != "-" {
+				want = append(want, name)
+			}
+		}
+		var got []string
+		for name := range tc.properties {
+			got = append(got, name)
+		}
+		sort.Strings(want)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Schema properties of %s do not match the struct:\nGot:  %v\nWant: %v", tc.name, got, want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package core
 
+import "time"
+
 // PullRequest is a normalized structure representing a pull request result.
 // Gitea will populate this via JSON unmarshaling.
 type PullRequest struct {
@@ -11,6 +13,32 @@ type PullRequest struct {
 	State string `json:"state"`
 	// URL is the HTML URL of the pull request.
 	URL string `json:"html_url"`
+	// Number is the index of the pull request in its repository, e.g. 499 in products/SLES#499.
+	Number int `json:"number"`
+	// User is the author of the pull request.
+	User User `json:"user"`
+	// CreatedAt is when the pull request was opened.
+	CreatedAt time.Time `json:"created_at"`
+	// Head is the source branch of the pull request.
+	Head Branch `json:"head"`
+	// CIState is the combined state of the commit statuses of Head (e.g., "success",
+	// "pending", "failure"), or empty if unknown. It is not part of the pull request
+	// in the Gitea API.
+	CIState string `json:"-"`
+}
+
+// User is a normalized structure representing a Gitea user.
+type User struct {
+	// Login is the user name.
+	Login string `json:"login"`
+}
+
+// Branch is a normalized structure representing a branch of a pull request.
+type Branch struct {
+	// Ref is the name of the branch.
+	Ref string `json:"ref"`
+	// SHA is the commit at the tip of the branch.
+	SHA string `json:"sha"`
 }
 
 // BuildStatus is a normalized structure for build results.
@@ -1,20 +1,30 @@
 package gitea
 
 import (
-	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
-	"time"
+	"sync"
 
+	"github.com/gyr/relx-go/pkg/cache"
 	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/core"
+	"github.com/gyr/relx-go/pkg/timeout"
 )
 
 // Client handles interaction with the Gitea API via the 'git-obs api' command.
 type Client struct {
 	runner command.Runner
 	cfg    *config.Config
+
+	// The viewer of ShowPullRequest, resolved on first use.
+	viewerOnce sync.Once
+	viewer     string
+	viewerCmd  []string
 }
 
 // NewClient creates a new Gitea client instance.
@@ -25,30 +35,51 @@ func NewClient(runner command.Runner, cfg *config.Config) *Client {
 	}
 }
 
-// ShowPullRequest executes the `git obs pr show` command and pipes its output to `delta` to display the content and diff of a pull request.
-func (c *Client) ShowPullRequest(ctx context.Context, repository, prID string) error {
-	timeout := time.Duration(c.cfg.OperationTimeoutSeconds) * time.Second
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	gitObsCmd := []string{
-		"git-obs",
-		"pr",
-		"show",
-		"--timeline",
-		"--patch",
-		fmt.Sprintf("%s#%s", repository, prID),
+// ShowPullRequest executes the `git obs pr show` command to display part of a pull request
+// (see ShowParts) and pipes its output to the viewer selected by ResolveViewer. With
+// ViewerNone, the output is written to the output of the configuration as it is.
+func (c *Client) ShowPullRequest(ctx context.Context, repository, prID, part string) error {
+	args, err := showArgs(part)
+	if err != nil {
+		return err
 	}
-	deltaCmd := []string{"delta"}
+	gitObsCmd := append([]string{"git-obs"}, args...)
+	gitObsCmd = append(gitObsCmd, fmt.Sprintf("%s#%s", repository, prID))
+
+	c.viewerOnce.Do(func() {
+		var err error
+		c.viewer, c.viewerCmd, err = ResolveViewer(c.cfg)
+		if err != nil {
+			c.cfg.Logger.Warnf("%v; using %s instead", err, c.viewer)
+		}
+	})
 
-	return c.runner.RunPipeline(timeoutCtx, "" /* workDir */, gitObsCmd, deltaCmd)
+	timeoutCtx, op := timeout.Start(ctx, c.cfg, timeout.GiteaShow)
+	defer op.Stop()
+
+	stages := [][]string{gitObsCmd}
+	if c.viewerCmd != nil {
+		stages = append(stages, c.viewerCmd)
+	}
+	err = c.runner.RunPipeline(timeoutCtx, command.Pipeline{
+		Stages: stages,
+		Stdin:  c.cfg.InputReader, // The input of git-obs; viewers such as less read keys from /dev/tty
+		Stdout: c.cfg.OutputWriter,
+		Stderr: os.Stderr,
+	})
+	// Quitting the viewer before the end of the output is not an error of git-obs.
+	var pipelineErr *command.PipelineError
+	if c.viewerCmd != nil && errors.As(err, &pipelineErr) && pipelineErr.OnlyBrokenPipes() {
+		c.cfg.Logger.Debugf("The viewer was quit before the end of pull request %s#%s.", repository, prID)
+		return nil
+	}
+	return op.Err(err)
 }
 
 // GetOpenPullRequests executes the `git obs pr list` command to get the list of open pull requests.
 func (c *Client) GetOpenPullRequests(ctx context.Context, prReviewer, branch, repository string) ([]string, error) {
-	timeout := time.Duration(c.cfg.OperationTimeoutSeconds) * time.Second
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	timeoutCtx, op := timeout.Start(ctx, c.cfg, timeout.GiteaList)
+	defer op.Stop()
 
 	args := []string{
 		"pr",
@@ -61,25 +92,24 @@ func (c *Client) GetOpenPullRequests(ctx context.Context, prReviewer, branch, re
 		repository,
 	}
 
-	output, err := c.runner.Run(timeoutCtx, "" /* workDir */, "git-obs", args...)
-	if err != nil {
-		return nil, fmt.Errorf("gitea: 'git-obs pr list' failed: %w", err)
-	}
-
+	// Only the IDs are kept of the output of git-obs, which is read as it is printed.
 	var prIDs []string
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	for scanner.Scan() {
-		line := scanner.Text()
+	fetch := func(emit func(line string) error) error {
+		_, err := c.runner.Stream(timeoutCtx, "" /* workDir */, emit, "git-obs", args...)
+		return err
+	}
+	cacheKey := fmt.Sprintf("%s%s/%s", prListCachePrefix(repository), branch, prReviewer)
+	err := cache.RememberLines(c.cfg.CacheDir, cacheKey, c.cfg.PRListCacheTTL(), fetch, func(line string) error {
 		if strings.HasPrefix(line, "ID") {
 			parts := strings.Split(line, "#")
 			if len(parts) == 2 {
 				prIDs = append(prIDs, strings.TrimSpace(parts[1]))
 			}
 		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("gitea: failed to read command output: %w", err)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gitea: 'git-obs pr list' failed: %w", op.Err(err))
 	}
 
 	return prIDs, nil
@@ -87,21 +117,103 @@ func (c *Client) GetOpenPullRequests(ctx context.Context, prReviewer, branch, re
 
 // ApprovePullRequest adds a comment to the PR.
 func (c *Client) ApprovePullRequest(ctx context.Context, repository, prID, reviewer string) error {
-	timeout := time.Duration(c.cfg.OperationTimeoutSeconds) * time.Second
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	return c.CommentPullRequest(ctx, repository, prID, fmt.Sprintf(" @%s: approve", reviewer))
+}
+
+// CommentPullRequest adds a comment with the given message to the PR.
+func (c *Client) CommentPullRequest(ctx context.Context, repository, prID, message string) error {
+	timeoutCtx, op := timeout.Start(ctx, c.cfg, timeout.GiteaComment)
+	defer op.Stop()
 
 	args := []string{
 		"pr",
 		"comment",
 		fmt.Sprintf("%s#%s", repository, prID),
 		"--message",
-		fmt.Sprintf(" @%s: approve", reviewer),
+		message,
+	}
+
+	if _, err := c.runner.Exec(timeoutCtx, "" /* workDir */, "git-obs", args...); err != nil {
+		return fmt.Errorf("gitea: 'git-obs pr comment' failed: %w", op.Err(err))
+	}
+
+	// A comment may change the review state, so memoized PR lists of the repository are stale.
+	c.invalidatePullRequestLists(repository)
+	return nil
+}
+
+// GetPullRequest reads the details of a PR, including the combined state of its CI
+// statuses, from the Gitea API.
+func (c *Client) GetPullRequest(ctx context.Context, repository, prID string) (*core.PullRequest, error) {
+	var pr core.PullRequest
+	if err := c.api(ctx, fmt.Sprintf("/repos/%s/pulls/%s", repository, prID), &pr); err != nil {
+		return nil, err
+	}
+	if pr.Head.SHA == "" {
+		return &pr, nil
+	}
+
+	var status struct {
+		State string `json:"state"`
+	}
+	if err := c.api(ctx, fmt.Sprintf("/repos/%s/commits/%s/status", repository, pr.Head.SHA), &status); err != nil {
+		// The PR is still worth showing without its CI state.
+		c.cfg.Logger.Warnf("Failed to get the CI state of %s#%s: %v", repository, prID, err)
+		return &pr, nil
+	}
+	pr.CIState = status.State
+	return &pr, nil
+}
+
+// PullRequestText returns part of a PR (see ShowParts) as printed by `git obs pr show`,
+// for callers that display it themselves.
+func (c *Client) PullRequestText(ctx context.Context, repository, prID, part string) ([]byte, error) {
+	args, err := showArgs(part)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, fmt.Sprintf("%s#%s", repository, prID))
+
+	timeoutCtx, op := timeout.Start(ctx, c.cfg, timeout.GiteaShow)
+	defer op.Stop()
+
+	result, err := c.runner.Exec(timeoutCtx, "" /* workDir */, "git-obs", args...)
+	if err != nil {
+		return nil, fmt.Errorf("gitea: 'git-obs pr show' failed: %w", op.Err(err))
 	}
+	return result.Stdout, nil
+}
+
+// api runs `git-obs api` for a path of the Gitea API and decodes the JSON response into v.
+func (c *Client) api(ctx context.Context, path string, v interface{}) error {
+	timeoutCtx, op := timeout.Start(ctx, c.cfg, timeout.GiteaAPI)
+	defer op.Stop()
 
-	_, err := c.runner.Run(timeoutCtx, "" /* workDir */, "git-obs", args...)
+	result, err := c.runner.Exec(timeoutCtx, "" /* workDir */, "git-obs", "api", path)
 	if err != nil {
-		return fmt.Errorf("gitea: 'git-obs pr comment' failed: %w", err)
+		return fmt.Errorf("gitea: 'git-obs api %s' failed: %w", path, op.Err(err))
+	}
+	if err := json.Unmarshal(result.Stdout, v); err != nil {
+		return fmt.Errorf("gitea: invalid response of 'git-obs api %s': %w", path, err)
 	}
 	return nil
 }
+
+// prListCachePrefix returns the cache key prefix of the memoized PR lists of a repository.
+func prListCachePrefix(repository string) string {
+	return fmt.Sprintf("gitea/pr-list/%s/", repository)
+}
+
+// invalidatePullRequestLists drops the memoized PR lists of a repository.
+func (c *Client) invalidatePullRequestLists(repository string) {
+	if c.cfg.CacheDir == "" {
+		return
+	}
+	store, err := cache.New(c.cfg.CacheDir)
+	if err == nil {
+		_, err = store.Invalidate(prListCachePrefix(repository))
+	}
+	if err != nil {
+		c.cfg.Logger.Warnf("Failed to invalidate cached pull request lists for %s: %v", repository, err)
+	}
+}
@@ -1,13 +1,19 @@
 package gitea
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/command/commandtest"
 	"github.com/gyr/relx-go/pkg/config"
 	"github.com/gyr/relx-go/pkg/logging"
@@ -74,6 +80,20 @@ State       : open
 	})
 }
 
+// installed replaces lookPath, so that only the given executables are found.
+func installed(t *testing.T, names ...string) {
+	t.Helper()
+	lookPath = func(file string) (string, error) {
+		for _, name := range names {
+			if file == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+	}
+	t.Cleanup(func() { lookPath = exec.LookPath })
+}
+
 func TestShowPullRequest(t *testing.T) {
 	mockCfg := &config.Config{
 		Logger:                  logging.NewLogger(logging.LevelDebug),
@@ -83,8 +103,13 @@ func TestShowPullRequest(t *testing.T) {
 	const prID = "123"
 
 	t.Run("Success", func(t *testing.T) {
+		installed(t, "delta")
 		mockRunner := &commandtest.MockRunner{}
-		mockRunner.RunPipelineFunc = func(ctx context.Context, workDir string, cmd1, cmd2 []string) error {
+		mockRunner.RunPipelineFunc = func(ctx context.Context, p command.Pipeline) error {
+			if len(p.Stages) != 2 {
+				return fmt.Errorf("expected 2 stages, got %v", p.Stages)
+			}
+			cmd1, cmd2 := p.Stages[0], p.Stages[1]
 			expectedCmd1 := []string{"git-obs", "pr", "show", "--timeline", "--patch", "test_repo#123"}
 			expectedCmd2 := []string{"delta"}
 			if !reflect.DeepEqual(cmd1, expectedCmd1) {
@@ -97,22 +122,135 @@ func TestShowPullRequest(t *testing.T) {
 		}
 
 		client := NewClient(mockRunner, mockCfg)
-		err := client.ShowPullRequest(context.Background(), repository, prID)
+		err := client.ShowPullRequest(context.Background(), repository, prID, ShowAll)
 		if err != nil {
 			t.Fatalf("Expected no error, got %v", err)
 		}
 	})
 
+	t.Run("Parts", func(t *testing.T) {
+		installed(t, "less")
+		for part, want := range map[string][]string{
+			ShowDescription: {"git-obs", "pr", "show", "test_repo#123"},
+			ShowTimeline:    {"git-obs", "pr", "show", "--timeline", "test_repo#123"},
+			ShowDiff:        {"git-obs", "pr", "show", "--patch", "test_repo#123"},
+		} {
+			var got []string
+			mockRunner := &commandtest.MockRunner{
+				RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+					got = p.Stages[0]
+					return nil
+				},
+			}
+			if err := NewClient(mockRunner, mockCfg).ShowPullRequest(context.Background(), repository, prID, part); err != nil {
+				t.Fatalf("Expected no error for %s, got %v", part, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Unexpected command for %s: got %v, want %v", part, got, want)
+			}
+		}
+
+		err := NewClient(&commandtest.MockRunner{}, mockCfg).ShowPullRequest(context.Background(), repository, prID, "files")
+		if err == nil || !strings.Contains(err.Error(), `unknown part "files"`) {
+			t.Errorf("Expected an error for an unknown part, got %v", err)
+		}
+	})
+
+	t.Run("NoViewer", func(t *testing.T) {
+		installed(t)
+		var out bytes.Buffer
+		cfg := *mockCfg
+		cfg.OutputWriter = &out
+		var got [][]string
+		mockRunner := &commandtest.MockRunner{
+			RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+				got = p.Stages
+				_, err := io.WriteString(p.Stdout, "description\n")
+				return err
+			},
+		}
+		if err := NewClient(mockRunner, &cfg).ShowPullRequest(context.Background(), repository, prID, ShowDescription); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if want := [][]string{{"git-obs", "pr", "show", "test_repo#123"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected git-obs to print directly with %v, got %v", want, got)
+		}
+		if out.String() != "description\n" {
+			t.Errorf("Expected the output of git-obs in the configured output, got %q", out.String())
+		}
+	})
+
+	t.Run("Viewer", func(t *testing.T) {
+		// A fake git-obs whose output goes through a real viewer, here $PAGER.
+		dir := t.TempDir()
+		script := "#!/bin/sh\necho \"diff of $*\"\n"
+		if err := os.WriteFile(filepath.Join(dir, "git-obs"), []byte(script), 0755); err != nil {
+			t.Fatalf("Failed to write git-obs: %v", err)
+		}
+		t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		t.Setenv("PAGER", "tr a-z A-Z")
+		installed(t, "tr")
+
+		var out bytes.Buffer
+		cfg := *mockCfg
+		cfg.PRViewer = ViewerPager
+		cfg.OutputWriter = &out
+		if err := NewClient(&command.DefaultRunner{}, &cfg).ShowPullRequest(context.Background(), repository, prID, ShowDiff); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got, want := out.String(), "DIFF OF PR SHOW --PATCH TEST_REPO#123\n"; got != want {
+			t.Errorf("Expected the viewer to print %q, got %q", want, got)
+		}
+	})
+
+	t.Run("ViewerQuitsEarly", func(t *testing.T) {
+		// git-obs is killed by SIGPIPE when the viewer exits before reading all of its output.
+		dir := t.TempDir()
+		script := "#!/bin/sh\nexec yes \"diff of $*\"\n"
+		if err := os.WriteFile(filepath.Join(dir, "git-obs"), []byte(script), 0755); err != nil {
+			t.Fatalf("Failed to write git-obs: %v", err)
+		}
+		t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		t.Setenv("PAGER", "head -n 1")
+		installed(t, "head")
+
+		var out bytes.Buffer
+		cfg := *mockCfg
+		cfg.PRViewer = ViewerPager
+		cfg.OutputWriter = &out
+		if err := NewClient(&command.DefaultRunner{}, &cfg).ShowPullRequest(context.Background(), repository, prID, ShowDiff); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got, want := out.String(), "diff of pr show --patch test_repo#123\n"; got != want {
+			t.Errorf("Expected the viewer to print %q, got %q", want, got)
+		}
+
+		// git-obs is written in Python, which reports EPIPE instead of being killed.
+		installed(t, "delta")
+		mockRunner := &commandtest.MockRunner{
+			RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+				return &command.PipelineError{Stages: []command.StageResult{
+					{Args: p.Stages[0], ExitCode: 120, Err: errors.New("exit status 120"), Stderr: []byte("BrokenPipeError: [Errno 32] Broken pipe\n")},
+					{Args: p.Stages[1]},
+				}}
+			},
+		}
+		if err := NewClient(mockRunner, mockCfg).ShowPullRequest(context.Background(), repository, prID, ShowAll); err != nil {
+			t.Errorf("Expected no error for a broken pipe, got %v", err)
+		}
+	})
+
 	t.Run("Command fails", func(t *testing.T) {
+		installed(t, "delta")
 		mockError := errors.New("git-obs command failed")
 		mockRunner := &commandtest.MockRunner{
-			RunPipelineFunc: func(ctx context.Context, workDir string, cmd1, cmd2 []string) error {
+			RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
 				return mockError
 			},
 		}
 
 		client := NewClient(mockRunner, mockCfg)
-		err := client.ShowPullRequest(context.Background(), repository, prID)
+		err := client.ShowPullRequest(context.Background(), repository, prID, ShowAll)
 
 		if err == nil {
 			t.Fatal("Expected an error, but got nil")
@@ -123,3 +261,161 @@ func TestShowPullRequest(t *testing.T) {
 		}
 	})
 }
+
+func TestResolveViewer(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		installed  []string
+		pager      string
+		wantViewer string
+		wantArgs   []string
+		wantErr    string
+	}{
+		{"AutoDelta", "", []string{"delta", "bat", "less"}, "", ViewerDelta, []string{"delta"}, ""},
+		{"AutoBat", "auto", []string{"bat", "less"}, "", ViewerBat, []string{"bat", "--language", "diff"}, ""},
+		{"AutoPager", "auto", []string{"most", "less"}, "most -s", ViewerPager, []string{"most", "-s"}, ""},
+		{"AutoLess", "auto", []string{"less"}, "", ViewerLess, []string{"less", "-R"}, ""},
+		{"AutoNothingInstalled", "auto", nil, "", ViewerNone, nil, ""},
+		{"Configured", "less", []string{"delta", "less"}, "", ViewerLess, []string{"less", "-R"}, ""},
+		{"ConfiguredMissing", "delta", []string{"less"}, "", ViewerLess, []string{"less", "-R"}, `pr_viewer "delta" is not available`},
+		{"PagerNotSet", "pager", []string{"less"}, "", ViewerLess, []string{"less", "-R"}, "$PAGER is not set"},
+		{"None", "none", []string{"delta"}, "", ViewerNone, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installed(t, tt.installed...)
+			t.Setenv("PAGER", tt.pager)
+
+			viewer, args, err := ResolveViewer(&config.Config{PRViewer: tt.configured})
+			if viewer != tt.wantViewer || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Expected viewer %s %v, got %s %v", tt.wantViewer, tt.wantArgs, viewer, args)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPullRequestListMemoization(t *testing.T) {
+	mockCfg := &config.Config{
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OperationTimeoutSeconds: 5,
+		CacheDir:                t.TempDir(),
+		MetadataCacheTTLSeconds: 300,
+		PRListCacheTTLSeconds:   60,
+	}
+
+	var listCalls int
+	mockRunner := &commandtest.MockRunner{
+		RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+			if args[1] == "list" {
+				listCalls++
+				return []byte("ID          : products/SLES#499\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	client := NewClient(mockRunner, mockCfg)
+
+	for i := 0; i < 2; i++ {
+		prIDs, err := client.GetOpenPullRequests(context.Background(), "reviewer", "main", "products/SLES")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !reflect.DeepEqual(prIDs, []string{"499"}) {
+			t.Errorf("Unexpected pull requests: %v", prIDs)
+		}
+	}
+	if listCalls != 1 {
+		t.Errorf("Expected the PR list to be fetched once, got %d calls", listCalls)
+	}
+
+	// Approving a PR invalidates the memoized lists of the repository.
+	if err := client.ApprovePullRequest(context.Background(), "products/SLES", "499", "reviewer"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := client.GetOpenPullRequests(context.Background(), "reviewer", "main", "products/SLES"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if listCalls != 2 {
+		t.Errorf("Expected the PR list to be fetched again after approval, got %d calls", listCalls)
+	}
+
+	// Without pr_list_cache_ttl_seconds, the review queue is fetched every time.
+	mockCfg.PRListCacheTTLSeconds = 0
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetOpenPullRequests(context.Background(), "reviewer", "main", "products/SLES"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if listCalls != 4 {
+		t.Errorf("Expected the PR list to be fetched on every call without pr_list_cache_ttl_seconds, got %d calls", listCalls)
+	}
+}
+
+func TestGetPullRequest(t *testing.T) {
+	mockCfg := &config.Config{
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OperationTimeoutSeconds: 5,
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockRunner := &commandtest.MockRunner{
+			ExecFunc: func(ctx context.Context, workDir, name string, args ...string) (*command.Result, error) {
+				switch strings.Join(args, " ") {
+				case "api /repos/test_repo/pulls/123":
+					return &command.Result{Stdout: []byte(`{"number": 123, "title": "Update foo", "user": {"login": "alice"},
+						"created_at": "2025-01-02T03:04:05Z", "head": {"ref": "update", "sha": "abc"}}`)}, nil
+				case "api /repos/test_repo/commits/abc/status":
+					return &command.Result{Stdout: []byte(`{"state": "pending"}`)}, nil
+				}
+				return nil, fmt.Errorf("unexpected command: %s %v", name, args)
+			},
+		}
+
+		pr, err := NewClient(mockRunner, mockCfg).GetPullRequest(context.Background(), "test_repo", "123")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if pr.Number != 123 || pr.Title != "Update foo" || pr.User.Login != "alice" || pr.CIState != "pending" || pr.CreatedAt.Day() != 2 {
+			t.Errorf("Unexpected pull request: %+v", pr)
+		}
+	})
+
+	t.Run("Status fails", func(t *testing.T) {
+		mockRunner := &commandtest.MockRunner{
+			ExecFunc: func(ctx context.Context, workDir, name string, args ...string) (*command.Result, error) {
+				if args[1] == "/repos/test_repo/pulls/123" {
+					return &command.Result{Stdout: []byte(`{"title": "Update foo", "head": {"sha": "abc"}}`)}, nil
+				}
+				return &command.Result{ExitCode: 1}, errors.New("no status")
+			},
+		}
+
+		pr, err := NewClient(mockRunner, mockCfg).GetPullRequest(context.Background(), "test_repo", "123")
+		if err != nil {
+			t.Fatalf("Expected the PR without its CI state, got %v", err)
+		}
+		if pr.Title != "Update foo" || pr.CIState != "" {
+			t.Errorf("Unexpected pull request: %+v", pr)
+		}
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		mockRunner := &commandtest.MockRunner{
+			ExecFunc: func(ctx context.Context, workDir, name string, args ...string) (*command.Result, error) {
+				return &command.Result{Stdout: []byte("not json")}, nil
+			},
+		}
+
+		_, err := NewClient(mockRunner, mockCfg).GetPullRequest(context.Background(), "test_repo", "123")
+		if err == nil || !strings.Contains(err.Error(), "invalid response of 'git-obs api /repos/test_repo/pulls/123'") {
+			t.Errorf("Expected an invalid response error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package gitea
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/gyr/relx-go/pkg/config"
+)
+
+// Viewers of pull requests, selected with the pr_viewer setting.
+const (
+	ViewerAuto  = "auto"
+	ViewerDelta = "delta"
+	ViewerBat   = "bat"
+	ViewerLess  = "less"
+	ViewerPager = "pager" // The command in $PAGER
+	ViewerNone  = "none"  // Print the output of git-obs as it is
+)
+
+// autoViewers are tried in this order for pr_viewer auto, and when the configured viewer
+// is not available.
+var autoViewers = []string{ViewerDelta, ViewerBat, ViewerPager, ViewerLess}
+
+// lookPath finds executables on PATH; tests replace it.
+var lookPath = exec.LookPath
+
+// Parts of a pull request shown by ShowPullRequest. git-obs always shows the title and
+// the description first.
+const (
+	ShowAll         = "all" // The description, the timeline and the diff
+	ShowDescription = "description"
+	ShowTimeline    = "timeline" // The description and the timeline
+	ShowDiff        = "diff"     // The description and the diff
+)
+
+// ShowParts are the valid parts for ShowPullRequest.
+var ShowParts = []string{ShowAll, ShowDescription, ShowTimeline, ShowDiff}
+
+// ResolveViewer returns the viewer used to show pull requests and its command line, which
+// is nil for ViewerNone. For pr_viewer auto (or unset), the first available viewer of delta,
+// bat, $PAGER and less is used, or none if none of them is. A configured viewer that is not
+// available falls back the same way, and the error says why it is not used.
+func ResolveViewer(cfg *config.Config) (viewer string, args []string, err error) {
+	configured := cfg.PRViewer
+	if configured == "" {
+		configured = ViewerAuto
+	}
+	if configured == ViewerNone {
+		return ViewerNone, nil, nil
+	}
+
+	if configured != ViewerAuto {
+		args, err := viewerArgs(configured)
+		if err == nil {
+			return configured, args, nil
+		}
+		viewer, args := autoViewer()
+		return viewer, args, fmt.Errorf("gitea: pr_viewer %q is not available: %w", configured, err)
+	}
+	viewer, args = autoViewer()
+	return viewer, args, nil
+}
+
+// autoViewer returns the first available viewer of autoViewers, or ViewerNone.
+func autoViewer() (string, []string) {
+	for _, viewer := range autoViewers {
+		if args, err := viewerArgs(viewer); err == nil {
+			return viewer, args
+		}
+	}
+	return ViewerNone, nil
+}
+
+// viewerArgs returns the command line of viewer, or an error if it is not installed.
+func viewerArgs(viewer string) ([]string, error) {
+	var args []string
+	switch viewer {
+	case ViewerDelta:
+		args = []string{"delta"}
+	case ViewerBat:
+		args = []string{"bat", "--language", "diff"}
+	case ViewerLess:
+		args = []string{"less", "-R"} // Keep the colors of git-obs
+	case ViewerPager:
+		args = strings.Fields(os.Getenv("PAGER"))
+		if len(args) == 0 {
+			return nil, fmt.Errorf("$PAGER is not set")
+		}
+	default:
+		return nil, fmt.Errorf("unknown viewer %q", viewer)
+	}
+	if _, err := lookPath(args[0]); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// showArgs returns the arguments of `git-obs pr show` for part. git-obs always starts
+// with the summary and description of the pull request; the timeline and the patch follow.
+func showArgs(part string) ([]string, error) {
+	args := []string{"pr", "show"}
+	switch part {
+	case ShowAll, "":
+		args = append(args, "--timeline", "--patch")
+	case ShowDescription:
+	case ShowTimeline:
+		args = append(args, "--timeline")
+	case ShowDiff:
+		args = append(args, "--patch")
+	default:
+		return nil, fmt.Errorf("gitea: unknown part %q (valid: %s)", part, strings.Join(ShowParts, ", "))
+	}
+	return args, nil
+}
@@ -1,41 +1,156 @@
 package gitutils
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"time"
+	"os"
+	"strings"
 
+	"github.com/gyr/relx-go/pkg/cache"
 	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/timeout"
 )
 
 // FetchRemoteFile uses 'git archive' to fetch a single file from a remote repository
 // without cloning the entire repository. This is much more efficient than a full clone.
+// The file is read from the branch configured in `RepoBranch`.
 func FetchRemoteFile(ctx context.Context, cfg *config.Config, runner command.Runner, filePath string) ([]byte, error) {
-	// Create a context with the configured timeout.
-	timeout := time.Duration(cfg.OperationTimeoutSeconds) * time.Second
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	return FetchRemoteFileAt(ctx, cfg, runner, cfg.RepoBranch, filePath)
+}
+
+// FetchRemoteFileAt is like FetchRemoteFile, but reads the file from the given ref
+// (a branch, tag or commit) of the configured repository instead of `RepoBranch`.
+// Note that fetching arbitrary commits requires the remote to allow it
+// (see `uploadArchive.allowUnreachable` in git-config(1)).
+func FetchRemoteFileAt(ctx context.Context, cfg *config.Config, runner command.Runner, ref, filePath string) ([]byte, error) {
+	timeoutCtx, op := timeout.Start(ctx, cfg, timeout.GitArchive)
+	defer op.Stop()
 
-	// Construct the command. Note that we are using `bash -c` to handle the pipe.
 	// The `git archive` command creates a tarball of the requested file, which we then
 	// pipe to `tar -xO` to extract the raw file content to standard output.
-	archiveCmd := fmt.Sprintf(
-		"git archive --remote=%s %s %s | tar -xO",
-		cfg.RepoURL,
-		cfg.RepoBranch,
-		filePath,
-	)
+	pipeline := command.Pipeline{
+		Stages: [][]string{
+			{"git", "archive", "--remote=" + cfg.RepoURL, ref, filePath},
+			{"tar", "-xO"},
+		},
+	}
 
-	cfg.Logger.Infof("Fetching remote file: %s from %s (branch: %s)", filePath, cfg.RepoURL, cfg.RepoBranch)
+	cfg.Logger.Infof("Fetching remote file: %s from %s (ref: %s)", filePath, cfg.RepoURL, ref)
 
-	// Execute the command using the injected runner.
-	output, err := runner.Run(timeoutCtx, "" /* workDir */, "bash", "-c", archiveCmd)
+	// Execute the command using the injected runner. Results are memoized in the cache,
+	// since the same files are usually read over and over again. Only the files of a
+	// commit never change, so other refs are resolved to their commit first.
+	ttl := cfg.MetadataCacheTTL()
+	var cacheKey string
+	if cfg.CacheDir != "" && ttl > 0 {
+		cacheKey = archiveCacheKey(ctx, cfg, runner, ref, filePath)
+	}
+	if cacheKey == "" {
+		ttl = 0 // The file is fetched without memoization.
+	}
+	output, err := cache.Remember(cfg.CacheDir, cacheKey, ttl, func() ([]byte, error) {
+		var stdout bytes.Buffer
+		pipeline.Stdout = &stdout
+		if err := runner.RunPipeline(timeoutCtx, pipeline); err != nil {
+			return nil, err
+		}
+		return stdout.Bytes(), nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch remote file '%s' with command '%s': %w. Output: %s", filePath, archiveCmd, err, string(output))
+		return nil, fmt.Errorf("failed to fetch remote file '%s': %w", filePath, op.Err(err))
 	}
 
 	cfg.Logger.Debugf("Successfully fetched remote file '%s'.", filePath)
 
 	return output, nil
 }
+
+// archiveCacheKey returns the key under which the file at filePath of ref is memoized, or
+// "" if ref cannot be resolved to a commit. A full commit ID is used as it is. Other refs
+// are resolved with `git ls-remote`, and the key names both the ref and its commit, since
+// `git archive` still reads the ref, which may have moved in the meantime.
+func archiveCacheKey(ctx context.Context, cfg *config.Config, runner command.Runner, ref, filePath string) string {
+	if isCommitID(ref) {
+		return fmt.Sprintf("git-archive/%s@%s:%s", cfg.RepoURL, ref, filePath)
+	}
+	commit, err := resolveRemoteRef(ctx, cfg, runner, cfg.RepoURL, ref)
+	if err != nil {
+		cfg.Logger.Debugf("Not memoizing '%s' of %s: %v", filePath, ref, err)
+		return ""
+	}
+	return fmt.Sprintf("git-archive/%s@%s=%s:%s", cfg.RepoURL, ref, commit, filePath)
+}
+
+// isCommitID reports whether ref is a full SHA-1 or SHA-256 commit ID.
+func isCommitID(ref string) bool {
+	if len(ref) != 40 && len(ref) != 64 {
+		return false
+	}
+	for _, r := range ref {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveRemoteRef returns the object that ref (a branch, tag or full ref name) points to
+// in the remote repository at repoURL, trying the names in the order git resolves them.
+func resolveRemoteRef(ctx context.Context, cfg *config.Config, runner command.Runner, repoURL, ref string) (string, error) {
+	timeoutCtx, op := timeout.Start(ctx, cfg, timeout.GitLsRemote)
+	defer op.Stop()
+
+	result, err := runner.Exec(timeoutCtx, "" /* workDir */, "git", "ls-remote", repoURL, ref)
+	if err != nil {
+		return "", fmt.Errorf("gitutils: git ls-remote failed for %s: %w", repoURL, op.Err(err))
+	}
+
+	refs := make(map[string]string)
+	for _, line := range strings.Split(string(result.Stdout), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	for _, name := range []string{ref, "refs/" + ref, "refs/tags/" + ref, "refs/heads/" + ref} {
+		if object, found := refs[name]; found {
+			return object, nil
+		}
+	}
+	return "", fmt.Errorf("gitutils: ref %s not found in %s", ref, repoURL)
+}
+
+// ExportRemotePaths uses 'git archive' to extract one or more files or directories
+// from the given ref of the configured repository into destDir.
+// Paths keep their location relative to the repository root, so exporting
+// "products/SLES" creates "<destDir>/products/SLES".
+func ExportRemotePaths(ctx context.Context, cfg *config.Config, runner command.Runner, ref, destDir string, paths ...string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("gitutils: at least one path must be given to export")
+	}
+	if destDir == "" {
+		destDir = "."
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("gitutils: failed to create export directory %s: %w", destDir, err)
+	}
+
+	timeoutCtx, op := timeout.Start(ctx, cfg, timeout.GitArchive)
+	defer op.Stop()
+
+	archiveCmd := append([]string{"git", "archive", "--remote=" + cfg.RepoURL, ref}, paths...)
+	pipeline := command.Pipeline{
+		Stages: [][]string{archiveCmd, {"tar", "-x", "-C", destDir}},
+	}
+
+	cfg.Logger.Infof("Exporting %v from %s (ref: %s) to %s", paths, cfg.RepoURL, ref, destDir)
+
+	if err := runner.RunPipeline(timeoutCtx, pipeline); err != nil {
+		return fmt.Errorf("failed to export %v: %w", paths, op.Err(err))
+	}
+
+	cfg.Logger.Debugf("Successfully exported %v to %s.", paths, destDir)
+
+	return nil
+}
@@ -3,9 +3,12 @@ package gitutils
 import (
 	"context"
 	"errors"
+	"io"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/command/commandtest"
 	"github.com/gyr/relx-go/pkg/config"
 	"github.com/gyr/relx-go/pkg/logging"
@@ -23,13 +26,14 @@ func TestFetchRemoteFile(t *testing.T) {
 	t.Run("SuccessfulFetch", func(t *testing.T) {
 		expectedContent := `{"pkg1": ["userA"]}`
 		mockRunner := &commandtest.MockRunner{}
-		mockRunner.RunFunc = func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+		mockRunner.RunPipelineFunc = func(ctx context.Context, p command.Pipeline) error {
 			// Check that the correct command is being run
-			expectedCmdPart := "git archive --remote=https://example.com/test.git main _maintainership.json"
-			if name != "bash" || !strings.Contains(args[1], expectedCmdPart) {
-				t.Errorf("Expected command containing %q, got %s %s", expectedCmdPart, name, args[1])
+			expectedArchive := []string{"git", "archive", "--remote=https://example.com/test.git", "main", "_maintainership.json"}
+			if !reflect.DeepEqual(p.Stages[0], expectedArchive) {
+				t.Errorf("Expected command %v, got %v", expectedArchive, p.Stages[0])
 			}
-			return []byte(expectedContent), nil
+			_, err := io.WriteString(p.Stdout, expectedContent)
+			return err
 		}
 
 		content, err := FetchRemoteFile(context.Background(), mockCfg, mockRunner, filePath)
@@ -45,8 +49,8 @@ func TestFetchRemoteFile(t *testing.T) {
 	t.Run("FailedFetch", func(t *testing.T) {
 		mockError := errors.New("git command failed")
 		mockRunner := &commandtest.MockRunner{}
-		mockRunner.RunFunc = func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
-			return nil, mockError
+		mockRunner.RunPipelineFunc = func(ctx context.Context, p command.Pipeline) error {
+			return mockError
 		}
 
 		_, err := FetchRemoteFile(context.Background(), mockCfg, mockRunner, filePath)
@@ -65,13 +69,10 @@ func TestFetchRemoteFile(t *testing.T) {
 		cancel()
 
 		mockRunner := &commandtest.MockRunner{}
-		mockRunner.RunFunc = func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+		mockRunner.RunPipelineFunc = func(ctx context.Context, p command.Pipeline) error {
 			// The real runner would fail because the context is cancelled.
 			// We can simulate this behavior.
-			if ctx.Err() != nil {
-				return nil, ctx.Err()
-			}
-			return nil, nil
+			return ctx.Err()
 		}
 
 		_, err := FetchRemoteFile(ctx, mockCfg, mockRunner, filePath)
@@ -84,3 +85,109 @@ func TestFetchRemoteFile(t *testing.T) {
 		}
 	})
 }
+
+func TestFetchRemoteFileArguments(t *testing.T) {
+	// Without a shell in between, arguments reach git as they are, however unusual.
+	mockCfg := &config.Config{
+		RepoURL:                 "git@example.com:user/repo.git",
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OperationTimeoutSeconds: 5,
+	}
+	const filePath = "dir with space/it's $(reboot).json"
+
+	var got [][]string
+	mockRunner := &commandtest.MockRunner{
+		RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+			got = p.Stages
+			return nil
+		},
+	}
+	if _, err := FetchRemoteFileAt(context.Background(), mockCfg, mockRunner, "v1.0", filePath); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	want := [][]string{
+		{"git", "archive", "--remote=git@example.com:user/repo.git", "v1.0", filePath},
+		{"tar", "-xO"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected pipeline %v, got %v", want, got)
+	}
+}
+
+func TestFetchRemoteFileMemoization(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	mockCfg := &config.Config{
+		RepoURL:                 "https://example.com/test.git",
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OperationTimeoutSeconds: 5,
+		MetadataCacheTTLSeconds: 300,
+	}
+	// newRunner returns a runner whose branch main points to *head, and which counts
+	// the archives it creates.
+	newRunner := func(head *string, archives *int) *commandtest.MockRunner {
+		return &commandtest.MockRunner{
+			ExecFunc: func(ctx context.Context, workDir, name string, args ...string) (*command.Result, error) {
+				if *head == "" {
+					return &command.Result{ExitCode: 128}, errors.New("ls-remote failed")
+				}
+				return &command.Result{Stdout: []byte(*head + "\trefs/heads/main\n")}, nil
+			},
+			RunPipelineFunc: func(ctx context.Context, p command.Pipeline) error {
+				*archives++
+				_, err := io.WriteString(p.Stdout, "content")
+				return err
+			},
+		}
+	}
+	fetch := func(t *testing.T, cfg *config.Config, runner command.Runner, ref string) {
+		t.Helper()
+		if content, err := FetchRemoteFileAt(context.Background(), cfg, runner, ref, "file"); err != nil || string(content) != "content" {
+			t.Fatalf("FetchRemoteFileAt() = %q, %v", content, err)
+		}
+	}
+
+	t.Run("BranchIsResolved", func(t *testing.T) {
+		cfg := *mockCfg
+		cfg.CacheDir = t.TempDir()
+		head, archives := commit, 0
+		runner := newRunner(&head, &archives)
+
+		fetch(t, &cfg, runner, "main")
+		fetch(t, &cfg, runner, "main")
+		if archives != 1 {
+			t.Errorf("Expected the file of an unchanged branch to be memoized, got %d archives", archives)
+		}
+		head = strings.Repeat("f", 40)
+		fetch(t, &cfg, runner, "main")
+		if archives != 2 {
+			t.Errorf("Expected the file to be fetched again after the branch moved, got %d archives", archives)
+		}
+	})
+
+	t.Run("CommitIsNotResolved", func(t *testing.T) {
+		cfg := *mockCfg
+		cfg.CacheDir = t.TempDir()
+		head, archives := "", 0
+		runner := newRunner(&head, &archives)
+
+		fetch(t, &cfg, runner, commit)
+		fetch(t, &cfg, runner, commit)
+		if archives != 1 {
+			t.Errorf("Expected the file of a commit to be memoized, got %d archives", archives)
+		}
+	})
+
+	t.Run("UnresolvedRefIsNotMemoized", func(t *testing.T) {
+		cfg := *mockCfg
+		cfg.CacheDir = t.TempDir()
+		head, archives := "", 0
+		runner := newRunner(&head, &archives)
+
+		fetch(t, &cfg, runner, "main")
+		fetch(t, &cfg, runner, "main")
+		if archives != 2 {
+			t.Errorf("Expected the file to be fetched every time, got %d archives", archives)
+		}
+	})
+}
@@ -2,17 +2,80 @@ package gitutils
 
 import (
 	"context" // Import context for timeout management
+	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time" // Import time for duration
 
 	"github.com/gyr/relx-go/pkg/cache"
 	"github.com/gyr/relx-go/pkg/command" // Import the new command package
 	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/timeout"
 )
 
+// Update strategies for existing clones in the cache, selected with `repo_update_strategy`
+// or the per-repository `update_strategy` setting.
+const (
+	// UpdateStrategyFail refuses to update a clone with uncommitted changes or diverged history.
+	UpdateStrategyFail = "fail"
+	// UpdateStrategyStash stashes uncommitted changes and saves diverged local commits
+	// on a backup branch before resetting to the remote branch.
+	UpdateStrategyStash = "stash"
+	// UpdateStrategyReset discards uncommitted changes and local commits by resetting to the remote branch.
+	UpdateStrategyReset = "reset"
+	// UpdateStrategyReclone removes the clone and clones the repository again.
+	UpdateStrategyReclone = "reclone"
+)
+
+var (
+	// ErrDirtyWorkTree is returned when a clone has uncommitted changes and the strategy is UpdateStrategyFail.
+	ErrDirtyWorkTree = errors.New("uncommitted changes in work tree")
+	// ErrDivergedHistory is returned when a clone has commits that are not on the remote branch
+	// and the strategy is UpdateStrategyFail.
+	ErrDivergedHistory = errors.New("local history diverged from remote")
+)
+
+// updateStrategy returns the update strategy for a repository, falling back to the global setting.
+func updateStrategy(cfg *config.Config, repo config.Repository) (string, error) {
+	strategy := repo.UpdateStrategy
+	if strategy == "" {
+		strategy = cfg.RepoUpdateStrategy
+	}
+	switch strategy {
+	case "":
+		return UpdateStrategyFail, nil
+	case UpdateStrategyFail, UpdateStrategyStash, UpdateStrategyReset, UpdateStrategyReclone:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("gitutils: unknown update strategy %q (valid: %s, %s, %s, %s)", strategy, UpdateStrategyFail, UpdateStrategyStash, UpdateStrategyReset, UpdateStrategyReclone)
+}
+
+// cloneOptions holds the settings that reduce the amount of data fetched for a repository.
+type cloneOptions struct {
+	depth       int
+	filter      string
+	sparsePaths []string
+}
+
+// resolveCloneOptions returns the clone options for a repository, falling back to the global settings.
+func resolveCloneOptions(cfg *config.Config, repo config.Repository) cloneOptions {
+	opts := cloneOptions{depth: repo.Depth, filter: repo.Filter, sparsePaths: repo.SparsePaths}
+	if opts.depth == 0 {
+		opts.depth = cfg.RepoCloneDepth
+	}
+	if opts.filter == "" {
+		opts.filter = cfg.RepoCloneFilter
+	}
+	if len(opts.sparsePaths) == 0 {
+		opts.sparsePaths = cfg.RepoSparsePaths
+	}
+	return opts
+}
+
 // deriveRepoName extracts the repository name from a URL.
 func deriveRepoName(repoURL string) (string, error) {
 	var path string
@@ -45,17 +108,58 @@ func deriveRepoName(repoURL string) (string, error) {
 	return repoName, nil
 }
 
-// CloneRepo clones a Git repository into a specified cache directory.
-// It skips submodules and returns the local path to the cloned repository.
-// ManageRepo manages a Git repository by cloning or updating it.
-//
-// It accepts a parent context to enable cancellation of the entire operation from the caller.
-// For the git commands it executes, it creates a new derived context with a timeout
-// based on the `OperationTimeoutSeconds` in the configuration. This ensures that the git
-// operations don't hang indefinitely, while still respecting cancellation from the parent context.
-//
-// This function relies on a command.Runner for executing external commands, which allows
-// for mocking during tests.
+// ResolveRepositories returns the list of repositories to keep in the cache.
+// It contains every entry of `repositories` plus the main `repo_url`/`repo_branch`
+// repository, unless that one is already listed with the same URL and branch. Missing
+// names are derived from the URL.
+func ResolveRepositories(cfg *config.Config) ([]config.Repository, error) {
+	var repos []config.Repository
+	seen := make(map[string]config.Repository)
+
+	candidates := append([]config.Repository{}, cfg.Repositories...)
+	mainIndex := -1
+	if cfg.RepoURL != "" {
+		mainIndex = len(candidates)
+		candidates = append(candidates, config.Repository{URL: cfg.RepoURL, Branch: cfg.RepoBranch})
+	}
+
+	for i, repo := range candidates {
+		if repo.Name == "" {
+			name, err := deriveRepoName(repo.URL)
+			if err != nil {
+				return nil, err
+			}
+			repo.Name = name
+		}
+		if listed, exists := seen[repo.Name]; exists {
+			if i == mainIndex && listed.URL == repo.URL && listed.Branch == repo.Branch {
+				continue // The main repository is already part of the list.
+			}
+			return nil, fmt.Errorf("gitutils: repository name %q is configured more than once", repo.Name)
+		}
+		seen[repo.Name] = repo
+		repos = append(repos, repo)
+	}
+
+	return repos, nil
+}
+
+// FindRepository returns the configured repository with the given name.
+func FindRepository(cfg *config.Config, name string) (config.Repository, error) {
+	repos, err := ResolveRepositories(cfg)
+	if err != nil {
+		return config.Repository{}, err
+	}
+	for _, repo := range repos {
+		if repo.Name == name {
+			return repo, nil
+		}
+	}
+	return config.Repository{}, fmt.Errorf("gitutils: repository %q is not configured", name)
+}
+
+// ManageRepo manages the main Git repository (`RepoURL`/`RepoBranch`) by cloning or updating it.
+// It returns the local path to the repository in the cache. See SyncRepo for details.
 func ManageRepo(ctx context.Context, cfg *config.Config, runner command.Runner) (string, error) {
 	if cfg.RepoURL == "" {
 		return "", fmt.Errorf("gitutils: repository URL (RepoURL) cannot be empty in the configuration")
@@ -64,62 +168,339 @@ func ManageRepo(ctx context.Context, cfg *config.Config, runner command.Runner)
 		return "", fmt.Errorf("gitutils: repository branch (RepoBranch) cannot be empty in the configuration")
 	}
 
-	// Create a context with a timeout for the git operations.
-	// This context is derived from the parent `ctx`, so if the parent context
-	// is cancelled, this derived context will be cancelled as well.
-	timeout := time.Duration(cfg.OperationTimeoutSeconds) * time.Second
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel() // This is crucial to release resources associated with the context.
+	return SyncRepo(ctx, cfg, runner, config.Repository{URL: cfg.RepoURL, Branch: cfg.RepoBranch})
+}
 
-	cache, err := cache.New(cfg.CacheDir)
+// SyncRepo manages a Git repository by cloning or updating it.
+// It skips submodules and returns the local path to the cloned repository.
+//
+// Before updating an existing clone, it checks for uncommitted changes and, after fetching,
+// for local commits that are not on the remote branch (e.g. after a force-push).
+// How these situations are handled depends on the repository's update strategy
+// (see UpdateStrategyFail and friends).
+//
+// It accepts a parent context to enable cancellation of the entire operation from the caller.
+// Updating an existing clone and cloning run with derived contexts bounded by the git_fetch
+// and git_clone timeouts of the configuration. This ensures that the git operations don't
+// hang indefinitely, while still respecting cancellation from the parent context.
+//
+// This function relies on a command.Runner for executing external commands, which allows
+// for mocking during tests.
+func SyncRepo(ctx context.Context, cfg *config.Config, runner command.Runner, repo config.Repository) (string, error) {
+	if repo.URL == "" {
+		return "", fmt.Errorf("gitutils: repository URL cannot be empty")
+	}
+	if repo.Branch == "" {
+		return "", fmt.Errorf("gitutils: repository branch cannot be empty for %s", repo.URL)
+	}
+
+	strategy, err := updateStrategy(cfg, repo)
 	if err != nil {
 		return "", err
 	}
+	opts := resolveCloneOptions(cfg, repo)
+	if opts.depth < 0 {
+		return "", fmt.Errorf("gitutils: clone depth cannot be negative for %s", repo.URL)
+	}
 
-	repoName, err := deriveRepoName(cfg.RepoURL)
+	cache, err := cache.New(cfg.CacheDir)
 	if err != nil {
 		return "", err
 	}
+
+	repoName := repo.Name
+	if repoName == "" {
+		repoName, err = deriveRepoName(repo.URL)
+		if err != nil {
+			return "", err
+		}
+	}
 	localPath := cache.GetPath(repoName)
 
-	var output []byte
-	var cmdErr error // Use a distinct error variable for git commands
+	// Hold the entry's lock while running git in it, so that concurrent relx-go
+	// processes sharing the cache don't operate on the same clone.
+	lock, err := cache.Lock(ctx, repoName, lockOptions(cfg, repoName))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = lock.Unlock() }()
 
 	// Check if the repository already exists
 	if cache.Has(repoName, ".git") {
 		// Repository exists, update it
-		cfg.Logger.Infof("Repository %s already exists at %s. Updating...", cfg.RepoURL, localPath)
+		cfg.Logger.Infof("Repository %s already exists at %s. Updating (strategy: %s)...", repo.URL, localPath, strategy)
+
+		updateCtx, update := timeout.Start(ctx, cfg, timeout.GitFetch)
+		reclone, err := updateRepo(updateCtx, cfg, runner, repo, localPath, strategy, opts)
+		err = update.Err(err)
+		update.Stop()
+		if err != nil {
+			return "", err
+		}
+		if !reclone {
+			touchRepo(cfg, cache, repoName)
+			return localPath, nil
+		}
+
+		cfg.Logger.Infof("Removing %s to clone %s again.", localPath, repo.URL)
+		if err := os.RemoveAll(localPath); err != nil {
+			return "", fmt.Errorf("gitutils: failed to remove %s before cloning again: %w", localPath, err)
+		}
+	}
+
+	// Repository does not exist (anymore), clone it
+	cloneCtx, clone := timeout.Start(ctx, cfg, timeout.GitClone)
+	err = clone.Err(cloneRepo(cloneCtx, cfg, runner, repo, localPath, opts))
+	clone.Stop()
+	if err != nil {
+		return "", err
+	}
+
+	touchRepo(cfg, cache, repoName)
+	return localPath, nil
+}
+
+// lockOptions returns the options for locking a cache entry, based on `cache_lock_timeout_seconds`.
+// While waiting, a warning names the process holding the lock.
+func lockOptions(cfg *config.Config, artifactName string) cache.LockOptions {
+	seconds := cfg.CacheLockTimeoutSeconds
+	if seconds <= 0 {
+		seconds = cfg.OperationTimeoutSeconds
+	}
+	return cache.LockOptions{
+		Timeout: time.Duration(seconds) * time.Second,
+		OnWait: func(holderPID int) {
+			cfg.Logger.Warnf("Waiting for lock on %s held by PID %d...", artifactName, holderPID)
+		},
+	}
+}
+
+// touchRepo records the use of a repository in the cache for LRU eviction.
+// Failing to record it is not fatal for the git operation itself.
+func touchRepo(cfg *config.Config, c *cache.Cache, repoName string) {
+	if err := c.Touch(repoName); err != nil {
+		cfg.Logger.Warnf("Failed to record access to %s: %v", repoName, err)
+	}
+}
+
+// cloneRepo clones the repository into localPath, skipping submodules.
+// Depending on the clone options, the clone is shallow, partial and/or sparse.
+func cloneRepo(ctx context.Context, cfg *config.Config, runner command.Runner, repo config.Repository, localPath string, opts cloneOptions) error {
+	cfg.Logger.Infof("Cloning repository %s (branch %s) to %s...", repo.URL, repo.Branch, localPath)
+
+	args := []string{"clone", "--branch", repo.Branch, "--recurse-submodules=no"}
+	if opts.depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(opts.depth))
+	}
+	if opts.filter != "" {
+		args = append(args, "--filter="+opts.filter)
+	}
+	if len(opts.sparsePaths) > 0 {
+		args = append(args, "--sparse")
+	}
+	args = append(args, repo.URL, localPath)
+
+	result, err := runner.Exec(ctx, "", "git", args...)
+	if err != nil {
+		return fmt.Errorf("gitutils: git clone failed for %s: %w", repo.URL, err)
+	}
+	cfg.Logger.Debugf("Git clone output:\n%s%s", result.Stdout, result.Stderr)
+
+	return applySparseCheckout(ctx, cfg, runner, localPath, opts)
+}
 
-		// Ensure we are on the correct branch before pulling.
-		// We use the new runner, passing the timeout-enabled context.
-		output, cmdErr = runner.Run(timeoutCtx, localPath, "git", "switch", cfg.RepoBranch)
-		if cmdErr != nil {
-			return "", fmt.Errorf("gitutils: git switch to branch %s failed for %s. Output:\n%s\nError: %w", cfg.RepoBranch, cfg.RepoURL, string(output), cmdErr)
+// applySparseCheckout restricts the work tree to the configured sparse paths.
+// Paths are gitignore-style patterns (non-cone mode), so single files can be selected.
+// It does nothing if no sparse paths are configured.
+func applySparseCheckout(ctx context.Context, cfg *config.Config, runner command.Runner, localPath string, opts cloneOptions) error {
+	if len(opts.sparsePaths) == 0 {
+		return nil
+	}
+	args := append([]string{"sparse-checkout", "set", "--no-cone"}, opts.sparsePaths...)
+	return runGit(ctx, cfg, runner, localPath, args...)
+}
+
+// updateRepo brings an existing clone up to date with the remote branch, applying the
+// given strategy to dirty work trees and diverged history.
+// It returns true if the clone has to be removed and cloned again.
+func updateRepo(ctx context.Context, cfg *config.Config, runner command.Runner, repo config.Repository, localPath, strategy string, opts cloneOptions) (bool, error) {
+	remoteBranch := "origin/" + repo.Branch
+
+	// Check for uncommitted changes before touching the work tree.
+	result, err := runner.Exec(ctx, localPath, "git", "status", "--porcelain")
+	if err != nil {
+		return false, fmt.Errorf("gitutils: git status failed for %s: %w", repo.URL, err)
+	}
+	if strings.TrimSpace(string(result.Stdout)) != "" {
+		cfg.Logger.Warnf("Repository %s at %s has uncommitted changes.", repo.URL, localPath)
+		cfg.Logger.Debugf("Git status output:\n%s", result.Stdout)
+
+		switch strategy {
+		case UpdateStrategyFail:
+			return false, fmt.Errorf("gitutils: %w in %s; commit or discard them, or use another update strategy", ErrDirtyWorkTree, localPath)
+		case UpdateStrategyReclone:
+			return true, nil
+		case UpdateStrategyStash:
+			message := fmt.Sprintf("relx-go autostash %s", time.Now().Format(time.RFC3339))
+			if err := runGit(ctx, cfg, runner, localPath, "stash", "push", "--include-untracked", "--message", message); err != nil {
+				return false, err
+			}
+			cfg.Logger.Infof("Stashed uncommitted changes in %s as %q.", localPath, message)
+		case UpdateStrategyReset:
+			if err := runGit(ctx, cfg, runner, localPath, "reset", "--hard"); err != nil {
+				return false, err
+			}
+			if err := runGit(ctx, cfg, runner, localPath, "clean", "-ffdx"); err != nil {
+				return false, err
+			}
 		}
-		cfg.Logger.Debugf("Git switch output:\n%s", string(output))
+	}
+
+	// Ensure we are on the correct branch before pulling.
+	if err := runGit(ctx, cfg, runner, localPath, "switch", repo.Branch); err != nil {
+		return false, err
+	}
+
+	// A shallow fetch grafts the new remote tip, whose history no longer contains the old
+	// HEAD, so local commits of shallow clones are looked for before fetching, against the
+	// remote branch they were last updated to.
+	if opts.depth > 0 {
+		ahead, err := localCommits(ctx, cfg, runner, repo, localPath, remoteBranch)
+		if err != nil {
+			return false, err
+		}
+		if ahead > 0 {
+			if reclone, err := keepLocalCommits(ctx, cfg, runner, repo, localPath, remoteBranch, strategy, ahead); reclone || err != nil {
+				return reclone, err
+			}
+		}
+	}
+
+	// Fetch changes from the remote. Shallow clones stay shallow; the partial clone
+	// filter is remembered by git itself.
+	fetchArgs := []string{"fetch", "--prune", "--all"}
+	if opts.depth > 0 {
+		fetchArgs = append(fetchArgs, "--depth", strconv.Itoa(opts.depth))
+	}
+	if err := runGit(ctx, cfg, runner, localPath, fetchArgs...); err != nil {
+		return false, err
+	}
 
-		// Fetch changes from the remote.
-		output, cmdErr = runner.Run(timeoutCtx, localPath, "git", "fetch", "--prune", "--all")
-		if cmdErr != nil {
-			return "", fmt.Errorf("gitutils: git fetch failed for %s. Output:\n%s\nError: %w", cfg.RepoURL, string(output), cmdErr)
+	// Shallow clones are then moved to the new tip, since pulling needs their history.
+	if opts.depth > 0 {
+		cfg.Logger.Debugf("Resetting shallow clone %s to %s.", localPath, remoteBranch)
+		if err := runGit(ctx, cfg, runner, localPath, "reset", "--hard", remoteBranch); err != nil {
+			return false, err
 		}
-		cfg.Logger.Debugf("Git fetch output:\n%s", string(output))
+		return false, applySparseCheckout(ctx, cfg, runner, localPath, opts)
+	}
 
-		// Pull with rebase to keep a clean history.
-		output, cmdErr = runner.Run(timeoutCtx, localPath, "git", "pull", "--rebase")
-		if cmdErr != nil {
-			return "", fmt.Errorf("gitutils: git pull --rebase failed for %s. Output:\n%s\nError: %w", cfg.RepoURL, string(output), cmdErr)
+	// Check whether the local branch has commits that are not on the remote branch.
+	// This happens with local commits and when the remote history was rewritten.
+	ahead, err := localCommits(ctx, cfg, runner, repo, localPath, remoteBranch)
+	if err != nil {
+		return false, err
+	}
+	if ahead > 0 {
+		if reclone, err := keepLocalCommits(ctx, cfg, runner, repo, localPath, remoteBranch, strategy, ahead); reclone || err != nil {
+			return reclone, err
+		}
+		if err := runGit(ctx, cfg, runner, localPath, "reset", "--hard", remoteBranch); err != nil {
+			return false, err
 		}
-		cfg.Logger.Debugf("Git pull --rebase output:\n%s", string(output))
+		return false, applySparseCheckout(ctx, cfg, runner, localPath, opts)
+	}
+
+	// Pull with rebase to keep a clean history.
+	if err := runGit(ctx, cfg, runner, localPath, "pull", "--rebase"); err != nil {
+		return false, err
+	}
+
+	// Re-apply the sparse paths so that configuration changes take effect on existing clones.
+	return false, applySparseCheckout(ctx, cfg, runner, localPath, opts)
+}
+
+// runGit runs a git command in dir and logs its output at debug level.
+func runGit(ctx context.Context, cfg *config.Config, runner command.Runner, dir string, args ...string) error {
+	result, err := runner.Exec(ctx, dir, "git", args...)
+	if err != nil {
+		return fmt.Errorf("gitutils: git %s failed in %s: %w", args[0], dir, err)
+	}
+	cfg.Logger.Debugf("Git %s output:\n%s%s", args[0], result.Stdout, result.Stderr)
+	return nil
+}
 
-	} else { // Repository does not exist, clone it
-		cfg.Logger.Infof("Cloning repository %s (branch %s) to %s...", cfg.RepoURL, cfg.RepoBranch, localPath)
-		output, cmdErr = runner.Run(timeoutCtx, "", "git", "clone", "--branch", cfg.RepoBranch, "--recurse-submodules=no", cfg.RepoURL, localPath)
-		if cmdErr != nil {
-			return "", fmt.Errorf("gitutils: git clone failed for %s. Output:\n%s\nError: %w", cfg.RepoURL, string(output), cmdErr)
+// localCommits returns the number of commits of HEAD in localPath that are not on
+// remoteBranch.
+func localCommits(ctx context.Context, cfg *config.Config, runner command.Runner, repo config.Repository, localPath, remoteBranch string) (int, error) {
+	diverged, err := hasLocalCommits(ctx, runner, localPath, remoteBranch)
+	if err != nil {
+		return 0, fmt.Errorf("gitutils: failed to compare %s with %s in %s: %w", repo.Branch, remoteBranch, localPath, err)
+	}
+	if !diverged {
+		return 0, nil
+	}
+	result, err := runner.Exec(ctx, localPath, "git", "rev-list", "--left-right", "--count", "HEAD..."+remoteBranch)
+	if err != nil {
+		return 0, fmt.Errorf("gitutils: git rev-list failed for %s: %w", repo.URL, err)
+	}
+	ahead, behind, err := parseAheadBehind(string(result.Stdout))
+	if err != nil {
+		return 0, fmt.Errorf("gitutils: failed to compare %s with %s in %s: %w", repo.Branch, remoteBranch, localPath, err)
+	}
+	cfg.Logger.Debugf("Branch %s in %s is %d commit(s) ahead and %d commit(s) behind %s.", repo.Branch, localPath, ahead, behind, remoteBranch)
+	return ahead, nil
+}
+
+// keepLocalCommits applies strategy to a branch with ahead commits that are not on
+// remoteBranch, before the caller resets it: it fails with ErrDivergedHistory, saves the
+// commits on a backup branch, or returns true if the clone has to be cloned again.
+func keepLocalCommits(ctx context.Context, cfg *config.Config, runner command.Runner, repo config.Repository, localPath, remoteBranch, strategy string, ahead int) (bool, error) {
+	cfg.Logger.Warnf("Branch %s in %s has diverged from %s (%d local commit(s)).", repo.Branch, localPath, remoteBranch, ahead)
+
+	switch strategy {
+	case UpdateStrategyFail:
+		return false, fmt.Errorf("gitutils: %w: branch %s in %s has %d commit(s) not on %s", ErrDivergedHistory, repo.Branch, localPath, ahead, remoteBranch)
+	case UpdateStrategyReclone:
+		return true, nil
+	case UpdateStrategyStash:
+		// Keep the local commits reachable on a backup branch before resetting.
+		backup := fmt.Sprintf("relx-go-backup/%s-%s", repo.Branch, time.Now().Format("20060102-150405"))
+		if err := runGit(ctx, cfg, runner, localPath, "branch", backup); err != nil {
+			return false, err
 		}
-		cfg.Logger.Debugf("Git clone output:\n%s", string(output))
+		cfg.Logger.Infof("Saved local commits of %s in %s on branch %s.", repo.Branch, localPath, backup)
 	}
+	return false, nil
+}
 
-	return localPath, nil
+// hasLocalCommits reports whether HEAD has commits that are not on remoteBranch, i.e.
+// whether HEAD is not an ancestor of it. It needs the full history of both.
+func hasLocalCommits(ctx context.Context, runner command.Runner, dir, remoteBranch string) (bool, error) {
+	result, err := runner.Exec(ctx, dir, "git", "merge-base", "--is-ancestor", "HEAD", remoteBranch)
+	if err == nil {
+		return false, nil
+	}
+	// Exit status 1 means "not an ancestor"; anything else is an error.
+	if result != nil && result.ExitCode == 1 {
+		return true, nil
+	}
+	return false, fmt.Errorf("git merge-base failed: %w", err)
+}
+
+// parseAheadBehind parses the output of `git rev-list --left-right --count A...B`.
+func parseAheadBehind(output string) (int, int, error) {
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q", output)
+	}
+	ahead, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q: %w", output, err)
+	}
+	behind, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q: %w", output, err)
+	}
+	return ahead, behind, nil
 }
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/gyr/relx-go/pkg/cache"
+	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/command/commandtest"
 	"github.com/gyr/relx-go/pkg/config"
 	"github.com/gyr/relx-go/pkg/logging"
@@ -75,6 +78,10 @@ func TestManageRepo(t *testing.T) {
 			}
 			if name == "git" {
 				switch args[0] {
+				case "status":
+					return []byte(""), nil
+				case "merge-base":
+					return nil, nil // HEAD is an ancestor of origin/main
 				case "switch":
 					if args[1] != "main" {
 						t.Errorf("git switch branch mismatch: got %s, want main", args[1])
@@ -166,6 +173,9 @@ func TestManageRepo(t *testing.T) {
 			if name == "git" && args[0] == "pull" {
 				return []byte("pull failed output"), mockError
 			}
+			if name == "git" && args[0] == "status" {
+				return []byte(""), nil // Clean work tree
+			}
 			return []byte("success"), nil // Other commands succeed
 		}
 
@@ -212,6 +222,281 @@ func TestManageRepo(t *testing.T) {
 	})
 }
 
+// gitMock returns a mock runner function that records every git command and answers
+// `git status --porcelain` and `git rev-list --left-right --count` with the given output.
+// `git merge-base --is-ancestor` fails if revList has commits ahead.
+func gitMock(status, revList string, calls *[]string) func(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+	return func(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+		*calls = append(*calls, strings.Join(args, " "))
+		switch args[0] {
+		case "status":
+			return []byte(status), nil
+		case "rev-list":
+			return []byte(revList), nil
+		case "merge-base":
+			if !strings.HasPrefix(revList, "0\t") {
+				return nil, errors.New("exit status 1")
+			}
+		}
+		return nil, nil
+	}
+}
+
+func TestManageRepoUpdateStrategies(t *testing.T) {
+	tempCacheDir := t.TempDir()
+	expectedRepoPath := filepath.Join(tempCacheDir, "test")
+
+	newConfig := func(strategy string) *config.Config {
+		return &config.Config{
+			RepoURL:                 "https://example.com/test.git",
+			RepoBranch:              "main",
+			CacheDir:                tempCacheDir,
+			RepoUpdateStrategy:      strategy,
+			Logger:                  logging.NewLogger(logging.LevelDebug),
+			OperationTimeoutSeconds: 5,
+		}
+	}
+
+	contains := func(calls []string, prefix string) bool {
+		for _, c := range calls {
+			if strings.HasPrefix(c, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("DirtyTreeFails", func(t *testing.T) {
+		createDummyGitRepo(t, expectedRepoPath)
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock(" M _config\n", "0\t0\n", &calls)}
+
+		_, err := ManageRepo(context.Background(), newConfig(""), runner)
+		if !errors.Is(err, ErrDirtyWorkTree) {
+			t.Fatalf("Expected ErrDirtyWorkTree, got %v", err)
+		}
+		if contains(calls, "switch") {
+			t.Error("git switch must not run on a dirty work tree with the fail strategy")
+		}
+	})
+
+	t.Run("DivergedHistoryFails", func(t *testing.T) {
+		createDummyGitRepo(t, expectedRepoPath)
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock("", "2\t5\n", &calls)}
+
+		_, err := ManageRepo(context.Background(), newConfig(UpdateStrategyFail), runner)
+		if !errors.Is(err, ErrDivergedHistory) {
+			t.Fatalf("Expected ErrDivergedHistory, got %v", err)
+		}
+		if contains(calls, "pull") || contains(calls, "reset") {
+			t.Errorf("History must not be rewritten with the fail strategy, calls: %v", calls)
+		}
+	})
+
+	t.Run("StashKeepsChanges", func(t *testing.T) {
+		createDummyGitRepo(t, expectedRepoPath)
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock("?? new-file\n", "1\t0\n", &calls)}
+
+		if _, err := ManageRepo(context.Background(), newConfig(UpdateStrategyStash), runner); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, prefix := range []string{"stash push --include-untracked", "branch relx-go-backup/main-", "reset --hard origin/main"} {
+			if !contains(calls, prefix) {
+				t.Errorf("Expected a call starting with %q, calls: %v", prefix, calls)
+			}
+		}
+	})
+
+	t.Run("ResetDiscardsChanges", func(t *testing.T) {
+		createDummyGitRepo(t, expectedRepoPath)
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock(" M _config\n", "3\t1\n", &calls)}
+
+		if _, err := ManageRepo(context.Background(), newConfig(UpdateStrategyReset), runner); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		want := []string{
+			"status --porcelain",
+			"reset --hard",
+			"clean -ffdx",
+			"switch main",
+			"fetch --prune --all",
+			"merge-base --is-ancestor HEAD origin/main",
+			"rev-list --left-right --count HEAD...origin/main",
+			"reset --hard origin/main",
+		}
+		if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Command sequence mismatch:\nGot:  %v\nWant: %v", calls, want)
+		}
+	})
+
+	t.Run("RecloneRemovesClone", func(t *testing.T) {
+		createDummyGitRepo(t, expectedRepoPath)
+		marker := filepath.Join(expectedRepoPath, "local-change")
+		if err := os.WriteFile(marker, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write marker file: %v", err)
+		}
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock(" M local-change\n", "0\t0\n", &calls)}
+
+		if _, err := ManageRepo(context.Background(), newConfig(UpdateStrategyReclone), runner); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := os.Stat(marker); !os.IsNotExist(err) {
+			t.Errorf("Expected the old clone to be removed, stat error: %v", err)
+		}
+		if !contains(calls, "clone --branch main") {
+			t.Errorf("Expected the repository to be cloned again, calls: %v", calls)
+		}
+	})
+
+	t.Run("PerRepositoryStrategy", func(t *testing.T) {
+		createDummyGitRepo(t, expectedRepoPath)
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock(" M _config\n", "0\t0\n", &calls)}
+
+		repo := config.Repository{Name: "test", URL: "https://example.com/test.git", Branch: "main", UpdateStrategy: UpdateStrategyReset}
+		if _, err := SyncRepo(context.Background(), newConfig(UpdateStrategyFail), runner, repo); err != nil {
+			t.Fatalf("Expected the per-repository strategy to take precedence, got %v", err)
+		}
+	})
+
+	t.Run("UnknownStrategy", func(t *testing.T) {
+		_, err := ManageRepo(context.Background(), newConfig("merge"), &commandtest.MockRunner{})
+		if err == nil || !strings.Contains(err.Error(), "unknown update strategy") {
+			t.Errorf("Expected unknown strategy error, got %v", err)
+		}
+	})
+}
+
+func TestManageRepoCloneOptions(t *testing.T) {
+	tempCacheDir := t.TempDir()
+	expectedRepoPath := filepath.Join(tempCacheDir, "test")
+
+	newConfig := func() *config.Config {
+		return &config.Config{
+			RepoURL:                 "https://example.com/test.git",
+			RepoBranch:              "main",
+			CacheDir:                tempCacheDir,
+			RepoCloneDepth:          1,
+			RepoCloneFilter:         "blob:none",
+			RepoSparsePaths:         []string{"/_config", "/_manifest"},
+			Logger:                  logging.NewLogger(logging.LevelDebug),
+			OperationTimeoutSeconds: 5,
+		}
+	}
+
+	t.Run("ShallowPartialSparseClone", func(t *testing.T) {
+		if err := os.RemoveAll(expectedRepoPath); err != nil {
+			t.Fatalf("Failed to remove dummy repo: %v", err)
+		}
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock("", "0\t0\n", &calls)}
+
+		if _, err := ManageRepo(context.Background(), newConfig(), runner); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		want := []string{
+			"clone --branch main --recurse-submodules=no --depth 1 --filter=blob:none --sparse https://example.com/test.git " + expectedRepoPath,
+			"sparse-checkout set --no-cone /_config /_manifest",
+		}
+		if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Command sequence mismatch:\nGot:  %v\nWant: %v", calls, want)
+		}
+	})
+
+	t.Run("UpdateKeepsOptions", func(t *testing.T) {
+		createDummyGitRepo(t, expectedRepoPath)
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock("", "0\t3\n", &calls)}
+
+		if _, err := ManageRepo(context.Background(), newConfig(), runner); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		want := []string{
+			"status --porcelain",
+			"switch main",
+			"merge-base --is-ancestor HEAD origin/main",
+			"fetch --prune --all --depth 1",
+			"reset --hard origin/main",
+			"sparse-checkout set --no-cone /_config /_manifest",
+		}
+		if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Command sequence mismatch:\nGot:  %v\nWant: %v", calls, want)
+		}
+	})
+
+	t.Run("ShallowLocalCommitsFail", func(t *testing.T) {
+		createDummyGitRepo(t, expectedRepoPath)
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock("", "1\t0\n", &calls)}
+
+		_, err := ManageRepo(context.Background(), newConfig(), runner)
+		if !errors.Is(err, ErrDivergedHistory) {
+			t.Fatalf("Expected ErrDivergedHistory, got %v", err)
+		}
+		for _, call := range calls {
+			if strings.HasPrefix(call, "fetch") || strings.HasPrefix(call, "reset") {
+				t.Errorf("Local commits of a shallow clone must not be discarded with the fail strategy, calls: %v", calls)
+			}
+		}
+	})
+
+	t.Run("PerRepositoryOverride", func(t *testing.T) {
+		if err := os.RemoveAll(expectedRepoPath); err != nil {
+			t.Fatalf("Failed to remove dummy repo: %v", err)
+		}
+		var calls []string
+		runner := &commandtest.MockRunner{RunFunc: gitMock("", "0\t0\n", &calls)}
+
+		repo := config.Repository{URL: "https://example.com/test.git", Branch: "main", Depth: 10, SparsePaths: []string{"/products/"}}
+		if _, err := SyncRepo(context.Background(), newConfig(), runner, repo); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(calls[0], "--depth 10 --filter=blob:none --sparse") {
+			t.Errorf("Expected per-repository depth and global filter, got %q", calls[0])
+		}
+		if calls[1] != "sparse-checkout set --no-cone /products/" {
+			t.Errorf("Expected per-repository sparse paths, got %q", calls[1])
+		}
+	})
+}
+
+func TestManageRepoLocked(t *testing.T) {
+	tempCacheDir := t.TempDir()
+	c, err := cache.New(tempCacheDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	held, err := c.TryLock("test")
+	if err != nil {
+		t.Fatalf("Failed to lock cache entry: %v", err)
+	}
+	defer func() { _ = held.Unlock() }()
+
+	cfg := &config.Config{
+		RepoURL:                 "https://example.com/test.git",
+		RepoBranch:              "main",
+		CacheDir:                tempCacheDir,
+		CacheLockTimeoutSeconds: 1,
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OperationTimeoutSeconds: 5,
+	}
+	mockRunner := &commandtest.MockRunner{
+		RunFunc: func(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+			t.Errorf("git must not run while the entry is locked: %v", args)
+			return nil, nil
+		},
+	}
+
+	_, err = ManageRepo(context.Background(), cfg, mockRunner)
+	if !errors.Is(err, cache.ErrLockTimeout) {
+		t.Errorf("Expected cache.ErrLockTimeout, got %v", err)
+	}
+}
+
 func TestDeriveRepoName(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -245,3 +530,182 @@ func TestDeriveRepoName(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveRepositories(t *testing.T) {
+	t.Run("MainRepositoryAppended", func(t *testing.T) {
+		cfg := &config.Config{
+			RepoURL:    "https://example.com/products/SLFO.git",
+			RepoBranch: "main",
+			Repositories: []config.Repository{
+				{URL: "git@example.com:products/SLES.git", Branch: "16.0"},
+			},
+		}
+		repos, err := ResolveRepositories(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(repos) != 2 || repos[0].Name != "SLES" || repos[1].Name != "SLFO" || repos[1].Branch != "main" {
+			t.Errorf("Unexpected repositories: %+v", repos)
+		}
+	})
+
+	t.Run("MainRepositoryAlreadyListed", func(t *testing.T) {
+		cfg := &config.Config{
+			RepoURL:    "https://example.com/products/SLFO.git",
+			RepoBranch: "main",
+			Repositories: []config.Repository{
+				{URL: "https://example.com/products/SLFO.git", Branch: "main"},
+			},
+		}
+		repos, err := ResolveRepositories(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(repos) != 1 {
+			t.Errorf("Expected the main repository to be listed once, got %+v", repos)
+		}
+	})
+
+	t.Run("MainRepositoryListedWithOtherBranch", func(t *testing.T) {
+		cfg := &config.Config{
+			RepoURL:    "https://example.com/products/SLFO.git",
+			RepoBranch: "main",
+			Repositories: []config.Repository{
+				{URL: "https://example.com/products/SLFO.git", Branch: "1.1"},
+			},
+		}
+		if _, err := ResolveRepositories(cfg); err == nil || !strings.Contains(err.Error(), `repository name "SLFO" is configured more than once`) {
+			t.Fatalf("Expected a duplicate name error, got %v", err)
+		}
+	})
+
+	t.Run("DuplicateName", func(t *testing.T) {
+		cfg := &config.Config{
+			Repositories: []config.Repository{
+				{URL: "https://example.com/a/repo.git", Branch: "main"},
+				{URL: "https://example.com/b/repo.git", Branch: "main"},
+			},
+		}
+		if _, err := ResolveRepositories(cfg); err == nil {
+			t.Fatal("Expected an error for duplicate repository names, got nil")
+		}
+	})
+}
+
+// TestManageRepoRealGit updates cached clones of a local upstream repository with git.
+func TestManageRepoRealGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	git := func(t *testing.T, dir string, args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(t *testing.T, dir, message string) {
+		t.Helper()
+		git(t, dir, "commit", "--allow-empty", "-q", "-m", message)
+	}
+
+	newUpstream := func(t *testing.T) string {
+		upstream := filepath.Join(t.TempDir(), "upstream")
+		git(t, filepath.Dir(upstream), "init", "-q", "-b", "main", upstream)
+		commit(t, upstream, "first")
+		commit(t, upstream, "second")
+		return upstream
+	}
+	newConfig := func(upstream string, depth int) *config.Config {
+		return &config.Config{
+			RepoURL:                 "file://" + upstream,
+			RepoBranch:              "main",
+			CacheDir:                t.TempDir(),
+			RepoCloneDepth:          depth,
+			Logger:                  logging.NewLogger(logging.LevelError),
+			OperationTimeoutSeconds: 30,
+		}
+	}
+
+	t.Run("ShallowCloneFollowsUpstream", func(t *testing.T) {
+		upstream := newUpstream(t)
+		cfg := newConfig(upstream, 1)
+
+		path, err := ManageRepo(context.Background(), cfg, &command.DefaultRunner{})
+		if err != nil {
+			t.Fatalf("Initial clone failed: %v", err)
+		}
+		commit(t, upstream, "third")
+		commit(t, upstream, "fourth")
+
+		// The fetched commits are not connected to the shallow history, which must
+		// not be mistaken for local commits under the default "fail" strategy.
+		if _, err := ManageRepo(context.Background(), cfg, &command.DefaultRunner{}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if got, want := git(t, path, "rev-parse", "HEAD"), git(t, upstream, "rev-parse", "HEAD"); got != want {
+			t.Errorf("HEAD = %s, want upstream tip %s", got, want)
+		}
+	})
+
+	t.Run("FullCloneDetectsLocalCommits", func(t *testing.T) {
+		upstream := newUpstream(t)
+		cfg := newConfig(upstream, 0)
+
+		path, err := ManageRepo(context.Background(), cfg, &command.DefaultRunner{})
+		if err != nil {
+			t.Fatalf("Initial clone failed: %v", err)
+		}
+		commit(t, upstream, "third")
+		if _, err := ManageRepo(context.Background(), cfg, &command.DefaultRunner{}); err != nil {
+			t.Fatalf("Fast-forward update failed: %v", err)
+		}
+		if got, want := git(t, path, "rev-parse", "HEAD"), git(t, upstream, "rev-parse", "HEAD"); got != want {
+			t.Errorf("HEAD = %s, want upstream tip %s", got, want)
+		}
+
+		commit(t, path, "local")
+		commit(t, upstream, "fourth")
+		if _, err := ManageRepo(context.Background(), cfg, &command.DefaultRunner{}); !errors.Is(err, ErrDivergedHistory) {
+			t.Errorf("Expected ErrDivergedHistory, got %v", err)
+		}
+	})
+
+	t.Run("ShallowCloneKeepsLocalCommits", func(t *testing.T) {
+		upstream := newUpstream(t)
+		cfg := newConfig(upstream, 1)
+
+		path, err := ManageRepo(context.Background(), cfg, &command.DefaultRunner{})
+		if err != nil {
+			t.Fatalf("Initial clone failed: %v", err)
+		}
+		commit(t, path, "local")
+		local := git(t, path, "rev-parse", "HEAD")
+		commit(t, upstream, "third")
+
+		if _, err := ManageRepo(context.Background(), cfg, &command.DefaultRunner{}); !errors.Is(err, ErrDivergedHistory) {
+			t.Fatalf("Expected ErrDivergedHistory, got %v", err)
+		}
+		if got := git(t, path, "rev-parse", "HEAD"); got != local {
+			t.Errorf("HEAD = %s, want the local commit %s to be kept", got, local)
+		}
+
+		cfg.RepoUpdateStrategy = UpdateStrategyStash
+		if _, err := ManageRepo(context.Background(), cfg, &command.DefaultRunner{}); err != nil {
+			t.Fatalf("Update with the stash strategy failed: %v", err)
+		}
+		if got, want := git(t, path, "rev-parse", "HEAD"), git(t, upstream, "rev-parse", "HEAD"); got != want {
+			t.Errorf("HEAD = %s, want upstream tip %s", got, want)
+		}
+		if backup := git(t, path, "for-each-ref", "--format=%(objectname)", "refs/heads/relx-go-backup/"); backup != local {
+			t.Errorf("Expected the local commit %s on a backup branch, got %q", local, backup)
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package gitutils
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/timeout"
+)
+
+// Submodule describes a submodule of a repository as pinned in its HEAD commit.
+type Submodule struct {
+	// Name is the name of the submodule in .gitmodules.
+	Name string
+	// Path is the location of the submodule in the superproject.
+	Path string
+	// URL is the absolute URL of the submodule repository.
+	URL string
+	// Branch is the branch the submodule tracks. It is empty if .gitmodules does not set one.
+	Branch string
+	// Commit is the commit the superproject pins the submodule to.
+	Commit string
+}
+
+// ListSubmodules returns the submodules of the clone at localPath, sorted by path.
+// The submodule definitions are read from .gitmodules in HEAD, so the submodules don't
+// need to be checked out and sparse clones work as well. Relative submodule URLs are
+// resolved against the repository URL, and a branch of "." is replaced by repo.Branch.
+func ListSubmodules(ctx context.Context, cfg *config.Config, runner command.Runner, repo config.Repository, localPath string) ([]Submodule, error) {
+	timeoutCtx, op := timeout.Start(ctx, cfg, timeout.GitSubmodules)
+	defer op.Stop()
+
+	result, err := runner.Exec(timeoutCtx, localPath, "git", "config", "--blob", "HEAD:.gitmodules", "--get-regexp", `^submodule\..*\.(path|url|branch)$`)
+	if err != nil {
+		// `git config --get-regexp` exits with 1 when nothing matches, and git cannot
+		// resolve the blob when the repository has no .gitmodules at all.
+		stderr := string(result.Stderr)
+		if (result.ExitCode == 1 && strings.TrimSpace(stderr) == "") || strings.Contains(stderr, "unable to resolve config blob") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gitutils: failed to read .gitmodules in %s: %w", localPath, op.Err(err))
+	}
+
+	byName := make(map[string]*Submodule)
+	for _, line := range strings.Split(string(result.Stdout), "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found {
+			continue
+		}
+		key = strings.TrimPrefix(key, "submodule.")
+		dot := strings.LastIndex(key, ".")
+		if dot == -1 {
+			continue
+		}
+		name, attr := key[:dot], key[dot+1:]
+
+		sm, exists := byName[name]
+		if !exists {
+			sm = &Submodule{Name: name}
+			byName[name] = sm
+		}
+		switch attr {
+		case "path":
+			sm.Path = value
+		case "url":
+			sm.URL = value
+		case "branch":
+			sm.Branch = value
+		}
+	}
+
+	var submodules []Submodule
+	byPath := make(map[string]int)
+	for _, sm := range byName {
+		if sm.Path == "" {
+			continue
+		}
+		resolvedURL, err := resolveSubmoduleURL(repo.URL, sm.URL)
+		if err != nil {
+			return nil, err
+		}
+		sm.URL = resolvedURL
+		if sm.Branch == "." {
+			sm.Branch = repo.Branch
+		}
+		submodules = append(submodules, *sm)
+	}
+	if len(submodules) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(submodules, func(i, j int) bool { return submodules[i].Path < submodules[j].Path })
+	paths := make([]string, len(submodules))
+	for i, sm := range submodules {
+		byPath[sm.Path] = i
+		paths[i] = sm.Path
+	}
+
+	// Read the pinned commits (gitlinks) from the tree of HEAD.
+	args := append([]string{"ls-tree", "HEAD", "--"}, paths...)
+	result, err = runner.Exec(timeoutCtx, localPath, "git", args...)
+	if err != nil {
+		return nil, fmt.Errorf("gitutils: git ls-tree failed in %s: %w", localPath, op.Err(err))
+	}
+	for _, line := range strings.Split(string(result.Stdout), "\n") {
+		meta, smPath, found := strings.Cut(line, "\t")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 3 || fields[1] != "commit" {
+			continue
+		}
+		if i, exists := byPath[smPath]; exists {
+			submodules[i].Commit = fields[2]
+		}
+	}
+
+	return submodules, nil
+}
+
+// RemoteBranchHead returns the commit at the tip of branch in the remote repository at repoURL.
+// If branch is empty, the remote's default branch (HEAD) is used.
+func RemoteBranchHead(ctx context.Context, cfg *config.Config, runner command.Runner, repoURL, branch string) (string, error) {
+	timeoutCtx, op := timeout.Start(ctx, cfg, timeout.GitLsRemote)
+	defer op.Stop()
+
+	ref := "HEAD"
+	if branch != "" {
+		ref = "refs/heads/" + branch
+	}
+
+	result, err := runner.Exec(timeoutCtx, "" /* workDir */, "git", "ls-remote", repoURL, ref)
+	if err != nil {
+		return "", fmt.Errorf("gitutils: git ls-remote failed for %s: %w", repoURL, op.Err(err))
+	}
+
+	for _, line := range strings.Split(string(result.Stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("gitutils: ref %s not found in %s", ref, repoURL)
+}
+
+// CompareCommits returns how many commits commit has that tip does not (ahead), and how
+// many commits tip has that commit does not (behind), in the remote repository at repoURL.
+// Both commits are fetched without trees and blobs into a temporary bare repository, so
+// only their history is downloaded; the remote must allow fetching commits by their ID,
+// which protocol v2 does.
+func CompareCommits(ctx context.Context, cfg *config.Config, runner command.Runner, repoURL, commit, tip string) (ahead, behind int, err error) {
+	timeoutCtx, op := timeout.Start(ctx, cfg, timeout.GitFetch)
+	defer op.Stop()
+
+	dir, err := os.MkdirTemp("", "relx-go-compare-*")
+	if err != nil {
+		return 0, 0, fmt.Errorf("gitutils: failed to create a temporary repository: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	for _, args := range [][]string{
+		{"init", "--quiet", "--bare", dir},
+		{"-C", dir, "fetch", "--quiet", "--no-tags", "--filter=tree:0", repoURL, commit, tip},
+	} {
+		if _, err := runner.Exec(timeoutCtx, "" /* workDir */, "git", args...); err != nil {
+			return 0, 0, fmt.Errorf("gitutils: failed to fetch %s and %s from %s: %w", commit, tip, repoURL, op.Err(err))
+		}
+	}
+	result, err := runner.Exec(timeoutCtx, "" /* workDir */, "git", "-C", dir, "rev-list", "--left-right", "--count", commit+"..."+tip)
+	if err != nil {
+		return 0, 0, fmt.Errorf("gitutils: failed to compare %s with %s in %s: %w", commit, tip, repoURL, op.Err(err))
+	}
+	return parseAheadBehind(string(result.Stdout))
+}
+
+// resolveSubmoduleURL resolves a submodule URL that is relative to the superproject URL
+// ("./" or "../"), following the rules of git-submodule(1). Absolute URLs are returned unchanged.
+func resolveSubmoduleURL(baseURL, submoduleURL string) (string, error) {
+	if !strings.HasPrefix(submoduleURL, "./") && !strings.HasPrefix(submoduleURL, "../") {
+		return submoduleURL, nil
+	}
+
+	if strings.Contains(baseURL, "://") {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return "", fmt.Errorf("gitutils: failed to parse URL %s: %w", baseURL, err)
+		}
+		u.Path = path.Join(u.Path, submoduleURL)
+		return u.String(), nil
+	}
+
+	// scp-like syntax, e.g. gitea@example.com:products/SLFO.git
+	host, repoPath, found := strings.Cut(baseURL, ":")
+	if !found {
+		return path.Join(baseURL, submoduleURL), nil
+	}
+	return host + ":" + path.Join(repoPath, submoduleURL), nil
+}
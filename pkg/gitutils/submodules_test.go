@@ -0,0 +1,207 @@
+package gitutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gyr/relx-go/pkg/command"
+	"github.com/gyr/relx-go/pkg/command/commandtest"
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/logging"
+)
+
+func TestListSubmodules(t *testing.T) {
+	mockCfg := &config.Config{
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OperationTimeoutSeconds: 5,
+	}
+	repo := config.Repository{URL: "https://src.example.com/products/SLFO.git", Branch: "slfo-main"}
+
+	t.Run("Success", func(t *testing.T) {
+		gitmodules := `submodule.bash.path bash
+submodule.bash.url ../../pool/bash
+submodule.bash.branch slfo-main
+submodule.zypper.path zypper
+submodule.zypper.url https://src.example.com/pool/zypper.git
+submodule.zypper.branch .
+submodule.vim.path vim
+submodule.vim.url ../../pool/vim
+`
+		lsTree := "160000 commit 1111111111111111111111111111111111111111\tbash\n" +
+			"160000 commit 2222222222222222222222222222222222222222\tvim\n" +
+			"160000 commit 3333333333333333333333333333333333333333\tzypper\n"
+
+		mockRunner := &commandtest.MockRunner{
+			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+				if workDir != "/cache/SLFO" {
+					t.Errorf("Expected workDir /cache/SLFO, got %s", workDir)
+				}
+				switch args[0] {
+				case "config":
+					return []byte(gitmodules), nil
+				case "ls-tree":
+					want := []string{"ls-tree", "HEAD", "--", "bash", "vim", "zypper"}
+					if !reflect.DeepEqual(args, want) {
+						t.Errorf("Unexpected ls-tree args: got %v, want %v", args, want)
+					}
+					return []byte(lsTree), nil
+				}
+				return nil, fmt.Errorf("unexpected command: %s %v", name, args)
+			},
+		}
+
+		submodules, err := ListSubmodules(context.Background(), mockCfg, mockRunner, repo, "/cache/SLFO")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		want := []Submodule{
+			{Name: "bash", Path: "bash", URL: "https://src.example.com/pool/bash", Branch: "slfo-main", Commit: "1111111111111111111111111111111111111111"},
+			{Name: "vim", Path: "vim", URL: "https://src.example.com/pool/vim", Branch: "", Commit: "2222222222222222222222222222222222222222"},
+			{Name: "zypper", Path: "zypper", URL: "https://src.example.com/pool/zypper.git", Branch: "slfo-main", Commit: "3333333333333333333333333333333333333333"},
+		}
+		if !reflect.DeepEqual(submodules, want) {
+			t.Errorf("Submodules mismatch:\nGot:  %+v\nWant: %+v", submodules, want)
+		}
+	})
+
+	t.Run("NoGitmodules", func(t *testing.T) {
+		mockRunner := &commandtest.MockRunner{
+			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+				return []byte("fatal: unable to resolve config blob 'HEAD:.gitmodules'\n"), errors.New("exit status 128")
+			},
+		}
+
+		submodules, err := ListSubmodules(context.Background(), mockCfg, mockRunner, repo, "/cache/SLFO")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(submodules) != 0 {
+			t.Errorf("Expected no submodules, got %v", submodules)
+		}
+	})
+}
+
+func TestRemoteBranchHead(t *testing.T) {
+	mockCfg := &config.Config{
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OperationTimeoutSeconds: 5,
+	}
+
+	t.Run("Branch", func(t *testing.T) {
+		mockRunner := &commandtest.MockRunner{
+			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+				want := []string{"ls-remote", "https://example.com/pool/bash", "refs/heads/main"}
+				if !reflect.DeepEqual(args, want) {
+					t.Errorf("Unexpected args: got %v, want %v", args, want)
+				}
+				return []byte("abcdef\trefs/heads/main\n"), nil
+			},
+		}
+
+		head, err := RemoteBranchHead(context.Background(), mockCfg, mockRunner, "https://example.com/pool/bash", "main")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if head != "abcdef" {
+			t.Errorf("Expected head abcdef, got %s", head)
+		}
+	})
+
+	t.Run("BranchNotFound", func(t *testing.T) {
+		mockRunner := &commandtest.MockRunner{
+			RunFunc: func(ctx context.Context, workDir, name string, args ...string) ([]byte, error) {
+				return []byte(""), nil
+			},
+		}
+
+		_, err := RemoteBranchHead(context.Background(), mockCfg, mockRunner, "https://example.com/pool/bash", "gone")
+		if err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("Expected not found error, got %v", err)
+		}
+	})
+}
+
+// TestCompareCommits compares commits of a local upstream repository with git.
+func TestCompareCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", upstream, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if err := exec.Command("git", "init", "-q", "-b", "main", upstream).Run(); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+	git("commit", "-q", "--allow-empty", "-m", "first")
+	first := git("rev-parse", "HEAD")
+	git("commit", "-q", "--allow-empty", "-m", "second")
+	git("commit", "-q", "--allow-empty", "-m", "third")
+	tip := git("rev-parse", "HEAD")
+	// A commit that is only reachable by its ID, like a package pinned to a deleted branch.
+	git("switch", "-q", "-c", "side", first)
+	git("commit", "-q", "--allow-empty", "-m", "side")
+	side := git("rev-parse", "HEAD")
+	git("switch", "-q", "main")
+	git("branch", "-q", "-D", "side")
+
+	cfg := &config.Config{Logger: logging.NewLogger(logging.LevelError), OperationTimeoutSeconds: 30}
+	for _, tc := range []struct {
+		name          string
+		commit        string
+		ahead, behind int
+	}{
+		{"Behind", first, 0, 2},
+		{"Diverged", side, 1, 2},
+		{"Same", tip, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ahead, behind, err := CompareCommits(context.Background(), cfg, &command.DefaultRunner{}, "file://"+upstream, tc.commit, tip)
+			if err != nil {
+				t.Fatalf("CompareCommits() error = %v", err)
+			}
+			if ahead != tc.ahead || behind != tc.behind {
+				t.Errorf("CompareCommits() = %d ahead, %d behind, want %d, %d", ahead, behind, tc.ahead, tc.behind)
+			}
+		})
+	}
+}
+
+func TestResolveSubmoduleURL(t *testing.T) {
+	testCases := []struct {
+		base     string
+		url      string
+		expected string
+	}{
+		{"https://example.com/products/SLFO.git", "../../pool/bash", "https://example.com/pool/bash"},
+		{"https://example.com/products/SLFO.git", "../SLES.git", "https://example.com/products/SLES.git"},
+		{"https://example.com/products/SLFO.git", "./sub", "https://example.com/products/SLFO.git/sub"},
+		{"gitea@example.com:products/SLFO.git", "../../pool/bash", "gitea@example.com:pool/bash"},
+		{"https://example.com/products/SLFO.git", "https://other.com/bash.git", "https://other.com/bash.git"},
+	}
+
+	for _, tc := range testCases {
+		got, err := resolveSubmoduleURL(tc.base, tc.url)
+		if err != nil {
+			t.Fatalf("resolveSubmoduleURL(%q, %q) returned error: %v", tc.base, tc.url, err)
+		}
+		if got != tc.expected {
+			t.Errorf("resolveSubmoduleURL(%q, %q) = %q, want %q", tc.base, tc.url, got, tc.expected)
+		}
+	}
+}
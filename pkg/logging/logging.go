@@ -1,92 +1,300 @@
+// Package logging provides the leveled logger of relx-go. It is built on log/slog, so
+// messages can carry key/value attributes and be written as text or JSON.
 package logging
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
+	"time"
 )
 
 // LogLevel defines the level of logging.
 type LogLevel int
 
 const (
-	// LevelError is the lowest level, only errors will be logged.
+	// LevelError is the lowest level, only errors will be logged (-q).
 	LevelError LogLevel = iota
+	// LevelWarn logs errors and warnings. It is the default level.
+	LevelWarn
 	// LevelInfo enables info logging.
 	LevelInfo
 	// LevelDebug enables debug logging.
 	LevelDebug
 )
 
-// Logger is a configurable logger.
+// levelFatal is the slog level of messages logged by Fatal and Fatalf.
+const levelFatal = slog.LevelError + 4
+
+// DefaultExitCode is the exit status of Fatal and Fatalf unless configured otherwise.
+const DefaultExitCode = 1
+
+// ExitCoder is implemented by errors that call for a specific exit status when they
+// are passed to Fatal or Fatalf.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// osExit ends the program; tests replace it.
+var osExit = os.Exit
+
+// Format selects how log records are written.
+type Format string
+
+const (
+	// FormatText writes records as key=value pairs (the default).
+	FormatText Format = "text"
+	// FormatJSON writes one JSON object per record.
+	FormatJSON Format = "json"
+)
+
+// Options configures a Logger created with New.
+type Options struct {
+	Level  LogLevel
+	Format Format    // FormatText if empty
+	Output io.Writer // Stderr if nil
+	// Stderr is where fatal messages are printed for the user. os.Stderr if nil.
+	Stderr io.Writer
+	// ExitCode is the exit status of Fatal and Fatalf for messages without an ExitCoder
+	// error. Zero means DefaultExitCode.
+	ExitCode int
+}
+
+// Logger is a configurable logger. It is safe for concurrent use.
 type Logger struct {
-	level  LogLevel
-	logger *log.Logger
+	handler  slog.Handler
+	output   io.Writer
+	stderr   io.Writer
+	exitCode int
+	// plainFatal is set when text is logged to stderr. Fatal messages are then printed
+	// as they are, since they are meant for the user and may span several lines.
+	plainFatal bool
 }
 
-// NewLogger creates a new logger.
+// NewLogger creates a new logger writing text to stderr.
 func NewLogger(level LogLevel) *Logger {
+	return New(Options{Level: level})
+}
+
+// New creates a new logger with the given options.
+func New(opts Options) *Logger {
+	stderr := opts.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	output := opts.Output
+	if output == nil {
+		output = stderr
+	}
+	handlerOpts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       slogLevel(opts.Level),
+		ReplaceAttr: replaceAttr,
+	}
+
+	var handler slog.Handler
+	if opts.Format == FormatJSON {
+		handler = slog.NewJSONHandler(output, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(output, handlerOpts)
+	}
+	exitCode := opts.ExitCode
+	if exitCode == 0 {
+		exitCode = DefaultExitCode
+	}
+	return &Logger{
+		handler:    handler,
+		output:     output,
+		stderr:     stderr,
+		exitCode:   exitCode,
+		plainFatal: output == stderr && opts.Format != FormatJSON,
+	}
+}
+
+// ParseFormat returns the Format named s, which is "text" or "json".
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatText, FormatJSON:
+		return Format(s), nil
+	}
+	return "", fmt.Errorf("logging: unknown log format %q (valid: text, json)", s)
+}
+
+// OpenFile opens the log file at path for appending, creating it and its directory
+// if needed.
+func OpenFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("logging: failed to create directory for log file %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("logging: failed to open log file: %w", err)
+	}
+	return file, nil
+}
+
+// With returns a logger that adds the given key/value attributes to every message,
+// e.g. l.With("project", project, "package", pkg).
+func (l *Logger) With(args ...interface{}) *Logger {
 	return &Logger{
-		level:  level,
-		logger: log.New(os.Stderr, "", log.Ldate|log.Ltime|log.Lshortfile),
+		handler:    slog.New(l.handler).With(args...).Handler(),
+		output:     l.output,
+		stderr:     l.stderr,
+		exitCode:   l.exitCode,
+		plainFatal: l.plainFatal,
 	}
 }
 
 // Info logs messages only if the 'Verbose' or 'Debug' flag is set.
 func (l *Logger) Info(v ...interface{}) {
-	if l.level >= LevelInfo {
-		l.logger.SetPrefix("[INFO] ")
-		l.logger.Println(v...)
-	}
+	l.log(slog.LevelInfo, fmt.Sprintln(v...))
 }
 
 // Infof logs messages only if the 'Verbose' or 'Debug' flag is set.
 func (l *Logger) Infof(format string, v ...interface{}) {
-	if l.level >= LevelInfo {
-		l.logger.SetPrefix("[INFO] ")
-		l.logger.Printf(format, v...)
-	}
+	l.log(slog.LevelInfo, fmt.Sprintf(format, v...))
 }
 
 // Debug logs messages only if the 'Debug' flag is set.
 func (l *Logger) Debug(v ...interface{}) {
-	if l.level >= LevelDebug {
-		l.logger.SetPrefix("[DEBUG] ")
-		l.logger.Println(v...)
-	}
+	l.log(slog.LevelDebug, fmt.Sprintln(v...))
 }
 
 // Debugf logs messages only if the 'DebugFlag' flag is set.
 func (l *Logger) Debugf(format string, v ...interface{}) {
-	if l.level >= LevelDebug {
-		l.logger.SetPrefix("[DEBUG] ")
-		l.logger.Printf(format, v...)
-	}
+	l.log(slog.LevelDebug, fmt.Sprintf(format, v...))
 }
 
-// Fatal is equivalent to l.logger.Fatal, but it respects the log level.
+// Fatal reports the message regardless of the log level and exits. The exit status is
+// taken from the first argument implementing ExitCoder, or the configured exit code.
+// With text logs on stderr, the message is printed as it is. Otherwise it is logged,
+// and also printed to stderr when logging to a file, so that it is not missed.
 func (l *Logger) Fatal(v ...interface{}) {
-	l.logger.SetPrefix("[FATAL] ")
-	l.logger.Fatal(v...)
+	msg := trimNewline(fmt.Sprintln(v...))
+	if !l.plainFatal {
+		l.log(levelFatal, msg)
+	}
+	l.exit(msg, v)
 }
 
-// Fatalf is equivalent to l.logger.Fatalf, but it respects the log level.
+// Fatalf is like Fatal, but formats the message like fmt.Sprintf.
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	l.logger.SetPrefix("[FATAL] ")
-	l.logger.Fatalf(format, v...)
+	msg := fmt.Sprintf(format, v...)
+	if !l.plainFatal {
+		l.log(levelFatal, msg)
+	}
+	l.exit(msg, v)
 }
 
-// Warn logs messages at the INFO level with a WARN prefix.
-func (l *Logger) Warn(v ...interface{}) {
-	if l.level >= LevelInfo {
-		l.logger.SetPrefix("[WARN] ")
-		l.logger.Println(v...)
+// Failf reports the message like Fatalf, but returns instead of exiting, so that the
+// caller can clean up and exit with a status of its choice.
+func (l *Logger) Failf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	if !l.plainFatal {
+		l.log(levelFatal, msg)
 	}
+	l.printFatal(msg)
+}
+
+// Error logs errors unless the level is below LevelError.
+func (l *Logger) Error(v ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprintln(v...))
+}
+
+// Errorf logs errors unless the level is below LevelError.
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprintf(format, v...))
+}
+
+// Warn logs warnings unless the 'Quiet' flag is set.
+func (l *Logger) Warn(v ...interface{}) {
+	l.log(slog.LevelWarn, fmt.Sprintln(v...))
 }
 
-// Warnf logs messages at the INFO level with a WARN prefix.
+// Warnf logs warnings unless the 'Quiet' flag is set.
 func (l *Logger) Warnf(format string, v ...interface{}) {
-	if l.level >= LevelInfo {
-		l.logger.SetPrefix("[WARN] ")
-		l.logger.Printf(format, v...)
+	l.log(slog.LevelWarn, fmt.Sprintf(format, v...))
+}
+
+// exit ends the program after a fatal message with the exit code for the message arguments.
+func (l *Logger) exit(msg string, args []interface{}) {
+	l.printFatal(msg)
+	osExit(l.ExitCode(args...))
+}
+
+// printFatal prints a fatal message to stderr unless it was logged there.
+func (l *Logger) printFatal(msg string) {
+	if l.plainFatal || l.output != l.stderr {
+		fmt.Fprintln(l.stderr, msg)
+	}
+}
+
+// ExitCode returns the exit status Fatal uses for the given arguments: the code of the
+// first error implementing ExitCoder, or the configured exit code.
+func (l *Logger) ExitCode(args ...interface{}) int {
+	for _, arg := range args {
+		var coder ExitCoder
+		if err, ok := arg.(error); ok && errors.As(err, &coder) {
+			return coder.ExitCode()
+		}
+	}
+	return l.exitCode
+}
+
+// log writes a record at the given level. It must be called directly by the exported
+// logging methods, so that the source of the record is their caller.
+func (l *Logger) log(level slog.Level, msg string) {
+	ctx := context.Background()
+	if !l.handler.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip runtime.Callers, log and the exported method
+	record := slog.NewRecord(time.Now(), level, trimNewline(msg), pcs[0])
+	_ = l.handler.Handle(ctx, record)
+}
+
+// slogLevel returns the minimum slog level printed at the given log level.
+func slogLevel(level LogLevel) slog.Level {
+	switch {
+	case level >= LevelDebug:
+		return slog.LevelDebug
+	case level >= LevelInfo:
+		return slog.LevelInfo
+	case level >= LevelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// replaceAttr names the fatal level and shortens the source to file:line.
+func replaceAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return attr
+	}
+	switch attr.Key {
+	case slog.LevelKey:
+		if level, ok := attr.Value.Any().(slog.Level); ok && level >= levelFatal {
+			return slog.String(slog.LevelKey, "FATAL")
+		}
+	case slog.SourceKey:
+		if source, ok := attr.Value.Any().(*slog.Source); ok {
+			return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+		}
+	}
+	return attr
+}
+
+// trimNewline removes the newline added by fmt.Sprintln.
+func trimNewline(msg string) string {
+	if n := len(msg); n > 0 && msg[n-1] == '\n' {
+		return msg[:n-1]
 	}
+	return msg
 }
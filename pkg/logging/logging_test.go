@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Options{Level: LevelInfo, Format: FormatJSON, Output: &buf})
+
+	logger.With("project", "SUSE:SLFO", "package", "sles").Infof("Found %d binaries", 3)
+	logger.Debugf("Not printed at info level")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 record, got %d: %q", len(lines), buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Failed to parse record %q: %v", lines[0], err)
+	}
+	for key, want := range map[string]string{
+		"level":   "INFO",
+		"msg":     "Found 3 binaries",
+		"project": "SUSE:SLFO",
+		"package": "sles",
+	} {
+		if record[key] != want {
+			t.Errorf("Expected %s to be %q, got %v", key, want, record[key])
+		}
+	}
+	if source, _ := record["source"].(string); !strings.HasPrefix(source, "logging_test.go:") {
+		t.Errorf("Expected the source to be the caller, got %v", record["source"])
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	tests := []struct {
+		level LogLevel
+		want  []string
+	}{
+		{LevelError, []string{"level=ERROR"}},
+		{LevelWarn, []string{"level=WARN", "level=ERROR"}},
+		{LevelInfo, []string{"level=INFO", "level=WARN", "level=ERROR"}},
+		{LevelDebug, []string{"level=INFO", "level=WARN", "level=ERROR", "level=DEBUG"}},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := New(Options{Level: tt.level, Output: &buf})
+		logger.Info("info")
+		logger.Warnf("warn %s", "message")
+		logger.Errorf("error %s", "message")
+		logger.Debug("debug")
+
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line != "" {
+				got = append(got, strings.Fields(line)[1])
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("At level %d, expected %v, got %v", tt.level, tt.want, got)
+		}
+	}
+}
+
+// TestLoggerConcurrent logs from many goroutines, like obs.ListArtifacts does.
+// Run with -race to detect unsynchronized state in the logger.
+func TestLoggerConcurrent(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	logger := New(Options{Level: LevelDebug, Output: writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	})})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger.With("worker", i).Debugf("debug %d", i)
+			logger.Warnf("warn %d", i)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := strings.Count(buf.String(), "\n"); got != 40 {
+		t.Errorf("Expected 40 records, got %d", got)
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+type exitCodeError struct{ code int }
+
+func (e exitCodeError) Error() string { return "failed" }
+func (e exitCodeError) ExitCode() int { return e.code }
+
+func TestLoggerFatalExitCode(t *testing.T) {
+	var exitCode int
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = os.Exit }()
+
+	tests := []struct {
+		name     string
+		logger   *Logger
+		args     []interface{}
+		wantCode int
+	}{
+		{"Default", New(Options{Output: io.Discard}), []interface{}{errors.New("failed")}, DefaultExitCode},
+		{"Configured", New(Options{Output: io.Discard, ExitCode: 3}), []interface{}{"failed"}, 3},
+		{"ExitCoder", New(Options{Output: io.Discard, ExitCode: 3}), []interface{}{fmt.Errorf("wrapped: %w", exitCodeError{124})}, 124},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.logger.Fatalf("Error: %v", tt.args...)
+			if exitCode != tt.wantCode {
+				t.Errorf("Expected exit code %d, got %d", tt.wantCode, exitCode)
+			}
+		})
+	}
+}
+
+func TestLoggerFailf(t *testing.T) {
+	t.Run("Text", func(t *testing.T) {
+		var stderr bytes.Buffer
+		New(Options{Stderr: &stderr}).Failf("Error: %s", "first line\nsecond line")
+		if got, want := stderr.String(), "Error: first line\nsecond line\n"; got != want {
+			t.Errorf("Expected the message as it is %q, got %q", want, got)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var stderr bytes.Buffer
+		New(Options{Stderr: &stderr, Format: FormatJSON}).Failf("Error: %s", "failed")
+		var record map[string]interface{}
+		if err := json.Unmarshal(stderr.Bytes(), &record); err != nil {
+			t.Fatalf("Failed to parse record %q: %v", stderr.String(), err)
+		}
+		if record["level"] != "FATAL" || record["msg"] != "Error: failed" {
+			t.Errorf("Unexpected record: %v", record)
+		}
+	})
+}
@@ -2,15 +2,17 @@ package obs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
-	"time"
 
+	"github.com/gyr/relx-go/pkg/cache"
 	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/timeout"
 )
 
 const maxConcurrentOscCalls = 10
@@ -37,31 +39,32 @@ func NewClient(runner command.Runner, cfg *config.Config) *Client {
 func (c *Client) ListArtifacts(ctx context.Context, project string) ([]string, error) {
 	c.cfg.Logger.Infof("Starting artifact search for project: %s", project)
 
-	// Step 1: Get the list of all packages in the project.
-	packages, err := c.listPackages(ctx, project)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list packages for project %s: %w", project, err)
-	}
-	c.cfg.Logger.Debugf("Found %d packages in project %s.", len(packages), project)
-
-	// Step 2: Filter the package list based on configured patterns and associate them with a repository.
+	// Steps 1 and 2: List all packages in the project, and filter them based on configured
+	// patterns while they are listed, associating them with a repository. Big projects
+	// have tens of thousands of packages, so the full list is never kept.
 	// A map is used to store the package -> repository mapping, which also de-duplicates packages.
 	filteredPackages := make(map[string]string)
-	if len(c.cfg.PackageFilterPatterns) > 0 {
-		for _, pkg := range packages {
-			for _, filter := range c.cfg.PackageFilterPatterns {
-				matched, err := filepath.Match(filter.Pattern, pkg)
-				if err != nil {
-					c.cfg.Logger.Warnf("Invalid pattern '%s' in config: %v", filter.Pattern, err)
-					continue
-				}
-				if matched {
-					filteredPackages[pkg] = filter.Repository
-					break // Match found, no need to check other patterns for this package
-				}
+	var listed int
+	err := c.listPackages(ctx, project, func(pkg string) error {
+		listed++
+		for _, filter := range c.cfg.PackageFilterPatterns {
+			matched, err := filepath.Match(filter.Pattern, pkg)
+			if err != nil {
+				c.cfg.Logger.Warnf("Invalid pattern '%s' in config: %v", filter.Pattern, err)
+				continue
+			}
+			if matched {
+				filteredPackages[pkg] = filter.Repository
+				break // Match found, no need to check other patterns for this package
 			}
 		}
-	} else {
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages for project %s: %w", project, err)
+	}
+	c.cfg.Logger.Debugf("Found %d packages in project %s.", listed, project)
+	if len(c.cfg.PackageFilterPatterns) == 0 {
 		// If no package filters are defined, we cannot proceed because we don't know which
 		// repositories to target. The user must be explicit.
 		c.cfg.Logger.Infof("No package_filter_patterns defined in config. No packages to process.")
@@ -75,6 +78,7 @@ func (c *Client) ListArtifacts(ctx context.Context, project string) ([]string, e
 	}
 
 	// Step 3: Concurrently get binaries for each filtered package.
+	task := c.cfg.Progress.Start("Listing binaries", len(filteredPackages))
 	var wg sync.WaitGroup
 	errCh := make(chan error, len(filteredPackages))
 	resultsCh := make(chan []string, len(filteredPackages))
@@ -86,6 +90,7 @@ func (c *Client) ListArtifacts(ctx context.Context, project string) ([]string, e
 		go func(pkgName, repoName string) {
 			defer wg.Done()
 			defer func() { <-sem }()
+			defer task.Add(1)
 
 			binaries, err := c.listBinariesForPackage(ctx, project, pkgName, repoName)
 			if err != nil {
@@ -101,6 +106,7 @@ func (c *Client) ListArtifacts(ctx context.Context, project string) ([]string, e
 	// while waiting for the channels to be closed.
 	go func() {
 		wg.Wait()
+		task.Finish()
 		// Once all goroutines are done, close the channels to signal that no more
 		// data will be sent.
 		close(errCh)
@@ -116,7 +122,7 @@ func (c *Client) ListArtifacts(ctx context.Context, project string) ([]string, e
 
 	// If any errors were collected, return them.
 	if len(allErrors) > 0 {
-		return nil, fmt.Errorf("multiple errors occurred while listing binaries: %v", allErrors)
+		return nil, fmt.Errorf("multiple errors occurred while listing binaries: %w", errors.Join(allErrors...))
 	}
 
 	// Collect all the results from the results channel.
@@ -154,46 +160,44 @@ func (c *Client) ListArtifacts(ctx context.Context, project string) ([]string, e
 	return filteredBinaries, nil
 }
 
-// listPackages runs `osc ls` to get a list of all packages in a project.
-func (c *Client) listPackages(ctx context.Context, project string) ([]string, error) {
-	timeout := time.Duration(c.cfg.OperationTimeoutSeconds) * time.Second
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+// listPackages runs `osc ls` and calls onPackage for each package of a project as the
+// output of osc is read.
+func (c *Client) listPackages(ctx context.Context, project string, onPackage func(pkg string) error) error {
+	timeoutCtx, op := timeout.Start(ctx, c.cfg, timeout.OscList)
+	defer op.Stop()
 
-	c.cfg.Logger.Debugf("Executing 'osc ls' for project: %s", project)
-
-	var output []byte
-	var err error
+	c.cfg.Logger.With("project", project, "command", "osc ls").Debugf("Executing 'osc ls' for project: %s", project)
 
+	cacheKey := fmt.Sprintf("osc/ls/%s/%s", c.cfg.OBSAPIURL, project)
+	args := []string{"ls", project}
 	if c.cfg.OBSAPIURL != "" {
-		output, err = c.runner.Run(timeoutCtx, "" /* workDir */, "osc", "-A", c.cfg.OBSAPIURL, "ls", project)
-	} else {
-		output, err = c.runner.Run(timeoutCtx, "" /* workDir */, "osc", "ls", project)
+		args = append([]string{"-A", c.cfg.OBSAPIURL}, args...)
 	}
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to run 'osc ls' for project '%s': %w. Output: %s", project, err, string(output))
+	fetch := func(emit func(line string) error) error {
+		result, err := c.runner.Stream(timeoutCtx, "" /* workDir */, emit, "osc", args...)
+		if err == nil {
+			c.logStderr(args, result.Stderr)
+		}
+		return err
 	}
-
-	packages := strings.Split(string(output), "\n")
-
-	var cleanedPackages []string
-	for _, pkg := range packages {
-		if strings.TrimSpace(pkg) != "" {
-			cleanedPackages = append(cleanedPackages, strings.TrimSpace(pkg))
+	err := cache.RememberLines(c.cfg.CacheDir, cacheKey, c.cfg.MetadataCacheTTL(), fetch, func(line string) error {
+		if pkg := strings.TrimSpace(line); pkg != "" {
+			return onPackage(pkg)
 		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run 'osc ls' for project '%s': %w", project, op.Err(err))
 	}
-
-	return cleanedPackages, nil
+	return nil
 }
 
 // listBinariesForPackage runs `osc ls -b` for a single package and optional repository.
 func (c *Client) listBinariesForPackage(ctx context.Context, project, pkg, repository string) ([]string, error) {
-	timeout := time.Duration(c.cfg.OperationTimeoutSeconds) * time.Second
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	timeoutCtx, op := timeout.Start(ctx, c.cfg, timeout.OscBinaries)
+	defer op.Stop()
 
-	c.cfg.Logger.Debugf("Executing 'osc ls -b' for package: %s, repository: %s", pkg, repository)
+	c.cfg.Logger.With("project", project, "package", pkg, "command", "osc ls -b").Debugf("Executing 'osc ls -b' for package: %s, repository: %s", pkg, repository)
 
 	args := []string{"ls", "-b", project, pkg}
 	if c.cfg.OBSAPIURL != "" {
@@ -204,9 +208,12 @@ func (c *Client) listBinariesForPackage(ctx context.Context, project, pkg, repos
 		args = append(args, "-r", repository)
 	}
 
-	output, err := c.runner.Run(timeoutCtx, "" /* workDir */, "osc", args...)
+	cacheKey := fmt.Sprintf("osc/ls-b/%s/%s/%s/%s", c.cfg.OBSAPIURL, project, pkg, repository)
+	output, err := cache.Remember(c.cfg.CacheDir, cacheKey, c.cfg.MetadataCacheTTL(), func() ([]byte, error) {
+		return c.oscStdout(timeoutCtx, args...)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to run 'osc ls -b' for package '%s': %w. Output: %s", pkg, err, string(output))
+		return nil, fmt.Errorf("failed to run 'osc ls -b' for package '%s': %w", pkg, op.Err(err))
 	}
 
 	binaries := strings.Split(string(output), "\n")
@@ -227,3 +234,21 @@ func (c *Client) listBinariesForPackage(ctx context.Context, project, pkg, repos
 
 	return cleanedBinaries, nil
 }
+
+// oscStdout runs osc and returns its standard output. Warnings that osc prints on standard
+// error, e.g. about its configuration, are logged at debug level instead of being parsed.
+func (c *Client) oscStdout(ctx context.Context, args ...string) ([]byte, error) {
+	result, err := c.runner.Exec(ctx, "" /* workDir */, "osc", args...)
+	if err != nil {
+		return nil, err
+	}
+	c.logStderr(args, result.Stderr)
+	return result.Stdout, nil
+}
+
+// logStderr logs what a successful osc command printed on standard error.
+func (c *Client) logStderr(args []string, stderr []byte) {
+	if text := strings.TrimSpace(string(stderr)); text != "" {
+		c.cfg.Logger.Debugf("'osc %s' printed on stderr: %s", strings.Join(args, " "), text)
+	}
+}
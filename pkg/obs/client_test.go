@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/gyr/relx-go/pkg/command"
 	"github.com/gyr/relx-go/pkg/command/commandtest"
 	"github.com/gyr/relx-go/pkg/config"
 	"github.com/gyr/relx-go/pkg/logging"
@@ -221,3 +222,30 @@ product/x86_64
 		}
 	})
 }
+
+func TestListPackagesIgnoresStderr(t *testing.T) {
+	mockCfg := &config.Config{
+		Logger:                  logging.NewLogger(logging.LevelDebug),
+		OperationTimeoutSeconds: 5,
+	}
+	mockRunner := &commandtest.MockRunner{
+		ExecFunc: func(ctx context.Context, workDir, name string, args ...string) (*command.Result, error) {
+			return &command.Result{
+				Stdout: []byte("pkg1\npkg2\n"),
+				Stderr: []byte("WARNING: Using a plain text password in ~/.config/osc/oscrc\n"),
+			}, nil
+		},
+	}
+
+	var packages []string
+	err := NewClient(mockRunner, mockCfg).listPackages(context.Background(), "SUSE:SLFO:Main", func(pkg string) error {
+		packages = append(packages, pkg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := []string{"pkg1", "pkg2"}; !reflect.DeepEqual(packages, want) {
+		t.Errorf("Expected packages %v, got %v", want, packages)
+	}
+}
@@ -0,0 +1,171 @@
+// Package progress reports the progress of long-running operations on many items, e.g.
+// listing the binaries of hundreds of OBS packages, on standard error.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode selects how progress is reported.
+type Mode int
+
+const (
+	// ModeAuto draws a bar on terminals and prints plain lines otherwise.
+	ModeAuto Mode = iota
+	// ModeBar redraws a single line with a bar, the counts and the estimated time left.
+	ModeBar
+	// ModePlain prints a line now and then, for logs and pipes.
+	ModePlain
+	// ModeQuiet reports nothing, e.g. with -q.
+	ModeQuiet
+)
+
+const (
+	barWidth = 30
+	// redrawInterval limits how often the bar is redrawn.
+	redrawInterval = 100 * time.Millisecond
+	// plainInterval is the time between two lines in ModePlain.
+	plainInterval = 10 * time.Second
+)
+
+// Reporter starts the progress reports of operations. A nil *Reporter reports nothing,
+// so code can report progress without checking whether anyone is interested.
+type Reporter struct {
+	w    io.Writer
+	mode Mode
+	now  func() time.Time // Replaced by tests
+}
+
+// New returns a Reporter that writes to w. ModeAuto draws a bar if w is a terminal.
+func New(w io.Writer, mode Mode) *Reporter {
+	if mode == ModeAuto {
+		mode = ModePlain
+		if isTerminal(w) {
+			mode = ModeBar
+		}
+	}
+	return &Reporter{w: w, mode: mode, now: time.Now}
+}
+
+// isTerminal reports whether w is a character device, e.g. a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Task is the progress of a single operation on total items. Its methods are safe for
+// concurrent use, and do nothing on a nil *Task.
+type Task struct {
+	r     *Reporter
+	label string
+	total int
+
+	mu       sync.Mutex
+	done     int
+	start    time.Time
+	lastDraw time.Time
+	drawn    bool // Progress was written at least once
+	finished bool
+}
+
+// Start starts reporting the progress of an operation on total items, e.g.
+// Start("Listing binaries", 120). It returns nil if progress is not reported.
+func (r *Reporter) Start(label string, total int) *Task {
+	if r == nil || r.mode == ModeQuiet || total <= 0 {
+		return nil
+	}
+	now := r.now()
+	t := &Task{r: r, label: label, total: total, start: now}
+	if r.mode == ModeBar {
+		t.draw(now)
+	} else {
+		t.lastDraw = now
+	}
+	return t
+}
+
+// Add records that n more items are done.
+func (t *Task) Add(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return
+	}
+	t.done += n
+	now := t.r.now()
+	interval := redrawInterval
+	if t.r.mode == ModePlain {
+		interval = plainInterval
+	}
+	if now.Sub(t.lastDraw) >= interval {
+		t.draw(now)
+	}
+}
+
+// Finish ends the report with the final counts and the time taken. In ModePlain, it
+// prints nothing if the operation finished before its first progress line.
+func (t *Task) Finish() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return
+	}
+	t.finished = true
+	elapsed := t.r.now().Sub(t.start).Round(time.Second)
+	if t.r.mode == ModeBar {
+		fmt.Fprintf(t.r.w, "\r%s %s %d/%d in %s\033[K\n", t.label, bar(t.done, t.total), t.done, t.total, elapsed)
+		return
+	}
+	if !t.drawn {
+		return // Quick operations are not worth a line in logs
+	}
+	fmt.Fprintf(t.r.w, "%s: %d/%d done in %s\n", t.label, t.done, t.total, elapsed)
+}
+
+// draw writes the current progress. The caller holds t.mu.
+func (t *Task) draw(now time.Time) {
+	t.lastDraw, t.drawn = now, true
+	status := fmt.Sprintf("%d/%d (%d%%)", t.done, t.total, t.done*100/t.total)
+	if eta, ok := t.eta(now); ok {
+		status += ", ETA " + eta.String()
+	}
+	if t.r.mode == ModeBar {
+		// \033[K clears what is left of a longer previous line.
+		fmt.Fprintf(t.r.w, "\r%s %s %s\033[K", t.label, bar(t.done, t.total), status)
+		return
+	}
+	fmt.Fprintf(t.r.w, "%s: %s\n", t.label, status)
+}
+
+// eta estimates the time left from the average time per item so far.
+func (t *Task) eta(now time.Time) (time.Duration, bool) {
+	if t.done == 0 || t.done >= t.total {
+		return 0, false
+	}
+	perItem := now.Sub(t.start) / time.Duration(t.done)
+	return (perItem * time.Duration(t.total-t.done)).Round(time.Second), true
+}
+
+// bar returns a bar like [=========>         ] for done of total items.
+func bar(done, total int) string {
+	filled := done * barWidth / total
+	if filled >= barWidth {
+		return "[" + strings.Repeat("=", barWidth) + "]"
+	}
+	return "[" + strings.Repeat("=", filled) + ">" + strings.Repeat(" ", barWidth-filled-1) + "]"
+}
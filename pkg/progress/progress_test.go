@@ -0,0 +1,90 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a reporter writing to out whose clock is advanced by the returned func.
+func fakeClock(out *bytes.Buffer, mode Mode) (*Reporter, func(time.Duration)) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := New(out, mode)
+	r.now = func() time.Time { return now }
+	return r, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestBar(t *testing.T) {
+	var out bytes.Buffer
+	r, advance := fakeClock(&out, ModeBar)
+
+	task := r.Start("Listing binaries", 4)
+	advance(10 * time.Second)
+	task.Add(1)
+	advance(10 * time.Millisecond)
+	task.Add(1) // Too soon to redraw
+	advance(10 * time.Second)
+	task.Finish()
+
+	got := strings.Split(out.String(), "\r")
+	want := []string{
+		"",
+		"Listing binaries [>                             ] 0/4 (0%)\033[K",
+		"Listing binaries [=======>                      ] 1/4 (25%), ETA 30s\033[K",
+		"Listing binaries [===============>              ] 2/4 in 20s\033[K\n",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Unexpected output:\nGot:  %q\nWant: %q", got, want)
+	}
+}
+
+func TestPlain(t *testing.T) {
+	var out bytes.Buffer
+	r, advance := fakeClock(&out, ModePlain)
+
+	task := r.Start("Syncing repositories", 3)
+	advance(time.Second)
+	task.Add(1) // Too soon for a line
+	advance(11 * time.Second)
+	task.Add(1)
+	advance(time.Second)
+	task.Add(1)
+	task.Finish()
+	task.Finish()
+
+	want := "Syncing repositories: 2/3 (66%), ETA 6s\nSyncing repositories: 3/3 done in 13s\n"
+	if out.String() != want {
+		t.Errorf("Unexpected output:\nGot:  %q\nWant: %q", out.String(), want)
+	}
+
+	// Quick operations print nothing.
+	out.Reset()
+	task = r.Start("Syncing repositories", 1)
+	task.Add(1)
+	task.Finish()
+	if out.Len() != 0 {
+		t.Errorf("Expected no output for a quick operation, got %q", out.String())
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	var out bytes.Buffer
+	for _, r := range []*Reporter{New(&out, ModeQuiet), nil} {
+		task := r.Start("Listing binaries", 10)
+		if task != nil {
+			t.Fatalf("Expected no task, got %+v", task)
+		}
+		task.Add(1) // Safe on nil
+		task.Finish()
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output, got %q", out.String())
+	}
+}
+
+func TestAutoWithoutTerminal(t *testing.T) {
+	if r := New(&bytes.Buffer{}, ModeAuto); r.mode != ModePlain {
+		t.Errorf("Expected plain lines for a buffer, got mode %d", r.mode)
+	}
+}
@@ -0,0 +1,129 @@
+// Package timeout bounds external operations by their configured timeouts and reports
+// which operation ran out of time.
+package timeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gyr/relx-go/pkg/config"
+	"github.com/gyr/relx-go/pkg/logging"
+)
+
+// Operation names a kind of external operation. The names of operations with their own
+// timeout setting match the keys of the `timeouts` configuration.
+type Operation string
+
+// Operations with their own timeout setting.
+const (
+	OscList      Operation = "osc_list"
+	OscBinaries  Operation = "osc_binaries"
+	GitClone     Operation = "git_clone"
+	GitFetch     Operation = "git_fetch"
+	GiteaList    Operation = "gitea_list"
+	GiteaComment Operation = "gitea_comment"
+)
+
+// Operations bounded by `operation_timeout_seconds`.
+const (
+	GitArchive    Operation = "git_archive"
+	GitLsRemote   Operation = "git_ls_remote"
+	GitSubmodules Operation = "git_submodules"
+	GiteaShow     Operation = "gitea_show"
+	GiteaAPI      Operation = "gitea_api"
+	DoctorCheck   Operation = "doctor_check"
+)
+
+// ExitCode is the exit status of relx-go when it stops because an operation timed out.
+// It matches timeout(1), so that scripts can tell timeouts from other failures.
+const ExitCode = 124
+
+// TimeoutError reports that an operation ran out of time, either because its own
+// timeout expired or because the deadline of the whole run was reached.
+type TimeoutError struct {
+	Op      Operation
+	Elapsed time.Duration
+	Timeout time.Duration
+	// Deadline is true if the deadline of the whole run (--deadline) was reached
+	// before the timeout of the operation.
+	Deadline bool
+	Err      error
+}
+
+func (e *TimeoutError) Error() string {
+	elapsed := e.Elapsed.Round(100 * time.Millisecond)
+	if e.Deadline {
+		return fmt.Sprintf("%s was stopped by the deadline of the run after %s: %v", e.Op, elapsed, e.Err)
+	}
+	return fmt.Sprintf("%s timed out after %s (timeout: %s): %v", e.Op, elapsed, e.Timeout, e.Err)
+}
+
+// ExitCode returns ExitCode, so that logging.Logger.Fatal exits with it.
+func (e *TimeoutError) ExitCode() int {
+	return ExitCode
+}
+
+// Unwrap returns the error of the operation and context.DeadlineExceeded, so that
+// errors.Is works as for plain context timeouts.
+func (e *TimeoutError) Unwrap() []error {
+	return []error{e.Err, context.DeadlineExceeded}
+}
+
+// Op is a running operation bounded by its timeout.
+type Op struct {
+	name    Operation
+	parent  context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	start   time.Time
+	timeout time.Duration
+	logger  *logging.Logger
+}
+
+// Start starts the operation op and returns a context bounded by its configured timeout.
+// The caller must call Stop when the operation is done, and pass its error through Err
+// to report a timeout:
+//
+//	ctx, op := timeout.Start(ctx, cfg, timeout.GitClone)
+//	defer op.Stop()
+//	if err := run(ctx); err != nil {
+//		return fmt.Errorf("git clone failed: %w", op.Err(err))
+//	}
+func Start(ctx context.Context, cfg *config.Config, name Operation) (context.Context, *Op) {
+	op := &Op{
+		name:    name,
+		parent:  ctx,
+		start:   time.Now(),
+		timeout: cfg.OperationTimeout(string(name)),
+		logger:  cfg.Logger,
+	}
+	op.ctx, op.cancel = context.WithTimeout(ctx, op.timeout)
+	return op.ctx, op
+}
+
+// Stop releases the resources of the operation and logs its duration at debug level.
+func (o *Op) Stop() {
+	o.cancel()
+	if o.logger != nil {
+		elapsed := time.Since(o.start)
+		o.logger.With("operation", string(o.name), "duration", elapsed).Debugf("Operation %s finished after %s", o.name, elapsed)
+	}
+}
+
+// Err returns a *TimeoutError if the operation ran out of time, and err otherwise.
+// External commands killed at the deadline fail with errors like "signal: killed",
+// so the state of the context decides, not the error itself.
+func (o *Op) Err(err error) error {
+	if err == nil || !errors.Is(o.ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &TimeoutError{
+		Op:       o.name,
+		Elapsed:  time.Since(o.start),
+		Timeout:  o.timeout,
+		Deadline: errors.Is(o.parent.Err(), context.DeadlineExceeded),
+		Err:      err,
+	}
+}
@@ -0,0 +1,86 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gyr/relx-go/pkg/config"
+)
+
+func TestOpErr(t *testing.T) {
+	cfg := &config.Config{
+		OperationTimeoutSeconds: 300,
+		Timeouts:                config.Timeouts{GiteaComment: 1},
+	}
+	errKilled := errors.New("signal: killed")
+
+	t.Run("NoTimeout", func(t *testing.T) {
+		_, op := Start(context.Background(), cfg, GitClone)
+		defer op.Stop()
+
+		if err := op.Err(nil); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if err := op.Err(errKilled); err != errKilled {
+			t.Errorf("Expected the error to be returned unchanged, got %v", err)
+		}
+	})
+
+	t.Run("OperationTimeout", func(t *testing.T) {
+		ctx, op := Start(context.Background(), cfg, GiteaComment)
+		defer op.Stop()
+		<-ctx.Done()
+
+		var timeoutErr *TimeoutError
+		err := op.Err(errKilled)
+		if !errors.As(err, &timeoutErr) {
+			t.Fatalf("Expected a TimeoutError, got %v", err)
+		}
+		if timeoutErr.Op != GiteaComment || timeoutErr.Timeout != time.Second || timeoutErr.Deadline {
+			t.Errorf("Unexpected TimeoutError: %+v", timeoutErr)
+		}
+		if timeoutErr.ExitCode() != ExitCode {
+			t.Errorf("Expected exit code %d, got %d", ExitCode, timeoutErr.ExitCode())
+		}
+		if timeoutErr.Elapsed < time.Second {
+			t.Errorf("Expected at least 1s elapsed, got %v", timeoutErr.Elapsed)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errKilled) {
+			t.Errorf("Expected the error to wrap context.DeadlineExceeded and the original error, got %v", err)
+		}
+		if msg := err.Error(); !strings.HasPrefix(msg, "gitea_comment timed out after 1") || !strings.HasSuffix(msg, "(timeout: 1s): signal: killed") {
+			t.Errorf("Unexpected message: %q", msg)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		parent, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		ctx, op := Start(parent, cfg, GitClone)
+		defer op.Stop()
+		<-ctx.Done()
+
+		var timeoutErr *TimeoutError
+		err := op.Err(errKilled)
+		if !errors.As(err, &timeoutErr) || !timeoutErr.Deadline {
+			t.Fatalf("Expected a TimeoutError caused by the deadline, got %v", err)
+		}
+		if !strings.HasPrefix(err.Error(), "git_clone was stopped by the deadline of the run after") {
+			t.Errorf("Unexpected message: %q", err.Error())
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+		_, op := Start(parent, cfg, GitClone)
+		defer op.Stop()
+		cancel()
+
+		if err := op.Err(errKilled); err != errKilled {
+			t.Errorf("Expected a cancellation not to be reported as a timeout, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,499 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Review states of a ReviewItem.
+const (
+	StatusPending   = ""
+	StatusApproved  = "approved"
+	StatusSkipped   = "skipped"
+	StatusCommented = "commented"
+)
+
+// ReviewItem is a pull request in the review queue.
+type ReviewItem struct {
+	ID      string // The index of the PR in its repository, e.g. "499"
+	Group   string // The repository and branch, shown if the queue has several, e.g. "products/SLFO (main)"
+	Title   string
+	Author  string
+	Created time.Time // Zero if unknown
+	CIState string    // e.g. "success", "pending" or "failure"; empty if unknown
+	URL     string
+	Status  string // One of the Status constants
+}
+
+// ReviewActions are the operations on pull requests offered by the review UI. They run
+// while the UI waits, and their errors are shown on the status line.
+type ReviewActions interface {
+	// Diff returns the text shown in the diff pane, e.g. the output of `git obs pr show`.
+	Diff(item *ReviewItem) (string, error)
+	Approve(item *ReviewItem) error
+	Comment(item *ReviewItem, message string) error
+	// Open opens the pull request in a web browser.
+	Open(item *ReviewItem) error
+}
+
+// Panes of the review UI that keys like up and down act on.
+const (
+	paneList = iota
+	paneDiff
+)
+
+const reviewHelp = "a approve  s skip  c comment  o open  J/K reorder  tab pane  ? help  q quit"
+
+const reviewFullHelp = "↑/k ↓/j move or scroll  PgUp/PgDn/space scroll diff  g/G first/last  J/K move PR down/up  " +
+	"a approve  s skip  c comment  o open in browser  tab switch pane  q quit"
+
+// ansiEscape matches the color codes in the output of external tools.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// review is the state of the review UI. It is separate from the screen, so that it can
+// be tested with scripted keys.
+type review struct {
+	title   string
+	items   []*ReviewItem
+	actions ReviewActions
+	now     func() time.Time
+
+	groupWidth int // Width of the group column; zero if all items are in one group
+
+	cursor   int // Selected item
+	listTop  int // First item shown in the list pane
+	focus    int // paneList or paneDiff
+	diffs    map[*ReviewItem][]string
+	diffTop  int // First line shown in the diff pane
+	status   string
+	fullHelp bool
+
+	// A comment being typed; commenting is true while the prompt is shown.
+	commenting bool
+	input      []rune
+
+	// The height of the diff pane in the last rendering, for paging.
+	diffHeight int
+}
+
+// RunReview runs the review UI on screen until the user quits. The items are reviewed in
+// place: their Status records what was done, and the user may reorder them.
+func RunReview(screen Screen, title string, items []*ReviewItem, actions ReviewActions) error {
+	r := &review{
+		title:   title,
+		items:   items,
+		actions: actions,
+		now:     time.Now,
+		diffs:   make(map[*ReviewItem][]string),
+	}
+	r.groupWidth = groupWidth(items)
+	for {
+		if err := r.loadDiff(screen); err != nil {
+			return err
+		}
+		if err := screen.Draw(r.render(screen.Size())); err != nil {
+			return err
+		}
+		key, err := screen.ReadKey()
+		if err != nil {
+			return err
+		}
+		if quit := r.handleKey(screen, key); quit {
+			return nil
+		}
+	}
+}
+
+// selected returns the selected item, or nil if the queue is empty.
+func (r *review) selected() *ReviewItem {
+	if len(r.items) == 0 {
+		return nil
+	}
+	return r.items[r.cursor]
+}
+
+// loadDiff loads the diff of the selected item unless it is loaded already.
+func (r *review) loadDiff(screen Screen) error {
+	item := r.selected()
+	if item == nil {
+		return nil
+	}
+	if _, loaded := r.diffs[item]; loaded {
+		return nil
+	}
+	if err := r.busy(screen, fmt.Sprintf("Loading #%s...", item.ID)); err != nil {
+		return err
+	}
+	text, err := r.actions.Diff(item)
+	if err != nil {
+		r.status = fmt.Sprintf("Failed to load #%s: %v", item.ID, err)
+		text = ""
+	}
+	r.diffs[item] = diffLines(text)
+	return nil
+}
+
+// busy shows status while an action runs.
+func (r *review) busy(screen Screen, status string) error {
+	r.status = status
+	return screen.Draw(r.render(screen.Size()))
+}
+
+// handleKey applies a key press and returns true if the user quits.
+func (r *review) handleKey(screen Screen, key Key) bool {
+	if r.commenting {
+		r.handleInput(screen, key)
+		return false
+	}
+	r.status = ""
+	item := r.selected()
+
+	switch {
+	case key.Code == KeyCtrlC, key.Code == KeyRune && key.Rune == 'q':
+		return true
+	case key.Code == KeyTab:
+		r.focus = 1 - r.focus
+	case key.Code == KeyUp, key.Code == KeyRune && key.Rune == 'k':
+		r.move(-1)
+	case key.Code == KeyDown, key.Code == KeyRune && key.Rune == 'j':
+		r.move(1)
+	case key.Code == KeyPageUp, key.Code == KeyRune && key.Rune == 'b':
+		r.scrollDiff(-r.page())
+	case key.Code == KeyPageDown, key.Code == KeyRune && key.Rune == ' ':
+		r.scrollDiff(r.page())
+	case key.Code == KeyHome, key.Code == KeyRune && key.Rune == 'g':
+		r.move(-len(r.items) - len(r.diffs[item]))
+	case key.Code == KeyEnd, key.Code == KeyRune && key.Rune == 'G':
+		r.move(len(r.items) + len(r.diffs[item]))
+	case key.Code == KeyRune && key.Rune == 'J':
+		r.reorder(1)
+	case key.Code == KeyRune && key.Rune == 'K':
+		r.reorder(-1)
+	case key.Code == KeyRune && key.Rune == '?':
+		r.fullHelp = !r.fullHelp
+	case item == nil:
+		// The remaining keys act on the selected pull request.
+	case key.Code == KeyRune && key.Rune == 'a':
+		_ = r.busy(screen, fmt.Sprintf("Approving #%s...", item.ID))
+		if err := r.actions.Approve(item); err != nil {
+			r.status = fmt.Sprintf("Failed to approve #%s: %v", item.ID, err)
+			break
+		}
+		item.Status = StatusApproved
+		r.status = fmt.Sprintf("#%s approved.", item.ID)
+		r.nextPending()
+	case key.Code == KeyRune && key.Rune == 's':
+		item.Status = StatusSkipped
+		r.status = fmt.Sprintf("#%s skipped.", item.ID)
+		r.nextPending()
+	case key.Code == KeyRune && key.Rune == 'c':
+		r.commenting, r.input = true, nil
+	case key.Code == KeyRune && key.Rune == 'o':
+		if err := r.actions.Open(item); err != nil {
+			r.status = fmt.Sprintf("Failed to open #%s: %v", item.ID, err)
+		} else {
+			r.status = fmt.Sprintf("Opened %s", item.URL)
+		}
+	}
+	return false
+}
+
+// handleInput edits the comment being typed, and sends it on Enter.
+func (r *review) handleInput(screen Screen, key Key) {
+	item := r.selected()
+	switch key.Code {
+	case KeyEscape, KeyCtrlC:
+		r.commenting = false
+		r.status = "Comment discarded."
+	case KeyBackspace:
+		if len(r.input) > 0 {
+			r.input = r.input[:len(r.input)-1]
+		}
+	case KeyRune:
+		r.input = append(r.input, key.Rune)
+	case KeyEnter:
+		r.commenting = false
+		message := strings.TrimSpace(string(r.input))
+		if message == "" {
+			r.status = "Empty comment discarded."
+			return
+		}
+		_ = r.busy(screen, fmt.Sprintf("Commenting on #%s...", item.ID))
+		if err := r.actions.Comment(item, message); err != nil {
+			r.status = fmt.Sprintf("Failed to comment on #%s: %v", item.ID, err)
+			return
+		}
+		if item.Status == StatusPending {
+			item.Status = StatusCommented
+		}
+		r.status = fmt.Sprintf("Commented on #%s.", item.ID)
+	}
+}
+
+// move moves the selection in the list pane, or scrolls the diff pane, by delta lines.
+func (r *review) move(delta int) {
+	if r.focus == paneDiff {
+		r.scrollDiff(delta)
+		return
+	}
+	r.selectItem(r.cursor + delta)
+}
+
+// selectItem selects the item at index i, clamped to the queue.
+func (r *review) selectItem(i int) {
+	if i >= len(r.items) {
+		i = len(r.items) - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i != r.cursor {
+		r.cursor = i
+		r.diffTop = 0
+	}
+}
+
+// scrollDiff scrolls the diff pane by delta lines.
+func (r *review) scrollDiff(delta int) {
+	lines := len(r.diffs[r.selected()])
+	r.diffTop += delta
+	if last := lines - r.diffHeight; r.diffTop > last {
+		r.diffTop = last
+	}
+	if r.diffTop < 0 {
+		r.diffTop = 0
+	}
+}
+
+// page returns the number of lines scrolled by page up and down.
+func (r *review) page() int {
+	if r.diffHeight > 2 {
+		return r.diffHeight - 2 // Keep some context
+	}
+	return 1
+}
+
+// reorder moves the selected item by delta places in the queue.
+func (r *review) reorder(delta int) {
+	to := r.cursor + delta
+	if len(r.items) == 0 || to < 0 || to >= len(r.items) {
+		return
+	}
+	r.items[r.cursor], r.items[to] = r.items[to], r.items[r.cursor]
+	r.cursor = to
+}
+
+// nextPending selects the next pending item after the selected one, wrapping around.
+// The selection stays if no item is pending.
+func (r *review) nextPending() {
+	for i := 1; i < len(r.items); i++ {
+		j := (r.cursor + i) % len(r.items)
+		if r.items[j].Status == StatusPending {
+			r.selectItem(j)
+			return
+		}
+	}
+}
+
+// render returns the lines of the screen: a header, the list pane, a separator, the diff
+// pane and a status line.
+func (r *review) render(width, height int) []string {
+	lines := []string{reverse(fit(" "+r.title+"  "+r.counts(), width))}
+
+	// The list pane takes up to a third of the screen; the diff pane gets the rest.
+	listHeight := len(r.items)
+	if limit := (height - 3) / 3; listHeight > limit {
+		listHeight = limit
+	}
+	if listHeight < 1 {
+		listHeight = 1
+	}
+	if r.cursor < r.listTop {
+		r.listTop = r.cursor
+	}
+	if r.cursor >= r.listTop+listHeight {
+		r.listTop = r.cursor - listHeight + 1
+	}
+	for i := r.listTop; i < r.listTop+listHeight; i++ {
+		if i >= len(r.items) {
+			if len(r.items) == 0 {
+				lines = append(lines, fit("  No pull requests.", width))
+			} else {
+				lines = append(lines, "")
+			}
+			continue
+		}
+		row := r.listRow(r.items[i], width)
+		if i == r.cursor {
+			row = reverse(row)
+		}
+		lines = append(lines, row)
+	}
+
+	r.diffHeight = height - len(lines) - 2
+	if r.diffHeight < 0 {
+		r.diffHeight = 0
+	}
+	diff := r.diffs[r.selected()]
+	separator := "── diff "
+	if r.focus == paneDiff {
+		separator = "══ diff "
+	}
+	if len(diff) > 0 {
+		last := r.diffTop + r.diffHeight
+		if last > len(diff) {
+			last = len(diff)
+		}
+		separator += fmt.Sprintf("(lines %d-%d of %d) ", r.diffTop+1, last, len(diff))
+	}
+	lines = append(lines, fit(separator+strings.Repeat("─", width), width))
+	for i := 0; i < r.diffHeight; i++ {
+		if n := r.diffTop + i; n < len(diff) {
+			lines = append(lines, colorDiff(fit(diff[n], width)))
+		} else {
+			lines = append(lines, "")
+		}
+	}
+
+	switch {
+	case r.commenting:
+		lines = append(lines, fit(fmt.Sprintf("Comment on #%s (Enter to send, Esc to cancel): %s_", r.selected().ID, string(r.input)), width))
+	case r.status != "":
+		// Errors may span lines, e.g. with the standard error of a command.
+		lines = append(lines, fit(strings.Join(strings.Fields(r.status), " "), width))
+	case r.fullHelp:
+		lines = append(lines, fit(reviewFullHelp, width))
+	default:
+		lines = append(lines, fit(reviewHelp, width))
+	}
+	return lines
+}
+
+// counts summarizes the review states of the queue.
+func (r *review) counts() string {
+	var pending, approved, skipped, commented int
+	for _, item := range r.items {
+		switch item.Status {
+		case StatusApproved:
+			approved++
+		case StatusSkipped:
+			skipped++
+		case StatusCommented:
+			commented++
+		default:
+			pending++
+		}
+	}
+	return fmt.Sprintf("%d pending, %d approved, %d commented, %d skipped", pending, approved, commented, skipped)
+}
+
+// listRow returns the row of an item in the list pane: its review state, ID, title,
+// author, age and CI state.
+func (r *review) listRow(item *ReviewItem, width int) string {
+	mark := map[string]string{
+		StatusPending:   " ",
+		StatusApproved:  "✓",
+		StatusSkipped:   "-",
+		StatusCommented: "✎",
+	}[item.Status]
+	tail := " " + fit(item.Author, 14) + " " + fit(age(r.now(), item.Created), 4) + " " + fit(item.CIState, 7)
+	head := fmt.Sprintf(" %s #%-6s ", mark, item.ID)
+	if r.groupWidth > 0 {
+		head += fit(item.Group, r.groupWidth) + " "
+	}
+	titleWidth := width - len([]rune(head)) - len([]rune(tail))
+	if titleWidth < 10 {
+		return fit(head+item.Title, width)
+	}
+	return head + fit(item.Title, titleWidth) + tail
+}
+
+// groupWidth returns the width of the group column of the list pane: the longest group
+// up to 30 characters, or zero if all items are in the same group.
+func groupWidth(items []*ReviewItem) int {
+	width, several := 0, false
+	for _, item := range items {
+		several = several || item.Group != items[0].Group
+		if n := len([]rune(item.Group)); n > width {
+			width = n
+		}
+	}
+	if !several {
+		return 0
+	}
+	if width > 30 {
+		width = 30
+	}
+	return width
+}
+
+// age returns how long ago t was, e.g. "5m", "3h" or "12d".
+func age(now, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// diffLines splits the text of the diff pane into lines, without the colors of the tool
+// that produced it and with tabs expanded.
+func diffLines(text string) []string {
+	text = strings.TrimRight(ansiEscape.ReplaceAllString(text, ""), "\n")
+	if text == "" {
+		return []string{}
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(strings.TrimRight(line, "\r"))
+	}
+	return lines
+}
+
+// expandTabs replaces tabs with spaces up to the next multiple of 8 columns.
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, ch := range line {
+		if ch == '\t' {
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(ch)
+		col++
+	}
+	return b.String()
+}
+
+// colorDiff colors a line of a unified diff.
+func colorDiff(line string) string {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff "):
+		return "\033[1m" + line + "\033[0m"
+	case strings.HasPrefix(line, "+"):
+		return "\033[32m" + line + "\033[0m"
+	case strings.HasPrefix(line, "-"):
+		return "\033[31m" + line + "\033[0m"
+	case strings.HasPrefix(line, "@@"):
+		return "\033[36m" + line + "\033[0m"
+	}
+	return line
+}
+
+// reverse shows s in reverse video.
+func reverse(s string) string {
+	return "\033[7m" + s + "\033[0m"
+}
@@ -0,0 +1,230 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptScreen is a Screen that returns scripted keys and records what was drawn.
+type scriptScreen struct {
+	keys   []Key
+	height int // 20 if zero
+	frames [][]string
+}
+
+func (s *scriptScreen) Size() (int, int) {
+	if s.height == 0 {
+		return 100, 20
+	}
+	return 100, s.height
+}
+
+func (s *scriptScreen) ReadKey() (Key, error) {
+	if len(s.keys) == 0 {
+		return Key{}, io.EOF
+	}
+	key := s.keys[0]
+	s.keys = s.keys[1:]
+	return key, nil
+}
+
+func (s *scriptScreen) Draw(lines []string) error {
+	s.frames = append(s.frames, lines)
+	return nil
+}
+
+// last returns the last frame as a single string.
+func (s *scriptScreen) last() string {
+	return strings.Join(s.frames[len(s.frames)-1], "\n")
+}
+
+// typed returns the key presses of the characters of s.
+func typed(s string) []Key {
+	var keys []Key
+	for _, ch := range s {
+		keys = append(keys, Key{Code: KeyRune, Rune: ch})
+	}
+	return keys
+}
+
+// fakeActions records the actions and fails those on the PRs in fail.
+type fakeActions struct {
+	calls []string
+	fail  map[string]bool
+}
+
+func (f *fakeActions) do(call string, item *ReviewItem) error {
+	f.calls = append(f.calls, call)
+	if f.fail[item.ID] {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func (f *fakeActions) Diff(item *ReviewItem) (string, error) {
+	err := f.do("diff "+item.ID, item)
+	return fmt.Sprintf("\x1b[1mdiff --git a/%s.spec b/%s.spec\x1b[0m\n+\tadded\n", item.ID, item.ID), err
+}
+
+func (f *fakeActions) Approve(item *ReviewItem) error { return f.do("approve "+item.ID, item) }
+
+func (f *fakeActions) Comment(item *ReviewItem, message string) error {
+	return f.do("comment "+item.ID+" "+message, item)
+}
+
+func (f *fakeActions) Open(item *ReviewItem) error { return f.do("open "+item.ID, item) }
+
+func testItems() []*ReviewItem {
+	created := time.Now().Add(-49 * time.Hour)
+	return []*ReviewItem{
+		{ID: "1", Title: "Update foo", Author: "alice", Created: created, CIState: "success"},
+		{ID: "2", Title: "Update bar", Author: "bob", Created: created, CIState: "failure"},
+		{ID: "3", Title: "Update baz", Author: "carol"},
+	}
+}
+
+func TestRunReview(t *testing.T) {
+	t.Run("ApproveSkipComment", func(t *testing.T) {
+		items := testItems()
+		actions := &fakeActions{}
+		keys := typed("a")                     // Approve #1 and go to #2
+		keys = append(keys, typed("s")...)     // Skip #2 and go to #3
+		keys = append(keys, typed("cLGTM")...) // Comment on #3
+		keys = append(keys, Key{Code: KeyEnter})
+		keys = append(keys, typed("c")...) // Start a comment and cancel it
+		keys = append(keys, Key{Code: KeyEscape}, Key{Code: KeyCtrlC})
+		screen := &scriptScreen{keys: keys}
+
+		if err := RunReview(screen, "Review", items, actions); err != nil {
+			t.Fatalf("RunReview() error = %v", err)
+		}
+
+		want := []string{"diff 1", "approve 1", "diff 2", "diff 3", "comment 3 LGTM"}
+		if strings.Join(actions.calls, "|") != strings.Join(want, "|") {
+			t.Errorf("calls = %q, want %q", actions.calls, want)
+		}
+		for i, status := range []string{StatusApproved, StatusSkipped, StatusCommented} {
+			if items[i].Status != status {
+				t.Errorf("items[%d].Status = %q, want %q", i, items[i].Status, status)
+			}
+		}
+		if got := screen.last(); !strings.Contains(got, "0 pending, 1 approved, 1 commented, 1 skipped") || !strings.Contains(got, "Comment discarded.") {
+			t.Errorf("last frame = %q", got)
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		items := testItems()
+		actions := &fakeActions{fail: map[string]bool{"1": true}}
+		screen := &scriptScreen{keys: typed("aoq")}
+
+		if err := RunReview(screen, "Review", items, actions); err != nil {
+			t.Fatalf("RunReview() error = %v", err)
+		}
+
+		if items[0].Status != StatusPending {
+			t.Errorf("Status = %q after failed approval, want pending", items[0].Status)
+		}
+		frames := make([]string, len(screen.frames))
+		for i, frame := range screen.frames {
+			frames[i] = strings.Join(frame, "\n")
+		}
+		all := strings.Join(frames, "\n")
+		for _, want := range []string{"Failed to load #1: boom", "Failed to approve #1: boom", "Failed to open #1: boom"} {
+			if !strings.Contains(all, want) {
+				t.Errorf("frames do not contain %q", want)
+			}
+		}
+	})
+
+	t.Run("ReorderAndScroll", func(t *testing.T) {
+		items := testItems()
+		// The diff pane has room for a single line.
+		screen := &scriptScreen{keys: append(typed("JjK"), Key{Code: KeyTab}, Key{Code: KeyDown}, Key{Code: KeyRune, Rune: 'q'}), height: 5}
+
+		if err := RunReview(screen, "Review", items, &fakeActions{}); err != nil {
+			t.Fatalf("RunReview() error = %v", err)
+		}
+
+		// J moves #1 down to the second place, j selects the third and K moves it up.
+		var order []string
+		for _, item := range items {
+			order = append(order, item.ID)
+		}
+		if got := strings.Join(order, ","); got != "2,3,1" {
+			t.Errorf("order = %s, want 2,3,1", got)
+		}
+		// The diff pane scrolled down by one line, past the colored header.
+		got := screen.last()
+		if !strings.Contains(got, "(lines 2-2 of 2)") || !strings.Contains(got, "\033[32m+       added") {
+			t.Errorf("last frame = %q", got)
+		}
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		items := testItems()
+		items[0].Group, items[1].Group, items[2].Group = "products/SLFO (main)", "products/SLFO (main)", "products/SLES (16.0)"
+		screen := &scriptScreen{keys: typed("q")}
+
+		if err := RunReview(screen, "Review", items, &fakeActions{}); err != nil {
+			t.Fatalf("RunReview() error = %v", err)
+		}
+		if got := screen.last(); !strings.Contains(got, "#3      products/SLES (16.0) Update baz") {
+			t.Errorf("last frame = %q", got)
+		}
+	})
+
+	t.Run("ReadKeyError", func(t *testing.T) {
+		screen := &scriptScreen{}
+		if err := RunReview(screen, "Review", nil, &fakeActions{}); !errors.Is(err, io.EOF) {
+			t.Errorf("RunReview() error = %v, want io.EOF", err)
+		}
+		if got := screen.last(); !strings.Contains(got, "No pull requests.") {
+			t.Errorf("last frame = %q", got)
+		}
+	})
+}
+
+func TestDecodeKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("j\x1b[Aé\r\x1b[6~\x7f\x03"))
+	want := []Key{
+		{Code: KeyRune, Rune: 'j'},
+		{Code: KeyUp},
+		{Code: KeyRune, Rune: 'é'},
+		{Code: KeyEnter},
+		{Code: KeyPageDown},
+		{Code: KeyBackspace},
+		{Code: KeyCtrlC},
+	}
+	for _, w := range want {
+		got, err := decodeKey(r)
+		if err != nil {
+			t.Fatalf("decodeKey() error = %v", err)
+		}
+		if got != w {
+			t.Errorf("decodeKey() = %+v, want %+v", got, w)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	for _, tc := range []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc  "},
+		{"abcdef", 4, "abc…"},
+		{"äöü", 3, "äöü"},
+		{"abc", 0, ""},
+	} {
+		if got := fit(tc.s, tc.width); got != tc.want {
+			t.Errorf("fit(%q, %d) = %q, want %q", tc.s, tc.width, got, tc.want)
+		}
+	}
+}
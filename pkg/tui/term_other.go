@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package tui
+
+import "errors"
+
+// errUnsupported is returned on systems without termios.
+var errUnsupported = errors.New("raw terminal mode is not supported on this system")
+
+// makeRaw fails: raw terminal mode is only implemented on unix systems.
+func makeRaw(fd uintptr) (func() error, error) {
+	return nil, errUnsupported
+}
+
+// windowSize fails: the terminal size is only known on unix systems.
+func windowSize(fd uintptr) (int, int, error) {
+	return 0, 0, errUnsupported
+}
@@ -0,0 +1,200 @@
+// Package tui provides a small full-screen terminal UI without external dependencies:
+// raw terminal input, key decoding and screen drawing, and the review UI built on them.
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Screen is a full-screen display with keyboard input. Terminal implements it; tests use
+// a scripted screen.
+type Screen interface {
+	// Size returns the number of columns and rows.
+	Size() (width, height int)
+	// ReadKey waits for the next key press.
+	ReadKey() (Key, error)
+	// Draw replaces the content of the screen with lines, which fit its size.
+	Draw(lines []string) error
+}
+
+// KeyCode identifies special keys. Printable characters are KeyRune.
+type KeyCode int
+
+// Keys understood by the UI.
+const (
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyEnter
+	KeyTab
+	KeyEscape
+	KeyBackspace
+	KeyCtrlC
+)
+
+// Key is a key press.
+type Key struct {
+	Code KeyCode
+	Rune rune // The character for KeyRune
+}
+
+// decodeKey reads a key press from the input of a terminal in raw mode, decoding the
+// escape sequences of the cursor keys.
+func decodeKey(r *bufio.Reader) (Key, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return Key{}, err
+	}
+	switch b {
+	case '\r', '\n':
+		return Key{Code: KeyEnter}, nil
+	case '\t':
+		return Key{Code: KeyTab}, nil
+	case 127, 8:
+		return Key{Code: KeyBackspace}, nil
+	case 3:
+		return Key{Code: KeyCtrlC}, nil
+	case 0x1b:
+		// A lone escape is the Escape key; sequences arrive in one read.
+		if r.Buffered() == 0 {
+			return Key{Code: KeyEscape}, nil
+		}
+		return decodeEscape(r)
+	}
+	if err := r.UnreadByte(); err != nil {
+		return Key{}, err
+	}
+	ch, _, err := r.ReadRune()
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Code: KeyRune, Rune: ch}, nil
+}
+
+// decodeEscape decodes the rest of an escape sequence, e.g. "[A" for the up key.
+// Unknown sequences are returned as KeyEscape.
+func decodeEscape(r *bufio.Reader) (Key, error) {
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return Key{}, err
+	}
+	if prefix != '[' && prefix != 'O' {
+		return Key{Code: KeyEscape}, nil
+	}
+	var seq []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return Key{}, err
+		}
+		seq = append(seq, b)
+		// Sequences end with a letter or '~'.
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return Key{Code: KeyUp}, nil
+	case "B":
+		return Key{Code: KeyDown}, nil
+	case "5~":
+		return Key{Code: KeyPageUp}, nil
+	case "6~":
+		return Key{Code: KeyPageDown}, nil
+	case "H", "1~":
+		return Key{Code: KeyHome}, nil
+	case "F", "4~":
+		return Key{Code: KeyEnd}, nil
+	}
+	return Key{Code: KeyEscape}, nil
+}
+
+// Terminal is a Screen on a terminal in raw mode, using the alternate screen so that the
+// content of the terminal is restored on Close.
+type Terminal struct {
+	in      *os.File
+	out     io.Writer
+	reader  *bufio.Reader
+	restore func() error
+}
+
+// Open switches the terminal into raw mode and to the alternate screen. The caller must
+// call Close to restore it.
+func Open(in *os.File, out io.Writer) (*Terminal, error) {
+	restore, err := makeRaw(in.Fd())
+	if err != nil {
+		return nil, fmt.Errorf("tui: %s is not a terminal: %w", in.Name(), err)
+	}
+	t := &Terminal{in: in, out: out, reader: bufio.NewReader(in), restore: restore}
+	// Switch to the alternate screen and hide the cursor.
+	if _, err := io.WriteString(out, "\033[?1049h\033[?25l"); err != nil {
+		_ = restore()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Close shows the cursor, leaves the alternate screen and restores the terminal mode.
+func (t *Terminal) Close() error {
+	_, err := io.WriteString(t.out, "\033[?25h\033[?1049l")
+	if restoreErr := t.restore(); err == nil {
+		err = restoreErr
+	}
+	return err
+}
+
+// Size returns the size of the terminal, or 80x24 if it cannot be determined.
+func (t *Terminal) Size() (int, int) {
+	width, height, err := windowSize(t.in.Fd())
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// ReadKey waits for the next key press.
+func (t *Terminal) ReadKey() (Key, error) {
+	return decodeKey(t.reader)
+}
+
+// Draw redraws the screen. Output processing is off in raw mode, so lines end with CR LF.
+func (t *Terminal) Draw(lines []string) error {
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\033[K") // Clear the rest of the line
+	}
+	b.WriteString("\033[J") // Clear the rest of the screen
+	_, err := io.WriteString(t.out, b.String())
+	return err
+}
+
+// fit truncates s to width characters, or pads it with spaces to width.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		runes := []rune(s)
+		if width == 1 {
+			return string(runes[:1])
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}